@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+
 	"github.com/iwtcode/user-order-api/internal/config"
 	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/mail"
 	"github.com/iwtcode/user-order-api/internal/middleware"
+	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/repository"
 	"github.com/iwtcode/user-order-api/internal/services"
 	"github.com/iwtcode/user-order-api/internal/utils"
@@ -19,12 +23,12 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description Введите JWT токен вместе с префиксом Bearer
+// @description Введите JWT токен вместе с префиксом Bearer или API-ключ с префиксом ApiKey
 // @scheme bearer
 // @bearerFormat JWT
 
 // Настраиваем маршруты HTTP API
-func setupRoutes(userHandler *handlers.UserHandler, authHandler *handlers.AuthHandler, orderHandler *handlers.OrderHandler) *gin.Engine {
+func setupRoutes(userHandler *handlers.UserHandler, authHandler *handlers.AuthHandler, passwordHandler *handlers.PasswordHandler, verificationHandler *handlers.EmailVerificationHandler, mfaHandler *handlers.MFAHandler, apiKeyHandler *handlers.APIKeyHandler, sessionHandler *handlers.SessionHandler, oauthHandler *handlers.OAuthHandler, adminHandler *handlers.AdminHandler, orderHandler *handlers.OrderHandler, revocations services.RevocationStore, apiKeys services.APIKeyService) *gin.Engine {
 	router := gin.New()
 	router.SetTrustedProxies(nil)
 	router.Use(middleware.LoggerMiddleware())
@@ -32,18 +36,66 @@ func setupRoutes(userHandler *handlers.UserHandler, authHandler *handlers.AuthHa
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
 	router.POST("/auth/login", authHandler.Login)
+	router.POST("/auth/login/mfa", authHandler.LoginMFA)
+	router.POST("/auth/refresh", authHandler.Refresh)
+	router.POST("/oauth/token", oauthHandler.Token)
+	router.POST("/auth/password/forgot", passwordHandler.ForgotPassword)
+	router.POST("/auth/password/reset", passwordHandler.ResetPassword)
+	router.GET("/auth/verify-email", verificationHandler.VerifyEmail)
+	router.POST("/auth/verify-email/resend", verificationHandler.ResendVerification)
 	router.POST("/users", userHandler.CreateUser)
 
+	authRoutes := router.Group("/auth")
+	authRoutes.Use(middleware.JWTAuthMiddleware(revocations), middleware.RequireUser())
+	{
+		authRoutes.POST("logout", authHandler.Logout)
+		authRoutes.POST("logout-all", middleware.DenyImpersonation(), authHandler.LogoutAll)
+	}
+
+	// Ресурсы пользователей и администрирование доступны и по API-ключу, и по токену клиента OAuth2
+	// Каждая подгруппа требует своей области действия токена или ключа
 	userRoutes := router.Group("/users")
-	userRoutes.Use(middleware.JWTAuthMiddleware())
+	userRoutes.Use(middleware.AuthMiddleware(revocations, apiKeys))
 	{
-		userRoutes.GET("", userHandler.ListUsers)
-		userRoutes.GET(":id", userHandler.GetUserByID)
-		userRoutes.PUT(":id", userHandler.UpdateUser)
-		userRoutes.DELETE(":id", userHandler.DeleteUser)
-		userRoutes.POST(":id/orders", orderHandler.CreateOrder)
-		userRoutes.GET(":id/orders", orderHandler.GetOrdersByUserID)
+		usersRead := userRoutes.Group("", middleware.RequireScopes(models.ScopeUsersRead))
+		usersRead.GET("", userHandler.ListUsers)
+		usersRead.GET(":id", userHandler.GetUserByID)
+		usersRead.GET(":id/api-keys", apiKeyHandler.ListAPIKeys)
+		usersRead.GET(":id/sessions", sessionHandler.ListSessions)
+
+		usersWrite := userRoutes.Group("", middleware.RequireScopes(models.ScopeUsersWrite))
+		usersWrite.PUT(":id", userHandler.UpdateUser)
+		usersWrite.PATCH(":id", userHandler.PatchUser)
+		usersWrite.DELETE(":id", userHandler.DeleteUser)
+		usersWrite.POST(":id/restore", userHandler.RestoreUser)
+		usersWrite.DELETE(":id/api-keys/:key_id", apiKeyHandler.RevokeAPIKey)
+		usersWrite.DELETE(":id/sessions/:sid", sessionHandler.RevokeSession)
+
+		// Учётные данные нельзя менять по токену имперсонации
+		credentials := usersWrite.Group("", middleware.DenyImpersonation())
+		credentials.PUT(":id/password", passwordHandler.ChangePassword)
+		credentials.POST(":id/mfa/totp", mfaHandler.EnrollTOTP)
+		credentials.POST(":id/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+		credentials.DELETE(":id/mfa/totp", mfaHandler.DisableTOTP)
+		credentials.POST(":id/api-keys", apiKeyHandler.CreateAPIKey)
+
+		ordersRead := userRoutes.Group("", middleware.RequireScopes(models.ScopeOrdersRead))
+		ordersRead.GET(":id/orders", orderHandler.GetOrdersByUserID)
+
+		ordersWrite := userRoutes.Group("", middleware.RequireScopes(models.ScopeOrdersWrite))
+		ordersWrite.POST(":id/orders", orderHandler.CreateOrder)
+	}
+
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(middleware.AuthMiddleware(revocations, apiKeys), middleware.RequireRole(models.RoleAdmin), middleware.RequireScopes(models.ScopeUsersWrite))
+	{
+		adminRoutes.POST("users/:id/unlock", adminHandler.UnlockUser)
+		adminRoutes.POST("users/:id/impersonate", adminHandler.ImpersonateUser)
+		adminRoutes.POST("oauth-clients", oauthHandler.CreateOAuthClient)
+		adminRoutes.GET("oauth-clients", oauthHandler.ListOAuthClients)
+		adminRoutes.DELETE("oauth-clients/:id", oauthHandler.RevokeOAuthClient)
 	}
 
 	return router
@@ -56,7 +108,7 @@ func main() {
 	// Загружаем конфигурацию
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		utils.Error("Failed to load configuration: %v", err)
+		utils.Error("Invalid configuration:\n%v", err)
 		return
 	}
 
@@ -70,6 +122,41 @@ func main() {
 	// Инициализация логгера
 	utils.InitLogger(cfg.LogFile)
 
+	// Загружаем ключи подписи JWT
+	if err := utils.ConfigureJWT(cfg.JWT); err != nil {
+		utils.Error("Failed to configure JWT signing keys: %v", err)
+		return
+	}
+
+	// Настраиваем хеширование паролей
+	if err := utils.ConfigurePasswordHashing(cfg.PasswordHash); err != nil {
+		utils.Error("Failed to configure password hashing: %v", err)
+		return
+	}
+
+	// Настраиваем отправку писем
+	mailer, err := mail.NewMailer(cfg.Mail)
+	if err != nil {
+		utils.Error("Failed to configure mailer: %v", err)
+		return
+	}
+
+	// Загружаем парольную политику
+	passwordPolicy, err := services.NewPasswordPolicy(services.PasswordPolicySettings{
+		MinLength:             cfg.PasswordMinLength,
+		MaxBytes:              cfg.PasswordMaxBytes,
+		RequireUppercase:      cfg.RequiresPasswordClass(config.PasswordClassUpper),
+		RequireLowercase:      cfg.RequiresPasswordClass(config.PasswordClassLower),
+		RequireDigit:          cfg.RequiresPasswordClass(config.PasswordClassDigit),
+		RequireSymbol:         cfg.RequiresPasswordClass(config.PasswordClassSymbol),
+		RejectPersonalInfo:    cfg.PasswordRejectPersonalInfo,
+		BreachedPasswordsFile: cfg.PasswordBreachedListFile,
+	})
+	if err != nil {
+		utils.Error("Failed to load password policy: %v", err)
+		return
+	}
+
 	// Подключаемся к базе данных
 	db, err := gorm.Open(postgres.Open(cfg.DBConnectionString), &gorm.Config{
 		Logger: &utils.GormLogger{},
@@ -82,16 +169,76 @@ func main() {
 	// Инициализируем репозитории, сервисы и хэндлеры
 	userRepo := repository.NewUserRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
-	userService := services.NewUserService(userRepo)
-	orderService := services.NewOrderService(orderRepo, userRepo)
-	authService := services.NewAuthService(userRepo)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	revocationRepo := repository.NewRevocationRepository(db)
+	actionTokenRepo := repository.NewActionTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	oauthClientRepo := repository.NewOAuthClientRepository(db)
+	impersonationRepo := repository.NewImpersonationRepository(db)
+	revocationStore := services.NewRevocationStore(revocationRepo, cfg.RevocationSyncInterval)
+	authorizer := services.NewAuthorizer()
+	verificationService := services.NewEmailVerificationService(userRepo, actionTokenRepo, mailer, services.EmailVerificationSettings{
+		TokenTTL:  cfg.EmailVerificationTTL,
+		VerifyURL: cfg.EmailVerificationURL,
+	})
+	orderService := services.NewOrderService(orderRepo, userRepo, authorizer, services.OrderSettings{
+		RequireVerifiedEmail: cfg.RequiresVerifiedEmail(config.VerifiedEmailForOrders),
+	})
+	mfaService := services.NewMFAService(userRepo, mfaRepo, actionTokenRepo, authorizer, services.MFASettings{
+		Issuer:          cfg.MFAIssuer,
+		PendingTokenTTL: cfg.MFATokenTTL,
+	})
+	loginThrottle := services.NewLoginThrottle(loginThrottleRepo, services.LoginThrottleSettings{
+		AccountMaxFailures: cfg.LoginMaxFailures,
+		IPMaxFailures:      cfg.LoginIPMaxFailures,
+		Lockout:            cfg.LoginLockout,
+		MaxLockout:         cfg.LoginMaxLockout,
+		FailureWindow:      cfg.LoginFailureWindow,
+	})
+	authService := services.NewAuthService(userRepo, refreshTokenRepo, sessionRepo, revocationStore, mfaService, loginThrottle, authorizer, services.AuthSettings{
+		RequireVerifiedEmail: cfg.RequiresVerifiedEmail(config.VerifiedEmailForLogin),
+	})
+	userService := services.NewUserService(userRepo, authorizer, passwordPolicy, verificationService, authService, mailer, services.UserSettings{
+		SignupPrivacyMode: cfg.SignupPrivacyMode,
+		OrderPolicy:       cfg.UserDeleteOrderPolicy,
+	})
+	apiKeyService := services.NewAPIKeyService(userRepo, apiKeyRepo, authorizer)
+	sessionService := services.NewSessionService(sessionRepo, refreshTokenRepo, revocationStore, authorizer)
+	oauthService := services.NewOAuthService(oauthClientRepo, authorizer)
+	impersonationService := services.NewImpersonationService(userRepo, impersonationRepo, authorizer, services.ImpersonationSettings{
+		TokenTTL: cfg.ImpersonationTokenTTL,
+	})
+	passwordService := services.NewPasswordService(userRepo, actionTokenRepo, authorizer, passwordPolicy, authService, mailer, services.PasswordResetSettings{
+		TokenTTL: cfg.PasswordResetTTL,
+		ResetURL: cfg.PasswordResetURL,
+	})
 
 	userHandler := handlers.NewUserHandler(userService)
 	orderHandler := handlers.NewOrderHandler(orderService)
 	authHandler := handlers.NewAuthHandler(authService)
+	passwordHandler := handlers.NewPasswordHandler(passwordService)
+	verificationHandler := handlers.NewEmailVerificationHandler(verificationService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+	adminHandler := handlers.NewAdminHandler(authService, impersonationService)
+
+	// Запускаем окончательное удаление пользователей с истёкшим сроком хранения
+	if retention := cfg.DeletedUserRetention(); retention > 0 {
+		retentionJob := services.NewUserRetentionJob(userRepo, services.UserRetentionSettings{
+			Retention:   retention,
+			Interval:    cfg.UserPurgeInterval,
+			OrderPolicy: cfg.UserDeleteOrderPolicy,
+		})
+		go retentionJob.Run(context.Background())
+	}
 
 	// Настраиваем маршруты
-	router := setupRoutes(userHandler, authHandler, orderHandler)
+	router := setupRoutes(userHandler, authHandler, passwordHandler, verificationHandler, mfaHandler, apiKeyHandler, sessionHandler, oauthHandler, adminHandler, orderHandler, revocationStore, apiKeyService)
 
 	// Запускаем сервер
 	if err := router.Run(cfg.ServerPort); err != nil {
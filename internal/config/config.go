@@ -1,22 +1,121 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/iwtcode/user-order-api/internal/mail"
+	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/utils"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// Требования к секрету HS256
+// Оценка энтропии — по частотам символов самого секрета, поэтому
+// повторяющиеся и словарные строки её не проходят
+const (
+	minJWTSecretLength      = 32
+	minJWTSecretEntropyBits = 128
+	// Больший допуск на расхождение часов фактически продлевает жизнь токенов
+	maxJWTLeeway = 5 * time.Minute
+)
+
+// Минимальный объём памяти Argon2id по рекомендациям OWASP, КиБ
+// Меньшее значение в режиме release считается небезопасным
+const minArgon2Memory = 19 * 1024
+
+// Действия, которые можно запретить до подтверждения email (REQUIRE_VERIFIED_EMAIL)
+const (
+	VerifiedEmailForLogin  = "login"
+	VerifiedEmailForOrders = "orders"
+)
+
+// Классы символов, которые можно потребовать в пароле (PASSWORD_REQUIRE_CLASSES)
+const (
+	PasswordClassUpper  = "upper"
+	PasswordClassLower  = "lower"
+	PasswordClassDigit  = "digit"
+	PasswordClassSymbol = "symbol"
+)
+
+// bcrypt учитывает только первые 72 байта пароля, а более длинные пароли отвергает
+const bcryptMaxPasswordBytes = 72
+
 // Структура для хранения конфигурации приложения (строка подключения к БД и порт сервера)
 type Config struct {
 	DBConnectionString string
 	ServerPort         string
 	GinMode            string
 	LogFile            string
+	// Как часто кэш отозванных токенов перечитывается из БД
+	RevocationSyncInterval time.Duration
+	// Алгоритм, ключи, сроки жизни, издатель и аудитория JWT
+	JWT utils.JWTConfig
+	// Способ отправки писем
+	Mail mail.Config
+	// Время жизни токена сброса пароля и адрес страницы сброса, на которую ведёт ссылка из письма
+	PasswordResetTTL time.Duration
+	PasswordResetURL string
+	// Время жизни токена подтверждения email и адрес, на который ведёт ссылка из письма
+	EmailVerificationTTL time.Duration
+	EmailVerificationURL string
+	// Действия, запрещённые до подтверждения email: login, orders
+	RequireVerifiedEmail []string
+	// Название сервиса в приложении-аутентификаторе и время на ввод кода при входе
+	MFAIssuer   string
+	MFATokenTTL time.Duration
+	// Время жизни токена, с которым администратор работает от имени пользователя
+	ImpersonationTokenTTL time.Duration
+	// Порог неудачных попыток входа для аккаунта и для IP-адреса, начальная и предельная блокировка,
+	// окно, после которого счётчик забывается
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginLockout       time.Duration
+	LoginMaxLockout    time.Duration
+	LoginFailureWindow time.Duration
+	// Регистрация, не раскрывающая, занят ли email
+	SignupPrivacyMode bool
+	// Сколько дней хранить мягко удалённых пользователей (0 — хранить бессрочно, по умолчанию) и как часто запускать очистку
+	DeletedUserRetentionDays int
+	UserPurgeInterval        time.Duration
+	// Что делать с заказами удаляемого пользователя: restrict, cascade, anonymize
+	UserDeleteOrderPolicy string
+	// Алгоритм и параметры хеширования паролей
+	PasswordHash utils.PasswordHashConfig
+	// Парольная политика: длина в символах и в байтах, обязательные классы символов,
+	// запрет email и имени в пароле, файл со списком утёкших паролей
+	PasswordMinLength          int
+	PasswordMaxBytes           int
+	PasswordRequireClasses     []string
+	PasswordRejectPersonalInfo bool
+	PasswordBreachedListFile   string
+}
+
+// Проверяет, запрещено ли действие до подтверждения email
+func (c *Config) RequiresVerifiedEmail(action string) bool {
+	return slices.Contains(c.RequireVerifiedEmail, action)
+}
+
+// Срок хранения мягко удалённых пользователей; 0 означает, что они не удаляются окончательно
+func (c *Config) DeletedUserRetention() time.Duration {
+	return time.Duration(c.DeletedUserRetentionDays) * 24 * time.Hour
+}
+
+// Проверяет, обязателен ли в пароле класс символов
+func (c *Config) RequiresPasswordClass(class string) bool {
+	return slices.Contains(c.PasswordRequireClasses, class)
 }
 
 // Функция загружает конфигурацию из .env файла или переменных окружения
+// Возвращает сразу все найденные ошибки конфигурации, объединённые через errors.Join
 func LoadConfig() (*Config, error) {
 	// Пытаемся загрузить .env файл
 	err := godotenv.Load()
@@ -39,13 +138,296 @@ func LoadConfig() (*Config, error) {
 	ginMode := getEnv("GIN_MODE", "debug")
 	logFile := getEnv("LOG_FILE", "")
 
-	// Возвращаем структуру конфигурации
-	return &Config{
+	// Ошибки разбора JWT-настроек не заменяются значениями по умолчанию, а копятся
+	var errs []error
+	jwtConfig := utils.JWTConfig{
+		Algorithm:            getEnv("JWT_ALGORITHM", utils.JWTAlgHS256),
+		Secret:               getEnv("JWT_SECRET", utils.DefaultJWTSecret),
+		KeyID:                getEnv("JWT_KEY_ID", ""),
+		PrivateKeyFile:       getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		VerificationKeyFiles: getListEnv("JWT_VERIFICATION_KEY_FILES"),
+		AccessTokenTTL:       parseDurationEnv("JWT_EXPIRATION", utils.DefaultAccessTokenTTL, &errs),
+		RefreshTokenTTL:      parseDurationEnv("REFRESH_TOKEN_EXPIRATION", utils.DefaultRefreshTokenTTL, &errs),
+		Issuer:               getEnv("JWT_ISSUER", ""),
+		Audience:             getListEnv("JWT_AUDIENCE"),
+		AllowedAlgorithms:    getListEnv("JWT_ALLOWED_ALGORITHMS"),
+		Leeway:               parseDurationEnv("JWT_LEEWAY", utils.DefaultJWTLeeway, &errs),
+	}
+
+	mailConfig := mail.Config{
+		Driver:       getEnv("MAIL_DRIVER", mail.DriverConsole),
+		From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+		FilePath:     getEnv("MAIL_FILE", ""),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+	}
+	revocationSyncInterval := parseDurationEnv("TOKEN_REVOCATION_SYNC_INTERVAL", 30*time.Second, &errs)
+	passwordResetTTL := parseDurationEnv("PASSWORD_RESET_TOKEN_TTL", time.Hour, &errs)
+	passwordResetURL := getEnv("PASSWORD_RESET_URL", "http://localhost:8080/reset-password")
+	emailVerificationTTL := parseDurationEnv("EMAIL_VERIFICATION_TOKEN_TTL", 24*time.Hour, &errs)
+	emailVerificationURL := getEnv("EMAIL_VERIFICATION_URL", "http://localhost:8080/auth/verify-email")
+	mfaTokenTTL := parseDurationEnv("MFA_TOKEN_TTL", 5*time.Minute, &errs)
+	impersonationTokenTTL := parseDurationEnv("IMPERSONATION_TOKEN_TTL", 15*time.Minute, &errs)
+	loginMaxFailures := parseIntEnv("LOGIN_MAX_FAILURES", 5, &errs)
+	loginIPMaxFailures := parseIntEnv("LOGIN_IP_MAX_FAILURES", 20, &errs)
+	loginLockout := parseDurationEnv("LOGIN_LOCKOUT", time.Minute, &errs)
+	loginMaxLockout := parseDurationEnv("LOGIN_MAX_LOCKOUT", time.Hour, &errs)
+	loginFailureWindow := parseDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute, &errs)
+	signupPrivacyMode := parseBoolEnv("SIGNUP_PRIVACY_MODE", false, &errs)
+	deletedUserRetentionDays := parseIntEnv("DELETED_USER_RETENTION_DAYS", 0, &errs)
+	userPurgeInterval := parseDurationEnv("USER_PURGE_INTERVAL", time.Hour, &errs)
+	argon2Memory := parseIntEnv("ARGON2_MEMORY", utils.DefaultArgon2Memory, &errs)
+	argon2Iterations := parseIntEnv("ARGON2_ITERATIONS", utils.DefaultArgon2Iterations, &errs)
+	argon2Parallelism := parseIntEnv("ARGON2_PARALLELISM", utils.DefaultArgon2Parallelism, &errs)
+	// Значения вне диапазона типа обнуляются, чтобы Validate сообщил о них, а не о результате переполнения
+	if argon2Memory < 0 || argon2Memory > math.MaxUint32 {
+		argon2Memory = 0
+	}
+	if argon2Iterations < 0 || argon2Iterations > math.MaxUint32 {
+		argon2Iterations = 0
+	}
+	if argon2Parallelism < 0 || argon2Parallelism > math.MaxUint8 {
+		argon2Parallelism = 0
+	}
+	passwordMinLength := parseIntEnv("PASSWORD_MIN_LENGTH", 8, &errs)
+	passwordMaxBytes := parseIntEnv("PASSWORD_MAX_BYTES", bcryptMaxPasswordBytes, &errs)
+	passwordRejectPersonalInfo := parseBoolEnv("PASSWORD_REJECT_PERSONAL_INFO", true, &errs)
+	passwordHashConfig := utils.PasswordHashConfig{
+		Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", utils.PasswordHashBcrypt),
+		BcryptCost:        parseIntEnv("BCRYPT_COST", bcrypt.DefaultCost, &errs),
+		Argon2Memory:      uint32(argon2Memory),
+		Argon2Iterations:  uint32(argon2Iterations),
+		Argon2Parallelism: uint8(argon2Parallelism),
+	}
+
+	cfg := &Config{
 		DBConnectionString: dsn,
 		ServerPort:         ":" + serverPort,
 		GinMode:            ginMode,
 		LogFile:            logFile,
-	}, nil
+
+		RevocationSyncInterval:   revocationSyncInterval,
+		JWT:                      jwtConfig,
+		Mail:                     mailConfig,
+		PasswordResetTTL:         passwordResetTTL,
+		PasswordResetURL:         passwordResetURL,
+		EmailVerificationTTL:     emailVerificationTTL,
+		EmailVerificationURL:     emailVerificationURL,
+		RequireVerifiedEmail:     getListEnv("REQUIRE_VERIFIED_EMAIL"),
+		MFAIssuer:                getEnv("MFA_ISSUER", "user-order-api"),
+		MFATokenTTL:              mfaTokenTTL,
+		ImpersonationTokenTTL:    impersonationTokenTTL,
+		LoginMaxFailures:         loginMaxFailures,
+		LoginIPMaxFailures:       loginIPMaxFailures,
+		LoginLockout:             loginLockout,
+		LoginMaxLockout:          loginMaxLockout,
+		LoginFailureWindow:       loginFailureWindow,
+		SignupPrivacyMode:        signupPrivacyMode,
+		DeletedUserRetentionDays: deletedUserRetentionDays,
+		UserPurgeInterval:        userPurgeInterval,
+		UserDeleteOrderPolicy:    getEnv("USER_DELETE_ORDER_POLICY", models.OrderPolicyRestrict),
+		PasswordHash:             passwordHashConfig,
+
+		PasswordMinLength:          passwordMinLength,
+		PasswordMaxBytes:           passwordMaxBytes,
+		PasswordRequireClasses:     getListEnv("PASSWORD_REQUIRE_CLASSES"),
+		PasswordRejectPersonalInfo: passwordRejectPersonalInfo,
+		PasswordBreachedListFile:   getEnv("PASSWORD_BREACHED_LIST_FILE", ""),
+	}
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// Проверяет конфигурацию и возвращает все найденные ошибки разом
+// Небезопасные настройки (секрет по умолчанию, слабый секрет, не заданные издатель и аудитория,
+// вывод писем в консоль, слабые параметры хеширования паролей, короткие пароли)
+// в режиме release считаются ошибками, в остальных режимах только выводятся предупреждения
+func (c *Config) Validate() error {
+	var errs, insecure []error
+
+	switch c.JWT.Algorithm {
+	case utils.JWTAlgHS256:
+		switch {
+		case c.JWT.Secret == utils.DefaultJWTSecret:
+			insecure = append(insecure, errors.New("JWT_SECRET is not set, the default secret is used"))
+		case len(c.JWT.Secret) < minJWTSecretLength:
+			insecure = append(insecure, fmt.Errorf("JWT_SECRET must be at least %d characters long", minJWTSecretLength))
+		case secretEntropyBits(c.JWT.Secret) < minJWTSecretEntropyBits:
+			insecure = append(insecure, fmt.Errorf("JWT_SECRET is too predictable: estimated entropy is below %d bits", minJWTSecretEntropyBits))
+		}
+	case utils.JWTAlgRS256, utils.JWTAlgES256, utils.JWTAlgEdDSA:
+		if c.JWT.PrivateKeyFile == "" {
+			errs = append(errs, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for JWT_ALGORITHM=%s", c.JWT.Algorithm))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("JWT_ALGORITHM %q is not supported, use one of HS256, RS256, ES256, EdDSA", c.JWT.Algorithm))
+	}
+
+	for _, alg := range c.JWT.AllowedAlgorithms {
+		if !utils.IsSupportedJWTAlgorithm(alg) {
+			errs = append(errs, fmt.Errorf("JWT_ALLOWED_ALGORITHMS contains unsupported algorithm %q", alg))
+		}
+	}
+	if len(c.JWT.AllowedAlgorithms) > 0 && utils.IsSupportedJWTAlgorithm(c.JWT.Algorithm) && !slices.Contains(c.JWT.AllowedAlgorithms, c.JWT.Algorithm) {
+		errs = append(errs, fmt.Errorf("JWT_ALLOWED_ALGORITHMS must include JWT_ALGORITHM=%s", c.JWT.Algorithm))
+	}
+	if c.JWT.Leeway < 0 {
+		errs = append(errs, errors.New("JWT_LEEWAY must not be negative"))
+	} else if c.JWT.Leeway > maxJWTLeeway {
+		insecure = append(insecure, fmt.Errorf("JWT_LEEWAY is longer than %s", maxJWTLeeway))
+	}
+
+	if c.JWT.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("JWT_EXPIRATION must be positive"))
+	}
+	if c.JWT.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("REFRESH_TOKEN_EXPIRATION must be positive"))
+	} else if c.JWT.RefreshTokenTTL <= c.JWT.AccessTokenTTL {
+		errs = append(errs, errors.New("REFRESH_TOKEN_EXPIRATION must be longer than JWT_EXPIRATION"))
+	}
+
+	if c.JWT.Issuer == "" {
+		insecure = append(insecure, errors.New("JWT_ISSUER is not set"))
+	}
+	if len(c.JWT.Audience) == 0 {
+		insecure = append(insecure, errors.New("JWT_AUDIENCE is not set"))
+	}
+
+	switch c.Mail.Driver {
+	case mail.DriverConsole:
+		// Письма со ссылками сброса пароля попадают в вывод приложения
+		insecure = append(insecure, errors.New("MAIL_DRIVER=console prints emails with secret links to stdout"))
+	case mail.DriverFile:
+		if c.Mail.FilePath == "" {
+			errs = append(errs, errors.New("MAIL_FILE is required for MAIL_DRIVER=file"))
+		}
+	case mail.DriverSMTP:
+		if c.Mail.SMTPHost == "" {
+			errs = append(errs, errors.New("SMTP_HOST is required for MAIL_DRIVER=smtp"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("MAIL_DRIVER %q is not supported, use one of console, file, smtp", c.Mail.Driver))
+	}
+	if c.RevocationSyncInterval <= 0 {
+		errs = append(errs, errors.New("TOKEN_REVOCATION_SYNC_INTERVAL must be positive"))
+	}
+	if c.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("PASSWORD_RESET_TOKEN_TTL must be positive"))
+	}
+	if _, err := url.ParseRequestURI(c.PasswordResetURL); err != nil {
+		errs = append(errs, fmt.Errorf("PASSWORD_RESET_URL is not a valid URL: %w", err))
+	}
+	if c.EmailVerificationTTL <= 0 {
+		errs = append(errs, errors.New("EMAIL_VERIFICATION_TOKEN_TTL must be positive"))
+	}
+	if _, err := url.ParseRequestURI(c.EmailVerificationURL); err != nil {
+		errs = append(errs, fmt.Errorf("EMAIL_VERIFICATION_URL is not a valid URL: %w", err))
+	}
+	if c.MFAIssuer == "" {
+		errs = append(errs, errors.New("MFA_ISSUER must not be empty"))
+	}
+	if c.MFATokenTTL <= 0 {
+		errs = append(errs, errors.New("MFA_TOKEN_TTL must be positive"))
+	}
+	// Отзыв всех токенов пользователя хранится JWT_EXPIRATION: более долгий токен имперсонации его пережил бы
+	if c.ImpersonationTokenTTL <= 0 {
+		errs = append(errs, errors.New("IMPERSONATION_TOKEN_TTL must be positive"))
+	} else if c.JWT.AccessTokenTTL > 0 && c.ImpersonationTokenTTL > c.JWT.AccessTokenTTL {
+		errs = append(errs, errors.New("IMPERSONATION_TOKEN_TTL must not be longer than JWT_EXPIRATION"))
+	}
+	if c.DeletedUserRetentionDays < 0 {
+		errs = append(errs, errors.New("DELETED_USER_RETENTION_DAYS must not be negative"))
+	}
+	if c.UserPurgeInterval <= 0 {
+		errs = append(errs, errors.New("USER_PURGE_INTERVAL must be positive"))
+	}
+	// Заказы обрабатываются по политике только при окончательном удалении: без него cascade и anonymize ничего бы не делали
+	switch c.UserDeleteOrderPolicy {
+	case models.OrderPolicyRestrict:
+	case models.OrderPolicyCascade, models.OrderPolicyAnonymize:
+		if c.DeletedUserRetentionDays == 0 {
+			errs = append(errs, fmt.Errorf("USER_DELETE_ORDER_POLICY %q requires DELETED_USER_RETENTION_DAYS to be positive", c.UserDeleteOrderPolicy))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("USER_DELETE_ORDER_POLICY %q is not supported, use one of restrict, cascade, anonymize", c.UserDeleteOrderPolicy))
+	}
+	if c.LoginMaxFailures < 1 {
+		errs = append(errs, errors.New("LOGIN_MAX_FAILURES must be positive"))
+	}
+	if c.LoginIPMaxFailures < 1 {
+		errs = append(errs, errors.New("LOGIN_IP_MAX_FAILURES must be positive"))
+	}
+	if c.LoginLockout <= 0 {
+		errs = append(errs, errors.New("LOGIN_LOCKOUT must be positive"))
+	} else if c.LoginMaxLockout < c.LoginLockout {
+		errs = append(errs, errors.New("LOGIN_MAX_LOCKOUT must not be shorter than LOGIN_LOCKOUT"))
+	}
+	if c.LoginFailureWindow <= 0 {
+		errs = append(errs, errors.New("LOGIN_FAILURE_WINDOW must be positive"))
+	}
+	switch c.PasswordHash.Algorithm {
+	case utils.PasswordHashBcrypt:
+		if c.PasswordHash.BcryptCost < bcrypt.MinCost || c.PasswordHash.BcryptCost > bcrypt.MaxCost {
+			errs = append(errs, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+		} else if c.PasswordHash.BcryptCost < bcrypt.DefaultCost {
+			insecure = append(insecure, fmt.Errorf("BCRYPT_COST is below %d", bcrypt.DefaultCost))
+		}
+	case utils.PasswordHashArgon2id:
+		if c.PasswordHash.Argon2Iterations < 1 {
+			errs = append(errs, errors.New("ARGON2_ITERATIONS must be positive"))
+		}
+		if c.PasswordHash.Argon2Parallelism < 1 {
+			errs = append(errs, errors.New("ARGON2_PARALLELISM must be between 1 and 255"))
+		}
+		if c.PasswordHash.Argon2Memory < 8*uint32(c.PasswordHash.Argon2Parallelism) {
+			errs = append(errs, errors.New("ARGON2_MEMORY must be at least 8 KiB per ARGON2_PARALLELISM"))
+		} else if c.PasswordHash.Argon2Memory < minArgon2Memory {
+			insecure = append(insecure, fmt.Errorf("ARGON2_MEMORY is below %d KiB", minArgon2Memory))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("PASSWORD_HASH_ALGORITHM %q is not supported, use one of bcrypt, argon2id", c.PasswordHash.Algorithm))
+	}
+	if c.PasswordMinLength < 1 {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be positive"))
+	} else if c.PasswordMinLength < 8 {
+		insecure = append(insecure, errors.New("PASSWORD_MIN_LENGTH is below 8"))
+	}
+	if c.PasswordMaxBytes < c.PasswordMinLength {
+		errs = append(errs, errors.New("PASSWORD_MAX_BYTES must not be less than PASSWORD_MIN_LENGTH"))
+	} else if c.PasswordHash.Algorithm == utils.PasswordHashBcrypt && c.PasswordMaxBytes > bcryptMaxPasswordBytes {
+		errs = append(errs, fmt.Errorf("PASSWORD_MAX_BYTES must not exceed %d with PASSWORD_HASH_ALGORITHM=bcrypt", bcryptMaxPasswordBytes))
+	}
+	for _, class := range c.PasswordRequireClasses {
+		if !slices.Contains([]string{PasswordClassUpper, PasswordClassLower, PasswordClassDigit, PasswordClassSymbol}, class) {
+			errs = append(errs, fmt.Errorf("PASSWORD_REQUIRE_CLASSES contains unsupported class %q, use upper, lower, digit, symbol", class))
+		}
+	}
+	if c.PasswordBreachedListFile != "" {
+		if _, err := os.Stat(c.PasswordBreachedListFile); err != nil {
+			errs = append(errs, fmt.Errorf("PASSWORD_BREACHED_LIST_FILE is not readable: %w", err))
+		}
+	}
+	for _, action := range c.RequireVerifiedEmail {
+		if action != VerifiedEmailForLogin && action != VerifiedEmailForOrders {
+			errs = append(errs, fmt.Errorf("REQUIRE_VERIFIED_EMAIL contains unsupported action %q, use login, orders", action))
+		}
+	}
+
+	if c.GinMode == "release" {
+		errs = append(errs, insecure...)
+	} else {
+		for _, warning := range insecure {
+			utils.Warn("Insecure configuration, not allowed in release mode: %v", warning)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Вспомогательная функция для получения переменной окружения с дефолтным значением
@@ -55,3 +437,75 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// Вспомогательная функция для получения длительности из переменной окружения
+// Ошибка формата добавляется в errs
+func parseDurationEnv(key string, fallback time.Duration, errs *[]error) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	dur, err := time.ParseDuration(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s has invalid duration %q: %w", key, value, err))
+		return fallback
+	}
+	return dur
+}
+
+// Вспомогательная функция для получения целого числа из переменной окружения
+// Ошибка формата добавляется в errs
+func parseIntEnv(key string, fallback int, errs *[]error) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s has invalid number %q: %w", key, value, err))
+		return fallback
+	}
+	return n
+}
+
+// Вспомогательная функция для получения логического значения из переменной окружения
+// Ошибка формата добавляется в errs
+func parseBoolEnv(key string, fallback bool, errs *[]error) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s has invalid boolean %q: %w", key, value, err))
+		return fallback
+	}
+	return b
+}
+
+// Вспомогательная функция для получения списка из переменной окружения (значения через запятую)
+func getListEnv(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Оценивает энтропию секрета в битах по энтропии Шеннона его символов
+func secretEntropyBits(secret string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range secret {
+		counts[r]++
+		total++
+	}
+	var perChar float64
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		perChar -= p * math.Log2(p)
+	}
+	return perChar * float64(total)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Хэндлер для административных операций (REST API)
+type AdminHandler struct {
+	authService          services.AuthService
+	impersonationService services.ImpersonationService
+}
+
+// Структура ответа с токеном имперсонации
+// Refresh-токен не выдаётся, user_id — пользователь, от имени которого действует токен
+type ImpersonationTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+	UserID      uint   `json:"user_id"`
+}
+
+// Конструктор хэндлера административных операций
+func NewAdminHandler(authService services.AuthService, impersonationService services.ImpersonationService) *AdminHandler {
+	return &AdminHandler{authService: authService, impersonationService: impersonationService}
+}
+
+// UnlockUser godoc
+// @Summary Снять блокировку входа
+// @Description Снимает блокировку входа, наложенную на аккаунт после серии неудачных попыток, и обнуляет счётчик. Доступно только администратору
+// @Tags admin
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 204 {string} string ""
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/unlock [post]
+// @Security BearerAuth
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	// Получение и проверка ID
+	idParam := c.Param("id")
+	userID, err := strconv.Atoi(idParam)
+	if err != nil || userID < 1 {
+		utils.Warn("Invalid user ID param during unlock: %s", idParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Вызов бизнес-логики снятия блокировки
+	if err := h.authService.UnlockAccount(c.Request.Context(), uint(userID)); err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied during unlock: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: administrator role required"})
+			return
+		}
+		if errors.Is(err, services.ErrUserNotFound) {
+			utils.Warn("User not found for unlock: id=%d", userID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		utils.Error("Unlock failed for user id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	utils.Info("User login unlocked: id=%d by admin %d", userID, c.GetUint("user_id"))
+	c.Status(http.StatusNoContent)
+}
+
+// ImpersonateUser godoc
+// @Summary Войти от имени пользователя
+// @Description Выдаёт администратору короткоживущий access-токен пользователя с claim act, в котором указан администратор. Причина и выдача токена записываются в журнал, запросы по токену помечаются в логах. По такому токену нельзя менять пароль, второй фактор и API-ключи пользователя и завершать все его входы. Администраторов имперсонировать нельзя
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param request body models.ImpersonateRequest true "Причина имперсонации"
+// @Success 200 {object} ImpersonationTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/impersonate [post]
+// @Security BearerAuth
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	// Получение и проверка ID
+	idParam := c.Param("id")
+	userID, err := strconv.Atoi(idParam)
+	if err != nil || userID < 1 {
+		utils.Warn("Invalid user ID param during impersonation: %s", idParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req models.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Вызов бизнес-логики имперсонации
+	token, err := h.impersonationService.Impersonate(c.Request.Context(), uint(userID), req.Reason, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied during impersonation: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: administrator role required"})
+			return
+		}
+		if errors.Is(err, services.ErrImpersonationNotAllowed) {
+			utils.Warn("Impersonation rejected: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "This user cannot be impersonated"})
+			return
+		}
+		if errors.Is(err, services.ErrUserNotFound) {
+			utils.Warn("User not found for impersonation: id=%d", userID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		utils.Error("Impersonation failed for user id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to impersonate user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ImpersonationTokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   token.ExpiresIn,
+		Scope:       models.FormatScopes(token.Scopes),
+		UserID:      uint(userID),
+	})
+}
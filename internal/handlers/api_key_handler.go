@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Хэндлер для управления персональными API-ключами (REST API)
+type APIKeyHandler struct {
+	apiKeyService services.APIKeyService
+}
+
+// Структура ответа на создание API-ключа
+// Ключ показывается один раз, в списке ключей его нет
+type CreatedAPIKeyResponse struct {
+	models.APIKeyResponse
+	Key string `json:"key"`
+}
+
+// Конструктор хэндлера API-ключей
+func NewAPIKeyHandler(apiKeyService services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// CreateAPIKey godoc
+// @Summary Создать API-ключ
+// @Description Создаёт персональный API-ключ для передачи в заголовке Authorization: ApiKey <ключ>. Ключ показывается один раз. Области действия и срок действия необязательны; ключ получает только области действия токена, которым его создают, по умолчанию — все его области. Создать ключ можно только для себя и не по API-ключу
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param input body models.CreateAPIKeyRequest true "Название, области действия и срок действия ключа"
+// @Success 201 {object} CreatedAPIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/api-keys [post]
+// @Security BearerAuth
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "API key")
+	if !ok {
+		return
+	}
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during API key creation: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	created, err := h.apiKeyService.CreateAPIKey(c.Request.Context(), userID, &req)
+	if err != nil {
+		if respondScopeError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidAPIKeyExpiry) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "API key expiry must be in the future"})
+			return
+		}
+		respondAPIKeyError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreatedAPIKeyResponse{APIKeyResponse: models.BuildAPIKeyResponse(created.APIKey), Key: created.Key})
+}
+
+// ListAPIKeys godoc
+// @Summary Получить API-ключи пользователя
+// @Description Возвращает действующие и истёкшие, но не отозванные API-ключи пользователя без самих ключей
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/api-keys [get]
+// @Security BearerAuth
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "API key")
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		respondAPIKeyError(c, err, "Failed to fetch API keys")
+		return
+	}
+
+	respKeys := make([]models.APIKeyResponse, len(keys))
+	for i, k := range keys {
+		respKeys[i] = models.BuildAPIKeyResponse(&k)
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": respKeys})
+}
+
+// RevokeAPIKey godoc
+// @Summary Отозвать API-ключ
+// @Description Отзывает API-ключ пользователя; запросы с ним перестают проходить сразу
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param key_id path int true "ID API-ключа"
+// @Success 204 {string} string ""
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/api-keys/{key_id} [delete]
+// @Security BearerAuth
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "API key")
+	if !ok {
+		return
+	}
+	keyParam := c.Param("key_id")
+	keyID, err := strconv.Atoi(keyParam)
+	if err != nil || keyID < 1 {
+		utils.Warn("Invalid API key ID param: %s", keyParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(c.Request.Context(), userID, uint(keyID)); err != nil {
+		respondAPIKeyError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Вспомогательная функция для ответа на ошибку сервиса API-ключей
+func respondAPIKeyError(c *gin.Context, err error, failure string) {
+	if errors.Is(err, services.ErrForbidden) {
+		utils.Warn("Access denied in API key request: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you cannot manage API keys of this account"})
+		return
+	}
+	if errors.Is(err, services.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	utils.Error("%s: %v", failure, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+}
@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/services"
 	"github.com/iwtcode/user-order-api/internal/utils"
 )
@@ -16,9 +20,100 @@ type AuthHandler struct {
 
 // Структура запроса на вход
 // Используется для валидации данных при логине
+// Scopes — запрошенные области действия токена; если не переданы, выдаются все
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email    string   `json:"email" binding:"required,email"`
+	Password string   `json:"password" binding:"required"`
+	Scopes   []string `json:"scopes"`
+}
+
+// Структура запроса на второй шаг входа
+// Code — код из приложения-аутентификатора или код восстановления
+type LoginMFARequest struct {
+	MFAToken string   `json:"mfa_token" binding:"required"`
+	Code     string   `json:"code" binding:"required"`
+	Scopes   []string `json:"scopes"`
+}
+
+// Структура запроса на обновление токенов
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Структура запроса на выход
+// Refresh-токен необязателен: если он передан, отзывается и он
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Структура ответа с парой токенов
+// Поле token содержит access-токен, scope — его области действия через пробел
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+// Структура ответа на вход при включённой двухфакторной аутентификации
+// mfa_token вместе с кодом передаётся в POST /auth/login/mfa
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Вспомогательная функция для формирования ответа с парой токенов
+func buildTokenResponse(tokens *services.TokenPair) TokenResponse {
+	return TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    tokens.ExpiresIn,
+		Scope:        models.FormatScopes(tokens.Scopes),
+	}
+}
+
+// Вспомогательная функция для ответа на ошибку в запрошенных областях действия
+// Неподдерживаемая область даёт 422, область сверх прав вызывающего — 403
+// Возвращает false, если ошибка не связана с областями действия
+func respondScopeError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrInvalidScope) {
+		utils.Warn("Unsupported scope requested: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Unsupported scope", "details": []string{err.Error()}})
+		return true
+	}
+	if errors.Is(err, services.ErrScopeNotGranted) {
+		utils.Warn("Scope exceeding caller's scopes requested: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: requested scope exceeds the scopes of your token"})
+		return true
+	}
+	return false
+}
+
+// Вспомогательная функция для получения данных клиента, которые сохраняются в сессии
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+// Вспомогательная функция для ответа на блокировку входа после неудачных попыток
+// Блокировка аккаунта даёт 423, блокировка IP-адреса — 429; в заголовке Retry-After — секунды до снятия
+// Возвращает false, если ошибка не связана с блокировкой
+func respondLoginLocked(c *gin.Context, err error) bool {
+	var locked *services.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	if errors.Is(err, services.ErrAccountLocked) {
+		utils.Warn("Login attempt for locked account from %s", c.ClientIP())
+		c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked due to too many failed login attempts"})
+		return true
+	}
+	utils.Warn("Too many login attempts from %s", c.ClientIP())
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many login attempts, try again later"})
+	return true
 }
 
 // Конструктор хэндлера авторизации
@@ -28,14 +123,18 @@ func NewAuthHandler(authService services.AuthService) *AuthHandler {
 
 // Login godoc
 // @Summary Вход пользователя
-// @Description Аутентификация пользователя по email и паролю
+// @Description Аутентификация пользователя по email и паролю. Возвращает короткоживущий access-токен и refresh-токен; вход открывает сессию с User-Agent и IP-адресом клиента (GET /users/{id}/sessions). Необязательное поле scopes ограничивает области действия токена (users:read, users:write, orders:read, orders:write), по умолчанию выдаются все; неизвестная область даёт 422. Если включена двухфакторная аутентификация, возвращает 202 с mfa_token для POST /auth/login/mfa, области действия передаются на втором шаге. После серии неудачных попыток вход временно блокируется: 423 для аккаунта, 429 для IP-адреса, время до снятия — в заголовке Retry-After
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param input body LoginRequest true "Данные для входа"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} TokenResponse
+// @Success 202 {object} MFAChallengeResponse
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 422 {object} map[string]interface{}
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -48,8 +147,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// Вызов бизнес-логики авторизации
-	token, err := h.authService.Login(c.Request.Context(), req.Email, req.Password)
+	result, err := h.authService.Login(c.Request.Context(), req.Email, req.Password, clientInfo(c), req.Scopes)
 	if err != nil {
+		if respondLoginLocked(c, err) || respondScopeError(c, err) {
+			return
+		}
 		status := http.StatusInternalServerError
 		if errors.Is(err, services.ErrInvalidCredentials) {
 			utils.Warn("Invalid credentials for email: %s", req.Email)
@@ -57,12 +159,212 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			c.JSON(status, gin.H{"error": "Invalid email or password"})
 			return
 		}
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			utils.Warn("Login with unverified email: %s", req.Email)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email is not verified"})
+			return
+		}
 		utils.Error("Login failed for email %s: %v", req.Email, err)
 		c.JSON(status, gin.H{"error": "Login failed"})
 		return
 	}
 
+	if result.MFA != nil {
+		utils.Info("Password accepted, waiting for second factor: %s", req.Email)
+		c.JSON(http.StatusAccepted, MFAChallengeResponse{MFARequired: true, MFAToken: result.MFA.Token, ExpiresIn: result.MFA.ExpiresIn})
+		return
+	}
+
 	utils.Info("User logged in: %s", req.Email)
 	// Формирование и отправка ответа
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, buildTokenResponse(result.Tokens))
+}
+
+// LoginMFA godoc
+// @Summary Второй шаг входа
+// @Description Обменивает mfa_token, полученный при входе, и код из приложения-аутентификатора или код восстановления на пару токенов. Необязательное поле scopes ограничивает области действия токена. После нескольких неверных кодов mfa_token становится недействительным
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body LoginMFARequest true "Токен второго шага и код"
+// @Success 200 {object} TokenResponse
+// @Failure 401 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	// Валидация и разбор запроса
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during two-factor login: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	// Вызов бизнес-логики второго шага
+	tokens, err := h.authService.LoginMFA(c.Request.Context(), req.MFAToken, req.Code, clientInfo(c), req.Scopes)
+	if err != nil {
+		if respondLoginLocked(c, err) || respondScopeError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidMFAToken) {
+			utils.Warn("Invalid or expired mfa token")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			utils.Warn("Invalid two-factor code during login")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor authentication code"})
+			return
+		}
+		utils.Error("Two-factor login failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		return
+	}
+
+	utils.Info("User logged in with second factor")
+	c.JSON(http.StatusOK, buildTokenResponse(tokens))
+}
+
+// Refresh godoc
+// @Summary Обновить токены
+// @Description Обменивает refresh-токен на новую пару токенов. Старый refresh-токен становится недействительным; его повторное использование отзывает все токены, полученные от того же входа, и завершает его сессию. Обновление отмечает активность сессии и её IP-адрес
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body RefreshRequest true "Refresh-токен"
+// @Success 200 {object} TokenResponse
+// @Failure 401 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	// Валидация и разбор запроса
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during token refresh: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	// Вызов бизнес-логики ротации токенов
+	tokens, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			utils.Warn("Refresh token reuse detected, token family revoked: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used, please log in again"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			utils.Warn("Invalid or expired refresh token")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		utils.Error("Token refresh failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token refresh failed"})
+		return
+	}
+
+	utils.Info("Tokens refreshed")
+	// Формирование и отправка ответа
+	c.JSON(http.StatusOK, buildTokenResponse(tokens))
+}
+
+// Logout godoc
+// @Summary Выход из системы
+// @Description Отзывает текущий access-токен и завершает его сессию; если передан refresh-токен, отзывается и он
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body LogoutRequest false "Refresh-токен"
+// @Success 204 {string} string ""
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout [post]
+// @Security BearerAuth
+func (h *AuthHandler) Logout(c *gin.Context) {
+	// Получаем данные токена из JWT (middleware)
+	userIDValue, exists := c.Get("user_id")
+	if !exists {
+		utils.Warn("User ID not found in token during logout")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+	userID, ok := userIDValue.(uint)
+	if !ok {
+		utils.Error("Invalid user ID in token during logout")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		return
+	}
+	jti := c.GetString("jti")
+	expiresAt := c.GetTime("token_expires_at")
+
+	// Тело запроса необязательно
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.Warn("Invalid request body during logout: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	// Вызов бизнес-логики выхода
+	if err := h.authService.Logout(c.Request.Context(), userID, jti, expiresAt, req.RefreshToken); err != nil {
+		utils.Error("Logout failed for user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+		return
+	}
+
+	utils.Info("User logged out: user_id=%d", userID)
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+// @Summary Выход со всех устройств
+// @Description Отзывает все access- и refresh-токены пользователя, включая текущий, и завершает все его сессии
+// @Tags auth
+// @Produce json
+// @Success 204 {string} string ""
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout-all [post]
+// @Security BearerAuth
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	// Получаем user_id из JWT (middleware)
+	userIDValue, exists := c.Get("user_id")
+	if !exists {
+		utils.Warn("User ID not found in token during logout from all devices")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+	userID, ok := userIDValue.(uint)
+	if !ok {
+		utils.Error("Invalid user ID in token during logout from all devices")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		return
+	}
+
+	// Вызов бизнес-логики выхода
+	if err := h.authService.LogoutAll(c.Request.Context(), userID); err != nil {
+		utils.Error("Logout from all devices failed for user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+		return
+	}
+
+	utils.Info("User logged out from all devices: user_id=%d", userID)
+	c.Status(http.StatusNoContent)
+}
+
+// JWKS godoc
+// @Summary Публичные ключи подписи токенов
+// @Description Возвращает публичные ключи в формате JWKS (RFC 7517), которыми другие сервисы проверяют access-токены. Во время ротации содержит и прежние ключи. При подписи HS256 список пуст
+// @Tags auth
+// @Produce json
+// @Success 200 {object} utils.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	// Ключи меняются только при перезапуске, поэтому ответ можно кэшировать
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicJWKS())
 }
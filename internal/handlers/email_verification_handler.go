@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Хэндлер для подтверждения email (REST API)
+type EmailVerificationHandler struct {
+	verificationService services.EmailVerificationService
+}
+
+// Структура запроса на повторную отправку письма подтверждения
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// Конструктор хэндлера подтверждения email
+func NewEmailVerificationHandler(verificationService services.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{verificationService: verificationService}
+}
+
+// VerifyEmail godoc
+// @Summary Подтвердить email
+// @Description Подтверждает email по одноразовому токену из письма, отправленного при регистрации
+// @Tags auth
+// @Produce json
+// @Param token query string true "Токен подтверждения"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify-email [get]
+func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		utils.Warn("Email verification without token")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing verification token"})
+		return
+	}
+
+	// Вызов бизнес-логики подтверждения
+	if err := h.verificationService.VerifyEmail(c.Request.Context(), token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			utils.Warn("Invalid or expired email verification token")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired email verification token"})
+			return
+		}
+		utils.Error("Email verification failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	utils.Info("Email verified")
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification godoc
+// @Summary Повторно отправить письмо подтверждения
+// @Description Отправляет новую ссылку для подтверждения email. Ответ не зависит от того, зарегистрирован ли email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body ResendVerificationRequest true "Email пользователя"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify-email/resend [post]
+func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
+	// Валидация и разбор запроса
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during verification resend: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	// Вызов бизнес-логики повторной отправки
+	if err := h.verificationService.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		utils.Error("Verification resend failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered and not verified, a verification link has been sent"})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Хэндлер для управления двухфакторной аутентификацией (REST API)
+type MFAHandler struct {
+	mfaService services.MFAService
+}
+
+// Структура запроса с кодом из приложения-аутентификатора или кодом восстановления
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// Структура ответа на начало подключения TOTP
+// provisioning_uri кодируется в QR-код для приложения-аутентификатора
+type TOTPEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// Структура ответа с кодами восстановления
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Конструктор хэндлера двухфакторной аутентификации
+func NewMFAHandler(mfaService services.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+// EnrollTOTP godoc
+// @Summary Начать подключение TOTP
+// @Description Создаёт секрет TOTP и URI otpauth:// для QR-кода. Двухфакторная аутентификация включится после подтверждения кодом. Доступно только для своего аккаунта
+// @Tags mfa
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} TOTPEnrollmentResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/mfa/totp [post]
+// @Security BearerAuth
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "MFA")
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.mfaService.EnrollTOTP(c.Request.Context(), userID)
+	if err != nil {
+		respondMFAError(c, err, "Failed to start two-factor enrollment")
+		return
+	}
+
+	utils.Info("TOTP enrollment started: id=%d", userID)
+	c.JSON(http.StatusOK, TOTPEnrollmentResponse{Secret: enrollment.Secret, ProvisioningURI: enrollment.ProvisioningURI})
+}
+
+// ConfirmTOTP godoc
+// @Summary Подтвердить подключение TOTP
+// @Description Включает двухфакторную аутентификацию по первому коду из приложения и возвращает коды восстановления. Коды показываются один раз
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param input body MFACodeRequest true "Код из приложения"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/mfa/totp/confirm [post]
+// @Security BearerAuth
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "MFA")
+	if !ok {
+		return
+	}
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during TOTP confirmation: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP godoc
+// @Summary Отключить TOTP
+// @Description Отключает двухфакторную аутентификацию по коду из приложения или коду восстановления и удаляет коды восстановления
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param input body MFACodeRequest true "Код из приложения или код восстановления"
+// @Success 204 {string} string ""
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/mfa/totp [delete]
+// @Security BearerAuth
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "MFA")
+	if !ok {
+		return
+	}
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during TOTP disabling: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.mfaService.DisableTOTP(c.Request.Context(), userID, req.Code); err != nil {
+		respondMFAError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Вспомогательная функция для получения ID пользователя из path
+// context — вид запроса для лога, например "MFA"; при неверном ID отвечает 400
+func parseUserIDParam(c *gin.Context, context string) (uint, bool) {
+	idParam := c.Param("id")
+	userID, err := strconv.Atoi(idParam)
+	if err != nil || userID < 1 {
+		utils.Warn("Invalid user ID param in %s request: %s", context, idParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// Вспомогательная функция для ответа на ошибку сервиса двухфакторной аутентификации
+func respondMFAError(c *gin.Context, err error, failure string) {
+	if errors.Is(err, services.ErrForbidden) {
+		utils.Warn("Access denied in MFA request: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only manage two-factor authentication of your own account"})
+		return
+	}
+	if errors.Is(err, services.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidMFACode) {
+		utils.Warn("Invalid two-factor code in MFA request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor authentication code"})
+		return
+	}
+	if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if errors.Is(err, services.ErrMFANotEnrolled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor enrollment has not been started"})
+		return
+	}
+	if errors.Is(err, services.ErrMFANotEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	utils.Error("%s: %v", failure, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Хэндлер OAuth2 для межсервисных вызовов (REST API)
+// Ошибки эндпоинта токена следуют RFC 6749: код ошибки в поле error и пояснение в error_description
+type OAuthHandler struct {
+	oauthService services.OAuthService
+}
+
+// Структура ответа с токеном клиента (RFC 6749, раздел 5.1)
+type ClientTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// Структура ответа на регистрацию клиента OAuth2
+// Секрет показывается один раз, в списке клиентов его нет
+type RegisteredOAuthClientResponse struct {
+	models.OAuthClientResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+// Конструктор хэндлера OAuth2
+func NewOAuthHandler(oauthService services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthService}
+}
+
+// Вспомогательная функция для ответа с ошибкой OAuth2
+func respondOAuthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// Token godoc
+// @Summary Получить токен клиента OAuth2
+// @Description Выдаёт access-токен зарегистрированному клиенту (внутреннему сервису) по grant client_credentials (RFC 6749, раздел 4.4). Клиент передаёт client_id и client_secret в заголовке Authorization: Basic или в полях формы. Необязательное поле scope сужает области действия токена до подмножества зарегистрированных у клиента, по умолчанию выдаются все. Субъект токена — клиент, а не пользователь; refresh-токен не выдаётся
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "Тип grant, только client_credentials"
+// @Param scope formData string false "Области действия через пробел"
+// @Param client_id formData string false "Идентификатор клиента, если не передан в заголовке"
+// @Param client_secret formData string false "Секрет клиента, если не передан в заголовке"
+// @Success 200 {object} ClientTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /oauth/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	// Токены не должны кэшироваться (RFC 6749, раздел 5.1)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	grantType := c.PostForm("grant_type")
+	if grantType == "" {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	}
+	if grantType != "client_credentials" {
+		utils.Warn("Unsupported OAuth grant type: %s", grantType)
+		respondOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "Only client_credentials grant is supported")
+		return
+	}
+
+	clientID, clientSecret, ok := clientCredentials(c)
+	if !ok {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", "Client credentials must be passed either in the Authorization header or in the form")
+		return
+	}
+
+	token, err := h.oauthService.IssueClientToken(c.Request.Context(), clientID, clientSecret, models.ParseScopes(c.PostForm("scope")))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidClient) {
+			utils.Warn("Invalid OAuth client credentials: client_id=%s", clientID)
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+			respondOAuthError(c, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+			return
+		}
+		if errors.Is(err, services.ErrInvalidScope) || errors.Is(err, services.ErrScopeNotGranted) {
+			utils.Warn("Invalid scope requested by OAuth client %s: %v", clientID, err)
+			respondOAuthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+			return
+		}
+		utils.Error("Failed to issue token to OAuth client %s: %v", clientID, err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "Failed to issue token")
+		return
+	}
+
+	utils.Info("Token issued to OAuth client: %s", clientID)
+	c.JSON(http.StatusOK, ClientTokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   token.ExpiresIn,
+		Scope:       models.FormatScopes(token.Scopes),
+	})
+}
+
+// Вспомогательная функция для получения учётных данных клиента
+// В заголовке Basic значения закодированы как в форме (RFC 6749, раздел 2.3.1).
+// Возвращает false, если данные не переданы, переданы неполностью или сразу двумя способами
+func clientCredentials(c *gin.Context) (string, string, bool) {
+	formID, formSecret := c.PostForm("client_id"), c.PostForm("client_secret")
+	basicID, basicSecret, hasBasic := c.Request.BasicAuth()
+	if hasBasic {
+		if formID != "" || formSecret != "" {
+			return "", "", false
+		}
+		id, errID := url.QueryUnescape(basicID)
+		secret, errSecret := url.QueryUnescape(basicSecret)
+		if errID != nil || errSecret != nil || id == "" {
+			return "", "", false
+		}
+		return id, secret, true
+	}
+	if formID == "" || formSecret == "" {
+		return "", "", false
+	}
+	return formID, formSecret, true
+}
+
+// CreateOAuthClient godoc
+// @Summary Зарегистрировать клиента OAuth2
+// @Description Регистрирует внутренний сервис как клиента OAuth2 с набором областей действия. Секрет клиента показывается один раз. Доступно только администратору и не по API-ключу
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param input body models.CreateOAuthClientRequest true "Название и области действия клиента"
+// @Success 201 {object} RegisteredOAuthClientResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /admin/oauth-clients [post]
+// @Security BearerAuth
+func (h *OAuthHandler) CreateOAuthClient(c *gin.Context) {
+	var req models.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during OAuth client registration: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	registered, err := h.oauthService.RegisterClient(c.Request.Context(), &req)
+	if err != nil {
+		if respondScopeError(c, err) {
+			return
+		}
+		respondOAuthClientError(c, err, "Failed to register OAuth client")
+		return
+	}
+
+	c.JSON(http.StatusCreated, RegisteredOAuthClientResponse{OAuthClientResponse: models.BuildOAuthClientResponse(registered.Client), ClientSecret: registered.Secret})
+}
+
+// ListOAuthClients godoc
+// @Summary Получить клиентов OAuth2
+// @Description Возвращает действующих клиентов OAuth2 без секретов. Доступно только администратору
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/oauth-clients [get]
+// @Security BearerAuth
+func (h *OAuthHandler) ListOAuthClients(c *gin.Context) {
+	clients, err := h.oauthService.ListClients(c.Request.Context())
+	if err != nil {
+		respondOAuthClientError(c, err, "Failed to fetch OAuth clients")
+		return
+	}
+
+	respClients := make([]models.OAuthClientResponse, len(clients))
+	for i, client := range clients {
+		respClients[i] = models.BuildOAuthClientResponse(&client)
+	}
+	c.JSON(http.StatusOK, gin.H{"oauth_clients": respClients})
+}
+
+// RevokeOAuthClient godoc
+// @Summary Отозвать клиента OAuth2
+// @Description Отзывает клиента OAuth2: новые токены ему больше не выдаются, уже выданные действуют до истечения срока. Доступно только администратору
+// @Tags admin
+// @Produce json
+// @Param id path int true "ID клиента"
+// @Success 204 {string} string ""
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/oauth-clients/{id} [delete]
+// @Security BearerAuth
+func (h *OAuthHandler) RevokeOAuthClient(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil || id < 1 {
+		utils.Warn("Invalid OAuth client ID param: %s", idParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth client ID"})
+		return
+	}
+
+	if err := h.oauthService.RevokeClient(c.Request.Context(), uint(id)); err != nil {
+		respondOAuthClientError(c, err, "Failed to revoke OAuth client")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Вспомогательная функция для ответа на ошибку управления клиентами OAuth2
+func respondOAuthClientError(c *gin.Context, err error, failure string) {
+	if errors.Is(err, services.ErrForbidden) {
+		utils.Warn("Access denied in OAuth client request: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: administrator role required"})
+		return
+	}
+	if errors.Is(err, services.ErrOAuthClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OAuth client not found"})
+		return
+	}
+	utils.Error("%s: %v", failure, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+}
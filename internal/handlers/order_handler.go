@@ -38,33 +38,15 @@ func NewOrderHandler(orderService services.OrderService) *OrderHandler {
 // @Router /users/{id}/orders [post]
 // @Security BearerAuth
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
-	// Получаем user_id из JWT (middleware)
-	userIDValue, exists := c.Get("user_id")
-	if !exists {
-		utils.Warn("User ID not found in token during order creation")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
-		return
-	}
-	userID, ok := userIDValue.(uint)
-	if !ok {
-		utils.Error("Invalid user ID in token during order creation")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
-		return
-	}
-	// Проверяем, совпадает ли user_id из токена с id из path
+	// Получаем и проверяем id из path; права вызывающего проверяет сервис
 	idParam := c.Param("id")
-	var pathID uint
-	_, err := fmt.Sscanf(idParam, "%d", &pathID)
-	if err != nil || pathID == 0 {
+	var userID uint
+	_, err := fmt.Sscanf(idParam, "%d", &userID)
+	if err != nil || userID == 0 {
 		utils.Warn("Invalid user ID in path during order creation: %s", idParam)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID in path"})
 		return
 	}
-	if userID != pathID {
-		utils.Warn("Access denied: user %d tried to create order for user %d", userID, pathID)
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only operate with your own orders"})
-		return
-	}
 	// Валидация и разбор запроса
 	var req models.OrderCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -85,11 +67,21 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	resultChan := h.orderService.CreateOrder(c.Request.Context(), userID, &req)
 	result := <-resultChan
 	if result.Err != nil {
+		if errors.Is(result.Err, services.ErrForbidden) {
+			utils.Warn("Access denied during order creation: %v", result.Err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only operate with your own orders"})
+			return
+		}
 		if errors.Is(result.Err, services.ErrOrderUserNotFound) {
 			utils.Warn("Order creation failed: user not found (user_id=%d)", userID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
+		if errors.Is(result.Err, services.ErrEmailNotVerified) {
+			utils.Warn("Order creation rejected: email of user_id=%d is not verified", userID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email is not verified"})
+			return
+		}
 		utils.Error("Failed to create order for user_id=%d: %v", userID, result.Err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
@@ -103,7 +95,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 // GetOrdersByUserID godoc
 // @Summary Получить заказы пользователя
-// @Description Возвращает список заказов пользователя по его ID. Пользователь может просматривать только свои заказы.
+// @Description Возвращает список заказов пользователя по его ID. Пользователь может просматривать только свои заказы, администратор — заказы любого пользователя.
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -115,36 +107,23 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 // @Router /users/{id}/orders [get]
 // @Security BearerAuth
 func (h *OrderHandler) GetOrdersByUserID(c *gin.Context) {
-	// Получаем user_id из JWT (middleware)
-	userIDValue, exists := c.Get("user_id")
-	if !exists {
-		utils.Warn("User ID not found in token during order list fetch")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
-		return
-	}
-	userID, ok := userIDValue.(uint)
-	if !ok {
-		utils.Error("Invalid user ID in token during order list fetch")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
-		return
-	}
-	// Проверяем, совпадает ли user_id из токена с id из path
+	// Получаем и проверяем id из path; права вызывающего проверяет сервис
 	idParam := c.Param("id")
-	var pathID uint
-	_, err := fmt.Sscanf(idParam, "%d", &pathID)
-	if err != nil || pathID == 0 {
+	var userID uint
+	_, err := fmt.Sscanf(idParam, "%d", &userID)
+	if err != nil || userID == 0 {
 		utils.Warn("Invalid user ID in path during order list fetch: %s", idParam)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID in path"})
 		return
 	}
-	if userID != pathID {
-		utils.Warn("Access denied: user %d tried to view orders for user %d", userID, pathID)
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only view your own orders"})
-		return
-	}
 	// Вызов бизнес-логики
 	orders, err := h.orderService.ListOrdersByUserID(c.Request.Context(), userID)
 	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied during order list fetch: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only view your own orders"})
+			return
+		}
 		if errors.Is(err, services.ErrOrderUserNotFound) {
 			utils.Warn("Order list fetch failed: user not found (user_id=%d)", userID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
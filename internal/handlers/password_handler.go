@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Хэндлер для управления паролями (REST API)
+type PasswordHandler struct {
+	passwordService services.PasswordService
+}
+
+// Структура запроса на сброс пароля
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// Структура запроса на установку нового пароля по токену сброса
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// Структура запроса на смену пароля
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// Конструктор хэндлера паролей
+func NewPasswordHandler(passwordService services.PasswordService) *PasswordHandler {
+	return &PasswordHandler{passwordService: passwordService}
+}
+
+// Вспомогательная функция для ответа на ошибку разбора запроса
+// Ошибки валидации полей дают 422 со списком полей, прочие ошибки разбора — 400
+func respondBindError(c *gin.Context, err error) {
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		details := make([]string, 0, len(ve))
+		for _, fe := range ve {
+			details = append(details, fe.Field()+": "+fe.Tag())
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "details": details})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+}
+
+// Вспомогательная функция для ответа на нарушение парольной политики
+// Возвращает false, если ошибка не относится к политике и её нужно обработать дальше
+func respondPasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Password does not meet the policy", "violations": policyErr.Violations})
+	return true
+}
+
+// ForgotPassword godoc
+// @Summary Запросить сброс пароля
+// @Description Отправляет на email ссылку с одноразовым токеном для сброса пароля. Ответ не зависит от того, зарегистрирован ли email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body ForgotPasswordRequest true "Email пользователя"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /auth/password/forgot [post]
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	// Валидация и разбор запроса
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during password reset request: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	// Вызов бизнес-логики отправки ссылки
+	if err := h.passwordService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		utils.Error("Password reset request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password reset request"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Сбросить пароль
+// @Description Устанавливает новый пароль по одноразовому токену из письма. Пароль проверяется парольной политикой, при нарушении возвращается 422 со списком нарушенных правил, а токен остаётся действительным. После сброса все входы пользователя завершаются
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body ResetPasswordRequest true "Токен сброса и новый пароль"
+// @Success 204 {string} string ""
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /auth/password/reset [post]
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	// Валидация и разбор запроса
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during password reset: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	// Вызов бизнес-логики сброса пароля
+	if err := h.passwordService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		if respondPasswordPolicyError(c, err) {
+			utils.Warn("Weak password rejected during password reset: %v", err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			utils.Warn("Invalid or expired password reset token")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired password reset token"})
+			return
+		}
+		utils.Error("Password reset failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	utils.Info("Password reset completed")
+	c.Status(http.StatusNoContent)
+}
+
+// ChangePassword godoc
+// @Summary Сменить пароль
+// @Description Меняет пароль пользователя по текущему паролю. Сменить можно только свой пароль. Новый пароль проверяется парольной политикой, при нарушении возвращается 422 со списком нарушенных правил. Все прочие входы пользователя завершаются, в ответе — новая пара токенов для текущего
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param input body ChangePasswordRequest true "Текущий и новый пароль"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/password [put]
+// @Security BearerAuth
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	// Получение и проверка ID
+	idParam := c.Param("id")
+	userID, err := strconv.Atoi(idParam)
+	if err != nil || userID < 1 {
+		utils.Warn("Invalid user ID param during password change: %s", idParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Валидация и разбор запроса
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Validation failed during password change: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	// Вызов бизнес-логики смены пароля
+	tokens, err := h.passwordService.ChangePassword(c.Request.Context(), uint(userID), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied during password change: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only change your own password"})
+			return
+		}
+		if errors.Is(err, services.ErrIncorrectPassword) {
+			utils.Warn("Incorrect current password during password change: id=%d", userID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+		if errors.Is(err, services.ErrUserNotFound) {
+			utils.Warn("User not found for password change: id=%d", userID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if respondPasswordPolicyError(c, err) {
+			utils.Warn("Weak password rejected during password change: id=%d", userID)
+			return
+		}
+		utils.Error("Password change failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	utils.Info("Password changed: id=%d", userID)
+	c.JSON(http.StatusOK, buildTokenResponse(tokens))
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Хэндлер для просмотра и завершения сессий пользователя (REST API)
+type SessionHandler struct {
+	sessionService services.SessionService
+}
+
+// Конструктор хэндлера сессий
+func NewSessionHandler(sessionService services.SessionService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionService}
+}
+
+// ListSessions godoc
+// @Summary Получить сессии пользователя
+// @Description Возвращает действующие входы пользователя по устройствам: User-Agent, IP-адрес, время входа и последней активности. Активность отмечается при обновлении токенов. Сессия, токеном которой выполнен запрос, помечена current
+// @Tags sessions
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/sessions [get]
+// @Security BearerAuth
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "session")
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(c.Request.Context(), userID)
+	if err != nil {
+		respondSessionError(c, err, "Failed to fetch sessions")
+		return
+	}
+
+	currentSessionID := c.GetUint("session_id")
+	respSessions := make([]models.SessionResponse, len(sessions))
+	for i, s := range sessions {
+		respSessions[i] = models.BuildSessionResponse(&s, currentSessionID)
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": respSessions})
+}
+
+// RevokeSession godoc
+// @Summary Завершить сессию
+// @Description Завершает вход пользователя на устройстве: refresh-токены сессии отзываются, а все её access-токены отклоняются по claim sid, поэтому запросы с устройства перестают проходить сразу
+// @Tags sessions
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param sid path int true "ID сессии"
+// @Success 204 {string} string ""
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/sessions/{sid} [delete]
+// @Security BearerAuth
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, ok := parseUserIDParam(c, "session")
+	if !ok {
+		return
+	}
+	sessionParam := c.Param("sid")
+	sessionID, err := strconv.Atoi(sessionParam)
+	if err != nil || sessionID < 1 {
+		utils.Warn("Invalid session ID param: %s", sessionParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(c.Request.Context(), userID, uint(sessionID)); err != nil {
+		respondSessionError(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Вспомогательная функция для ответа на ошибку сервиса сессий
+func respondSessionError(c *gin.Context, err error, failure string) {
+	if errors.Is(err, services.ErrForbidden) {
+		utils.Warn("Access denied in session request: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you cannot manage sessions of this account"})
+		return
+	}
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	utils.Error("%s: %v", failure, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+}
@@ -1,18 +1,24 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/services"
 	"github.com/iwtcode/user-order-api/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 )
 
+// Тип содержимого JSON Merge Patch (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
 // Хэндлер для работы с пользователями (REST API)
 type UserHandler struct {
 	userService services.UserService
@@ -25,12 +31,13 @@ func NewUserHandler(userService services.UserService) *UserHandler {
 
 // CreateUser godoc
 // @Summary Создать пользователя
-// @Description Регистрирует нового пользователя
+// @Description Регистрирует нового пользователя. Пароль проверяется парольной политикой, при нарушении возвращается 422 со списком нарушенных правил. В режиме приватной регистрации и для нового, и для занятого email возвращает 202 без данных пользователя, а владельцу занятого адреса приходит уведомление
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param input body models.CreateUserRequest true "Данные пользователя"
 // @Success 201 {object} models.UserResponse
+// @Success 202 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 422 {object} map[string]interface{}
 // @Router /users [post]
@@ -55,7 +62,11 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	}
 
 	// Вызов бизнес-логики создания пользователя
-	newUser, err := h.userService.CreateUser(c.Request.Context(), &req)
+	result, err := h.userService.CreateUser(c.Request.Context(), &req)
+	if respondPasswordPolicyError(c, err) {
+		utils.Warn("Weak password rejected during user creation: %v", err)
+		return
+	}
 	if err != nil {
 		if errors.Is(err, services.ErrEmailExists) {
 			utils.Warn("Attempt to create user with existing email: %s", req.Email)
@@ -66,16 +77,22 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	// В режиме приватной регистрации ответ не раскрывает, создан ли аккаунт
+	if result.Accepted {
+		utils.Info("Sign-up accepted in private signup mode")
+		c.JSON(http.StatusAccepted, gin.H{"message": "If the email is not registered yet, the account has been created. Check your email"})
+		return
+	}
 
-	utils.Info("User created: id=%d, email=%s", newUser.ID, newUser.Email)
+	utils.Info("User created: id=%d, email=%s", result.User.ID, result.User.Email)
 	// Формирование и отправка ответа
-	response := models.BuildUserResponse(newUser)
+	response := models.BuildUserResponse(result.User)
 	c.JSON(http.StatusCreated, response)
 }
 
 // ListUsers godoc
 // @Summary Получить список пользователей
-// @Description Возвращает список пользователей с пагинацией и фильтрацией
+// @Description Возвращает список пользователей с пагинацией, фильтрацией, поиском и сортировкой. Без sort пользователи упорядочены по id
 // @Tags users
 // @Accept json
 // @Produce json
@@ -83,12 +100,15 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 // @Param limit query int false "Размер страницы"
 // @Param min_age query int false "Минимальный возраст"
 // @Param max_age query int false "Максимальный возраст"
+// @Param q query string false "Подстрока имени или email без учёта регистра"
+// @Param email_domain query string false "Домены email через запятую, например example.com"
+// @Param sort query string false "Поля сортировки через запятую: id, name, email, age; минус перед полем — по убыванию, например name,-age"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Router /users [get]
 // @Security BearerAuth
 func (h *UserHandler) ListUsers(c *gin.Context) {
-	utils.Info("ListUsers called: page=%s, limit=%s, min_age=%s, max_age=%s", c.DefaultQuery("page", "1"), c.DefaultQuery("limit", "10"), c.DefaultQuery("min_age", "0"), c.DefaultQuery("max_age", "0"))
+	utils.Info("ListUsers called: page=%s, limit=%s, min_age=%s, max_age=%s, q=%q, email_domain=%s, sort=%s", c.DefaultQuery("page", "1"), c.DefaultQuery("limit", "10"), c.DefaultQuery("min_age", "0"), c.DefaultQuery("max_age", "0"), c.Query("q"), c.Query("email_domain"), c.Query("sort"))
 	// Получение параметров пагинации и фильтрации
 	page, err1 := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, err2 := strconv.Atoi(c.DefaultQuery("limit", "10"))
@@ -101,8 +121,24 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		return
 	}
 
+	// Сортировка допускается только по полям из белого списка
+	sort, ok := models.ParseSort(c.Query("sort"), models.UserSortFields)
+	if !ok {
+		utils.Warn("Invalid sort param: %s", c.Query("sort"))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be a comma-separated list of distinct fields id, name, email, age, each optionally prefixed with -"})
+		return
+	}
+
+	query := models.UserListQuery{
+		ListQuery:    models.ListQuery{Page: page, Limit: limit, Sort: sort},
+		MinAge:       minAge,
+		MaxAge:       maxAge,
+		Search:       strings.TrimSpace(c.Query("q")),
+		EmailDomains: parseEmailDomains(c.Query("email_domain")),
+	}
+
 	// Вызов бизнес-логики
-	users, total, err := h.userService.ListUsers(c.Request.Context(), page, limit, minAge, maxAge)
+	users, total, err := h.userService.ListUsers(c.Request.Context(), query)
 	if err != nil {
 		utils.Error("Failed to fetch users: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
@@ -123,6 +159,18 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	})
 }
 
+// Разбирает список доменов email через запятую
+// Домены приводятся к нижнему регистру, ведущий @ отбрасывается, пустые значения пропускаются
+func parseEmailDomains(raw string) []string {
+	var domains []string
+	for _, domain := range strings.Split(raw, ",") {
+		if domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
 // GetUserByID godoc
 // @Summary Получить пользователя по ID
 // @Description Возвращает пользователя по его ID
@@ -131,6 +179,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 // @Produce json
 // @Param id path int true "ID пользователя"
 // @Success 200 {object} models.UserResponse
+// @Header 200 {string} ETag "Версия пользователя для If-Match"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /users/{id} [get]
@@ -162,20 +211,25 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 	// Формирование и отправка ответа
 	response := models.BuildUserResponse(user)
 	utils.Info("User fetched: id=%d", userID)
+	c.Header("ETag", userETag(user))
 	c.JSON(http.StatusOK, response)
 }
 
 // UpdateUser godoc
 // @Summary Обновить пользователя
-// @Description Обновляет данные пользователя по ID
+// @Description Обновляет данные пользователя по ID. Пользователь может обновлять только свой аккаунт, администратор — любой. С заголовком If-Match обновление выполняется, только если ETag пользователя не изменился, иначе возвращается 412
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "ID пользователя"
+// @Param If-Match header string false "ETag, полученный при чтении пользователя"
 // @Param input body models.UpdateUserRequest true "Данные для обновления"
 // @Success 200 {object} models.UserResponse
+// @Header 200 {string} ETag "Новая версия пользователя"
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Failure 422 {object} map[string]interface{}
 // @Router /users/{id} [put]
 // @Security BearerAuth
@@ -208,13 +262,23 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	// Вызов бизнес-логики
-	user, err := h.userService.UpdateUser(c.Request.Context(), uint(userID), &req)
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(userID), &req, parseIfMatch(c.GetHeader("If-Match")))
 	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied for update: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only operate on your own account"})
+			return
+		}
 		if errors.Is(err, services.ErrUserNotFound) {
 			utils.Warn("User not found for update: id=%d", userID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
+		if errors.Is(err, services.ErrVersionMismatch) {
+			utils.Warn("Precondition failed for update: %v", err)
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "User has been modified: fetch it again and retry"})
+			return
+		}
 		if errors.Is(err, services.ErrEmailExists) {
 			utils.Warn("Email already exists for update: id=%d, email=%s", userID, req.Email)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
@@ -228,19 +292,155 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	// Формирование и отправка ответа
 	response := models.BuildUserResponse(user)
 	utils.Info("User updated: id=%d", userID)
+	c.Header("ETag", userETag(user))
+	c.JSON(http.StatusOK, response)
+}
+
+// PatchUser godoc
+// @Summary Частично обновить пользователя
+// @Description Обновляет только переданные поля пользователя по JSON Merge Patch (RFC 7396); проверяются лишь переданные поля. Значение null для поля означает его удаление, поэтому для обязательных полей оно даёт 422. Пустой объект не меняет пользователя. Пользователь может обновлять только свой аккаунт, администратор — любой. If-Match работает так же, как в PUT
+// @Tags users
+// @Accept json
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param If-Match header string false "ETag, полученный при чтении пользователя"
+// @Param input body models.PatchUserRequest true "Изменяемые поля"
+// @Success 200 {object} models.UserResponse
+// @Header 200 {string} ETag "Новая версия пользователя"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Router /users/{id} [patch]
+// @Security BearerAuth
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	utils.Info("PatchUser called: id=%s", c.Param("id"))
+	// Получение и проверка ID
+	idParam := c.Param("id")
+	userID, err := strconv.Atoi(idParam)
+	if err != nil || userID < 1 {
+		utils.Warn("Invalid user ID param: %s", idParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if contentType := c.ContentType(); contentType != mergePatchContentType && contentType != binding.MIMEJSON {
+		utils.Warn("Unsupported content type during user patch: %s", contentType)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported content type: use " + mergePatchContentType})
+		return
+	}
+
+	// Валидация и разбор запроса
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		utils.Warn("Merge patch is not a JSON object during user patch: id=%d", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: merge patch must be a JSON object"})
+		return
+	}
+	if details := nullPatchMembers(members, "name", "email", "age"); len(details) > 0 {
+		utils.Warn("Attempt to remove required fields during user patch: id=%d, %v", userID, details)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "details": details})
+		return
+	}
+	var req models.PatchUserRequest
+	if err := binding.JSON.BindBody(body, &req); err != nil {
+		utils.Warn("Validation failed during user patch: %v", err)
+		respondBindError(c, err)
+		return
+	}
+
+	// Вызов бизнес-логики
+	user, err := h.userService.PatchUser(c.Request.Context(), uint(userID), &req, parseIfMatch(c.GetHeader("If-Match")))
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied for patch: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only operate on your own account"})
+			return
+		}
+		if errors.Is(err, services.ErrUserNotFound) {
+			utils.Warn("User not found for patch: id=%d", userID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if errors.Is(err, services.ErrVersionMismatch) {
+			utils.Warn("Precondition failed for patch: %v", err)
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "User has been modified: fetch it again and retry"})
+			return
+		}
+		if errors.Is(err, services.ErrEmailExists) {
+			utils.Warn("Email already exists for patch: id=%d, email=%s", userID, *req.Email)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
+			return
+		}
+		utils.Error("Failed to patch user: id=%d, err=%v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	// Формирование и отправка ответа
+	response := models.BuildUserResponse(user)
+	utils.Info("User patched: id=%d", userID)
+	c.Header("ETag", userETag(user))
 	c.JSON(http.StatusOK, response)
 }
 
+// Вспомогательная функция для поиска обязательных полей, которые merge patch пытается удалить значением null
+// Возвращает описания нарушений в формате ошибок валидации
+func nullPatchMembers(members map[string]json.RawMessage, required ...string) []string {
+	var details []string
+	for _, name := range required {
+		if value, ok := members[name]; ok && string(value) == "null" {
+			details = append(details, name+": required")
+		}
+	}
+	return details
+}
+
+// Вспомогательная функция для формирования ETag пользователя по версии записи
+func userETag(user *models.User) string {
+	return `"` + strconv.Itoa(user.Version) + `"`
+}
+
+// Вспомогательная функция для разбора заголовка If-Match в список версий пользователя
+// Возвращает nil, если заголовка нет или он равен *. Слабые и нераспознанные ETag не совпадают ни с одной версией
+// (If-Match использует строгое сравнение), поэтому из них получается пустой список, отличный от nil
+func parseIfMatch(header string) []int {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
 // DeleteUser godoc
 // @Summary Удалить пользователя
-// @Description Удаляет пользователя по ID
+// @Description Мягко удаляет пользователя по ID: он пропадает из списков и не может войти, его заказы сохраняются. Администратор может восстановить аккаунт, пока не истёк срок хранения. При политике restrict пользователя с заказами удалить нельзя (409). Пользователь может удалить только свой аккаунт, администратор — любой
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "ID пользователя"
 // @Success 204 {string} string ""
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /users/{id} [delete]
 // @Security BearerAuth
 func (h *UserHandler) DeleteUser(c *gin.Context) {
@@ -257,11 +457,21 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	// Вызов бизнес-логики
 	err = h.userService.DeleteUser(c.Request.Context(), uint(userID))
 	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied for delete: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you can only operate on your own account"})
+			return
+		}
 		if errors.Is(err, services.ErrUserNotFound) {
 			utils.Warn("User not found for delete: id=%d", userID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
+		if errors.Is(err, services.ErrUserHasOrders) {
+			utils.Warn("User with orders cannot be deleted: %v", err)
+			c.JSON(http.StatusConflict, gin.H{"error": "User has orders and cannot be deleted"})
+			return
+		}
 		utils.Error("Failed to delete user: id=%d, err=%v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
@@ -271,3 +481,58 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	utils.Info("User deleted: id=%d", userID)
 	c.Status(http.StatusNoContent)
 }
+
+// RestoreUser godoc
+// @Summary Восстановить пользователя
+// @Description Восстанавливает мягко удалённого пользователя, пока не истёк срок хранения. Если его email уже занят другим аккаунтом, возвращает 409. Доступно только администратору
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} models.UserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/restore [post]
+// @Security BearerAuth
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	utils.Info("RestoreUser called: id=%s", c.Param("id"))
+	// Получение и проверка ID
+	idParam := c.Param("id")
+	userID, err := strconv.Atoi(idParam)
+	if err != nil || userID < 1 {
+		utils.Warn("Invalid user ID param: %s", idParam)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Вызов бизнес-логики
+	user, err := h.userService.RestoreUser(c.Request.Context(), uint(userID))
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			utils.Warn("Access denied for restore: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: administrator role required"})
+			return
+		}
+		if errors.Is(err, services.ErrUserNotFound) {
+			utils.Warn("Deleted user not found for restore: id=%d", userID)
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deleted user not found"})
+			return
+		}
+		if errors.Is(err, services.ErrEmailExists) {
+			utils.Warn("Email conflict during restore: %v", err)
+			c.JSON(http.StatusConflict, gin.H{"error": "Email of the deleted user is used by another account"})
+			return
+		}
+		utils.Error("Failed to restore user: id=%d, err=%v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+
+	// Формирование и отправка ответа
+	response := models.BuildUserResponse(user)
+	utils.Info("User restored: id=%d by admin %d", userID, c.GetUint("user_id"))
+	c.Header("ETag", userETag(user))
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Способы отправки писем
+const (
+	DriverConsole = "console"
+	DriverFile    = "file"
+	DriverSMTP    = "smtp"
+)
+
+// Письмо в виде простого текста
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Интерфейс отправки писем
+// Сервисы зависят только от него, конкретная реализация выбирается конфигурацией
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Настройки отправки писем
+type Config struct {
+	Driver       string
+	From         string
+	FilePath     string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// Создаёт реализацию Mailer по настройкам
+// console пишет письма в стандартный вывод, file — дописывает в файл, smtp — отправляет через SMTP-сервер
+func NewMailer(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverConsole, "":
+		return NewWriterMailer(os.Stdout, cfg.From), nil
+	case DriverFile:
+		return NewFileMailer(cfg.FilePath, cfg.From)
+	case DriverSMTP:
+		return NewSMTPMailer(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", cfg.Driver)
+	}
+}
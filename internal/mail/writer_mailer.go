@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Реализация Mailer, которая записывает письма в поток вместо отправки
+// Используется локально и в тестах: письмо со ссылкой можно прочитать из консоли или файла
+type writerMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// Конструктор Mailer, записывающего письма в произвольный поток
+func NewWriterMailer(w io.Writer, from string) Mailer {
+	return &writerMailer{w: w, from: from}
+}
+
+// Конструктор Mailer, дописывающего письма в файл
+func NewFileMailer(path, from string) (Mailer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("mail file path is required for %s driver", DriverFile)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail file %s: %w", path, err)
+	}
+	return NewWriterMailer(file, from), nil
+}
+
+// Записывает письмо в поток
+func (m *writerMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.w, "----- mail %s -----\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n----- end of mail -----\n",
+		time.Now().UTC().Format(time.RFC3339), m.from, msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("failed to write mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
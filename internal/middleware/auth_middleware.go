@@ -6,11 +6,40 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
 	"github.com/iwtcode/user-order-api/internal/utils"
 )
 
+// Сообщения об ошибках для каждого класса ошибок проверки токена
+var jwtErrorMessages = []struct {
+	err     error
+	message string
+}{
+	{utils.ErrJWTMalformed, "Malformed token"},
+	{utils.ErrJWTSignatureInvalid, "Invalid token signature"},
+	{utils.ErrJWTExpired, "Token is expired"},
+	{utils.ErrJWTNotValidYet, "Token is not valid yet"},
+	{utils.ErrJWTInvalidIssuer, "Invalid token issuer"},
+	{utils.ErrJWTInvalidAudience, "Invalid token audience"},
+}
+
+// Возвращает сообщение для клиента по классу ошибки проверки токена
+func jwtErrorMessage(err error) string {
+	for _, m := range jwtErrorMessages {
+		if errors.Is(err, m.err) {
+			return m.message
+		}
+	}
+	return "Invalid token claims"
+}
+
 // Промежуточный middleware для проверки JWT-токена в запросах
-func JWTAuthMiddleware() gin.HandlerFunc {
+// Помимо подписи и срока действия проверяет, не отозван ли токен.
+// Для токена пользователя в контекст кладутся user_id, role и, если токен выдан в рамках сессии, session_id,
+// для токена имперсонации — ещё и actor_id администратора, для токена клиента OAuth2 — client_id
+func JWTAuthMiddleware(revocations services.RevocationStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Получаем заголовок Authorization
 		authHeader := c.GetHeader("Authorization")
@@ -23,24 +52,119 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		claims, err := utils.ParseJWT(tokenString)
 		if err != nil {
-			if errors.Is(err, utils.JwtErrTokenExpired()) {
-				utils.Warn("Token expired: %s", tokenString)
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token is expired"})
-				return
-			}
 			utils.Warn("Invalid token: %v", err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": jwtErrorMessage(err)})
 			return
 		}
-		// Извлекаем user_id из токена и сохраняем в контекст запроса
-		userID, ok := claims["user_id"].(float64)
+		// Определяем субъект токена: пользователь или клиент OAuth2
+		principal, ok := principalFromClaims(claims)
 		if !ok {
 			utils.Error("Invalid token payload: user_id missing. Claims: %+v", claims)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token payload: user_id missing"})
 			return
 		}
-		utils.Info("Authenticated user_id: %d", uint(userID))
-		c.Set("user_id", uint(userID))
+		// Извлекаем jti и время выпуска, необходимые для проверки отзыва
+		jti, _ := claims["jti"].(string)
+		issuedAt, hasIssuedAt := utils.ClaimTime(claims, "iat")
+		expiresAt, _ := utils.ClaimTime(claims, "exp")
+		if jti == "" || !hasIssuedAt {
+			utils.Warn("Invalid token payload: jti or iat missing. Claims: %+v", claims)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token payload: jti or iat missing"})
+			return
+		}
+		// Проверяем, не отозван ли токен или его сессия; у токенов клиентов UserID равен нулю,
+		// и для них действует только отзыв по jti
+		revoked, err := revocations.IsRevoked(c.Request.Context(), jti, principal.UserID, principal.SessionID, issuedAt)
+		if err != nil {
+			utils.Error("Failed to check token revocation for jti=%s: %v", jti, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+			return
+		}
+		if revoked {
+			utils.Warn("Revoked token used: jti=%s, user_id=%d", jti, principal.UserID)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return
+		}
+		if principal.IsClient() {
+			utils.Info("Authenticated client_id: %s", principal.ClientID)
+			c.Set("client_id", principal.ClientID)
+		} else if principal.IsImpersonated() {
+			utils.Info("Authenticated user_id: %d, role: %s, impersonated by admin_id: %d", principal.UserID, principal.Role, principal.ActorID)
+			c.Set("user_id", principal.UserID)
+			c.Set("role", principal.Role)
+			c.Set("actor_id", principal.ActorID)
+		} else {
+			utils.Info("Authenticated user_id: %d, role: %s", principal.UserID, principal.Role)
+			c.Set("user_id", principal.UserID)
+			c.Set("role", principal.Role)
+			if principal.SessionID != 0 {
+				c.Set("session_id", principal.SessionID)
+			}
+		}
+		c.Set("jti", jti)
+		c.Set("token_expires_at", expiresAt)
+		c.Set("scopes", principal.Scopes)
+		// Идентичность вызывающего передаётся в сервисы через контекст запроса
+		c.Request = c.Request.WithContext(services.ContextWithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// Извлекает идентичность вызывающего из claims токена
+// Токен пользователя содержит user_id и role, токен клиента OAuth2 — client_id.
+// Возвращает false, если в токене нет ни того, ни другого
+func principalFromClaims(claims jwt.MapClaims) (services.Principal, bool) {
+	// Токены, выпущенные до появления областей действия, не содержат claim scope и дают все права
+	scopes, hasScopes := utils.ClaimScopes(claims)
+	if !hasScopes {
+		scopes = models.AllScopes
+	}
+	if clientID, _ := claims["client_id"].(string); clientID != "" {
+		return services.Principal{ClientID: clientID, Scopes: scopes}, true
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return services.Principal{}, false
+	}
+	// Токены, выпущенные до появления ролей, не содержат claim role
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = models.RoleUser
+	}
+	// Токены, выпущенные до появления сессий, не содержат claim sid
+	sessionID, _ := claims["sid"].(float64)
+	// Токен имперсонации содержит claim act с ID администратора
+	actorID, _ := utils.ClaimActorID(claims)
+	return services.Principal{UserID: uint(userID), Role: role, Scopes: scopes, SessionID: uint(sessionID), ActorID: actorID}, true
+}
+
+// Middleware аутентификации по JWT (Authorization: Bearer ...) или по персональному API-ключу (Authorization: ApiKey ...)
+// По ключу в контекст запроса кладутся те же user_id, role и scopes, что и по токену, а также api_key_id
+func AuthMiddleware(revocations services.RevocationStore, apiKeys services.APIKeyService) gin.HandlerFunc {
+	jwtAuth := JWTAuthMiddleware(revocations)
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "ApiKey ") {
+			jwtAuth(c)
+			return
+		}
+		principal, err := apiKeys.Authenticate(c.Request.Context(), strings.TrimPrefix(authHeader, "ApiKey "))
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIKey) {
+				utils.Warn("Invalid API key used")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid, expired or revoked API key"})
+				return
+			}
+			utils.Error("Failed to verify API key: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key"})
+			return
+		}
+		utils.Info("Authenticated user_id: %d, role: %s, api_key_id: %d", principal.UserID, principal.Role, principal.APIKeyID)
+		c.Set("user_id", principal.UserID)
+		c.Set("role", principal.Role)
+		c.Set("api_key_id", principal.APIKeyID)
+		c.Set("scopes", principal.GrantedScopes())
+		c.Request = c.Request.WithContext(services.ContextWithPrincipal(c.Request.Context(), principal))
 		c.Next()
 	}
 }
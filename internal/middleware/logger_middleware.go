@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -8,6 +9,7 @@ import (
 )
 
 // LoggerMiddleware логирует HTTP-запросы через кастомный логгер
+// Запросы по токену имперсонации помечаются ID пользователя и администратора, который действует от его имени
 func LoggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -20,6 +22,10 @@ func LoggerMiddleware() gin.HandlerFunc {
 		status := c.Writer.Status()
 		duration := time.Since(start)
 		errors := c.Errors.ByType(gin.ErrorTypePrivate).String()
+		// actor_id кладётся в контекст JWTAuthMiddleware, поэтому читается после обработки запроса
+		if actorID := c.GetUint("actor_id"); actorID != 0 {
+			path = fmt.Sprintf("%s | impersonated user_id=%d by admin_id=%d", path, c.GetUint("user_id"), actorID)
+		}
 
 		if errors != "" {
 			utils.ErrorSrc(utils.GinSource, "%s | %s | %d | %s | %s | %s", method, path, status, clientIP, duration, errors)
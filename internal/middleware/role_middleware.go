@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Middleware пропускает запрос, только если роль из токена входит в список разрешённых
+// Должен подключаться после JWTAuthMiddleware или AuthMiddleware
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if !slices.Contains(roles, role) {
+			utils.Warn("Access denied: user %d with role %q requested %s %s", c.GetUint("user_id"), role, c.Request.Method, c.FullPath())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied: insufficient role"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Middleware пропускает только запросы от имени пользователя
+// Токены клиентов OAuth2 получают 403: у сервиса нет входа, из которого можно выйти, и учётных данных пользователя
+// Должен подключаться после JWTAuthMiddleware или AuthMiddleware
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if clientID := c.GetString("client_id"); clientID != "" {
+			utils.Warn("Access denied: OAuth client %s requested %s %s", clientID, c.Request.Method, c.FullPath())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied: user token required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Middleware запрещает запрос по токену имперсонации
+// Администратор, действующий от имени пользователя, не может менять его учётные данные и завершать его входы
+// Должен подключаться после JWTAuthMiddleware или AuthMiddleware
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actorID := c.GetUint("actor_id"); actorID != 0 {
+			utils.Warn("Access denied: admin %d impersonating user %d requested %s %s", actorID, c.GetUint("user_id"), c.Request.Method, c.FullPath())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied: not allowed while impersonating a user"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Middleware пропускает запрос, только если токен или API-ключ имеет все перечисленные области действия
+// Должен подключаться после JWTAuthMiddleware или AuthMiddleware. Недостающие области
+// перечисляются в заголовке WWW-Authenticate, как предписывает RFC 6750
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	required := strings.Join(scopes, " ")
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("scopes")
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				utils.Warn("Access denied: user %d with scopes %q requested %s %s", c.GetUint("user_id"), strings.Join(granted, " "), c.Request.Method, c.FullPath())
+				c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+required+`"`)
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied: insufficient scope"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
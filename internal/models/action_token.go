@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Назначения одноразовых токенов
+const (
+	ActionPasswordReset     = "password_reset"
+	ActionEmailVerification = "email_verification"
+	ActionMFALogin          = "mfa_login"
+)
+
+// Структура одноразового токена действия (сброс пароля, подтверждение email, второй шаг входа) для хранения в базе данных
+// Хранится только SHA-256 хеш токена; токен считается использованным после установки UsedAt.
+// Attempts считает неудачные попытки там, где к токену прилагается проверяемый код
+type ActionToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"type:varchar(32);not null" json:"purpose"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура персонального API-ключа для хранения в базе данных
+// Хранится только SHA-256 хеш ключа; Prefix — видимое начало ключа, по которому владелец узнаёт его в списке.
+// Scopes — области действия через пробел, пустая строка означает все права владельца
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"type:varchar(255);not null;default:''" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Возвращает области действия ключа списком
+func (k *APIKey) ScopeList() []string {
+	return ParseScopes(k.Scopes)
+}
+
+// APIKeyResponse содержит данные API-ключа без самого ключа
+// swagger:model
+// Структура для ответа API с данными API-ключа
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyRequest содержит данные для создания API-ключа
+// swagger:model
+// Структура для запроса на создание API-ключа
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Вспомогательная функция для формирования ответа API по API-ключу
+func BuildAPIKeyResponse(key *APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
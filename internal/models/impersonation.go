@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура записи журнала имперсонации для хранения в базе данных
+// Каждая выдача администратору токена для работы от имени пользователя оставляет запись:
+// кто, за кого, по какой причине, с какого IP-адреса и до какого времени. TokenID — jti выданного токена
+type Impersonation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AdminID   uint      `gorm:"not null;index" json:"admin_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Reason    string    `gorm:"type:varchar(255);not null" json:"reason"`
+	TokenID   string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"token_id"`
+	IPAddress string    `gorm:"type:varchar(45);not null;default:''" json:"ip_address"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ImpersonateRequest содержит причину имперсонации для журнала
+// swagger:model
+// Структура для запроса на работу от имени пользователя
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Поля пользователя, по которым разрешена сортировка списка
+// Имена полей API совпадают с именами столбцов
+var UserSortFields = []string{"id", "name", "email", "age"}
+
+// Поле сортировки: имя поля и направление
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Общая часть спецификации выборки списка: страница и сортировка
+// Подходит для любого ресурса; фильтры ресурса добавляются во встраивающую структуру
+type ListQuery struct {
+	Page  int
+	Limit int
+	Sort  []SortField
+}
+
+// Смещение первой записи страницы
+func (q ListQuery) Offset() int {
+	return (q.Page - 1) * q.Limit
+}
+
+// Спецификация выборки списка пользователей
+// MinAge/MaxAge — границы возраста (0 — без границы), Search — подстрока имени или email без учёта регистра,
+// EmailDomains — домены email (часть после @) в нижнем регистре
+type UserListQuery struct {
+	ListQuery
+	MinAge       int
+	MaxAge       int
+	Search       string
+	EmailDomains []string
+}
+
+// Разбирает параметр sort вида "name,-age": минус перед полем означает сортировку по убыванию
+// Возвращает false, если поле не входит в allowed, пусто или повторяется
+// Пустой sort означает порядок по умолчанию
+func ParseSort(sort string, allowed []string) ([]SortField, bool) {
+	if sort == "" {
+		return nil, true
+	}
+	var fields []SortField
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(sort, ",") {
+		item = strings.TrimSpace(item)
+		field := SortField{Field: strings.TrimPrefix(item, "-"), Desc: strings.HasPrefix(item, "-")}
+		if !slices.Contains(allowed, field.Field) || seen[field.Field] {
+			return nil, false
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+	return fields, true
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// Области счётчиков неудачных попыток входа
+const (
+	// Счётчик по аккаунту, ключ — email в нижнем регистре
+	LoginThrottleAccount = "account"
+	// Счётчик по адресу клиента, ключ — IP-адрес
+	LoginThrottleIP = "ip"
+)
+
+// Структура счётчика неудачных попыток входа для хранения в базе данных
+// Счётчик обнуляется, если с последней неудачи и с конца блокировки прошло больше окна учёта.
+// Пока LockedUntil в будущем, вход для ключа запрещён
+type LoginThrottle struct {
+	Scope         string     `gorm:"primaryKey;type:varchar(16)" json:"scope"`
+	Subject       string     `gorm:"primaryKey;type:varchar(255)" json:"subject"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура TOTP-фактора пользователя для хранения в базе данных
+// Фактор включён после подтверждения первым кодом (ConfirmedAt != nil).
+// LastUsedStep — шаг последнего принятого кода, код того же или более раннего шага не принимается повторно
+type TOTPFactor struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	Secret       string     `gorm:"type:varchar(64);not null" json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Структура кода восстановления для входа без приложения-аутентификатора
+// Хранится только SHA-256 хеш кода; код одноразовый
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
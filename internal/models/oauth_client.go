@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура зарегистрированного клиента OAuth2 для хранения в базе данных
+// Клиент — внутренний сервис, получающий токены по grant client_credentials от своего имени, а не от имени пользователя.
+// Хранится только SHA-256 хеш секрета; Scopes — области действия через пробел, больше которых клиент получить не может
+type OAuthClient struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	ClientID   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"client_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	SecretHash string     `gorm:"type:varchar(64);not null" json:"-"`
+	Scopes     string     `gorm:"type:varchar(255);not null" json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Имя таблицы: по умолчанию GORM разбил бы OAuth на o_auth
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// Возвращает области действия клиента списком
+func (c *OAuthClient) ScopeList() []string {
+	return ParseScopes(c.Scopes)
+}
+
+// OAuthClientResponse содержит данные клиента OAuth2 без секрета
+// swagger:model
+// Структура для ответа API с данными клиента OAuth2
+type OAuthClientResponse struct {
+	ID         uint       `json:"id"`
+	ClientID   string     `json:"client_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateOAuthClientRequest содержит данные для регистрации клиента OAuth2
+// swagger:model
+// Структура для запроса на регистрацию клиента OAuth2
+type CreateOAuthClientRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+// Вспомогательная функция для формирования ответа API по клиенту OAuth2
+func BuildOAuthClientResponse(client *OAuthClient) OAuthClientResponse {
+	return OAuthClientResponse{
+		ID:         client.ID,
+		ClientID:   client.ClientID,
+		Name:       client.Name,
+		Scopes:     client.ScopeList(),
+		LastUsedAt: client.LastUsedAt,
+		CreatedAt:  client.CreatedAt,
+	}
+}
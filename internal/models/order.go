@@ -4,10 +4,20 @@ import (
 	"time"
 )
 
+// Политики обращения с заказами при окончательном удалении пользователя (USER_DELETE_ORDER_POLICY)
+// restrict запрещает удалять пользователя с заказами, cascade удаляет заказы вместе с ним,
+// anonymize сохраняет заказы, отвязав их от пользователя
+const (
+	OrderPolicyRestrict  = "restrict"
+	OrderPolicyCascade   = "cascade"
+	OrderPolicyAnonymize = "anonymize"
+)
+
 // Структура заказа для хранения в базе данных
+// В БД user_id может быть NULL у обезличенных заказов удалённых пользователей; через API они недоступны
 type Order struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
 	Product   string    `gorm:"type:varchar(255);not null" json:"product"`
 	Quantity  int       `gorm:"not null" json:"quantity"`
 	Price     float64   `gorm:"type:decimal(10,2);not null" json:"price"`
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура refresh-токена для хранения в базе данных
+// Хранится только SHA-256 хеш токена, сам токен отдаётся клиенту один раз
+// FamilyID объединяет все токены, полученные ротацией от одного входа,
+// Scopes — области действия входа через пробел (пустая строка у токенов, выпущенных до их появления)
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	FamilyID  string     `gorm:"type:varchar(64);index;not null" json:"family_id"`
+	Scopes    string     `gorm:"type:varchar(255);not null;default:''" json:"scopes"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
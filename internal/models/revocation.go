@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура отозванного access-токена для хранения в базе данных
+// Запись нужна только до истечения срока действия самого токена
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(64)" json:"jti"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Структура массового отзыва токенов пользователя
+// Все токены, выпущенные раньше RevokedBefore, считаются недействительными
+// ExpiresAt — момент, после которого такие токены истекут сами и запись можно удалить
+type UserTokenRevocation struct {
+	UserID        uint      `gorm:"primaryKey" json:"user_id"`
+	RevokedBefore time.Time `gorm:"not null" json:"revoked_before"`
+	ExpiresAt     time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// Структура отзыва сессии для хранения в базе данных
+// Все access-токены с claim sid, равным SessionID, считаются недействительными
+// ExpiresAt — момент, после которого такие токены истекут сами и запись можно удалить
+type RevokedSession struct {
+	SessionID uint      `gorm:"primaryKey" json:"session_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Области действия (scopes), которые можно выдать токену или API-ключу
+// Имя состоит из ресурса и уровня доступа: read — чтение, write — изменение
+const (
+	ScopeUsersRead   = "users:read"
+	ScopeUsersWrite  = "users:write"
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+)
+
+// Все поддерживаемые области действия
+var AllScopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeOrdersRead, ScopeOrdersWrite}
+
+// Проверяет, поддерживается ли область действия
+func IsKnownScope(scope string) bool {
+	return slices.Contains(AllScopes, scope)
+}
+
+// Разбирает список областей действия, разделённых пробелами (формат claim scope из OAuth2)
+func ParseScopes(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// Собирает список областей действия в строку через пробел
+func FormatScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура сессии (входа пользователя на устройстве) для хранения в базе данных
+// Сессия соответствует семейству refresh-токенов FamilyID: она открывается при входе и продлевается при каждом обновлении токенов.
+// Access-токены сессии содержат её ID в claim sid; при завершении сессии они отзываются все сразу (RevokedSession).
+// IPAddress и LastSeenAt обновляются при обновлении токенов
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	UserAgent  string     `gorm:"type:varchar(255);not null;default:''" json:"user_agent"`
+	IPAddress  string     `gorm:"type:varchar(45);not null;default:''" json:"ip_address"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// SessionResponse содержит данные сессии для владельца
+// swagger:model
+// Структура для ответа API с данными сессии; Current отмечает сессию, токеном которой выполнен запрос
+type SessionResponse struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Вспомогательная функция для формирования ответа API по сессии
+func BuildSessionResponse(session *Session, currentSessionID uint) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		Current:    currentSessionID != 0 && session.ID == currentSessionID,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
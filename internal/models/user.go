@@ -1,12 +1,31 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // Структура пользователя для хранения в базе данных
 type User struct {
 	ID           uint   `gorm:"primaryKey" json:"id"`
 	Name         string `gorm:"type:varchar(255);not null" json:"name"`
-	Email        string `gorm:"type:varchar(255);unique;not null" json:"email"`
+	Email        string `gorm:"type:varchar(255);uniqueIndex:idx_users_email_active,where:deleted_at IS NULL;not null" json:"email"`
 	Age          int    `gorm:"not null" json:"age"`
 	PasswordHash string `gorm:"type:varchar(255);not null" json:"-"`
+	Role         string `gorm:"type:varchar(32);not null;default:user" json:"role"`
+	// Момент подтверждения email; nil, пока пользователь не перешёл по ссылке из письма
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// Версия записи, увеличивается при каждом изменении; из неё строится ETag
+	Version int `gorm:"not null;default:1" json:"-"`
+	// Момент мягкого удаления; удалённые пользователи не попадают в выборки GORM, пока их не восстановят
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // UserResponse содержит данные пользователя
@@ -17,6 +36,9 @@ type UserResponse struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
 	Age   int    `json:"age"`
+	Role  string `json:"role"`
+	// Подтверждён ли email
+	EmailVerified bool `json:"email_verified"`
 }
 
 // CreateUserRequest содержит данные для создания пользователя
@@ -26,7 +48,7 @@ type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Age      int    `json:"age" binding:"required,gte=1"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"`
 }
 
 // UpdateUserRequest содержит данные для обновления пользователя
@@ -38,6 +60,15 @@ type UpdateUserRequest struct {
 	Age   int    `json:"age" binding:"required,gte=1"`
 }
 
+// PatchUserRequest содержит изменяемые поля пользователя (JSON Merge Patch, RFC 7396)
+// swagger:model
+// Структура для запроса на частичное обновление пользователя; nil означает, что поле не передано
+type PatchUserRequest struct {
+	Name  *string `json:"name" binding:"omitempty,min=1"`
+	Email *string `json:"email" binding:"omitempty,email"`
+	Age   *int    `json:"age" binding:"omitempty,gte=1"`
+}
+
 // Вспомогательная функция для формирования ответа API по пользователю
 func BuildUserResponse(user *User) UserResponse {
 	return UserResponse{
@@ -45,5 +76,8 @@ func BuildUserResponse(user *User) UserResponse {
 		Name:  user.Name,
 		Email: user.Email,
 		Age:   user.Age,
+		Role:  user.Role,
+
+		EmailVerified: user.EmailVerifiedAt != nil,
 	}
 }
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория одноразовых токенов действий для работы с БД
+type ActionTokenRepository interface {
+	// Сохраняет новый токен
+	CreateActionToken(ctx context.Context, token *models.ActionToken) error
+	// Возвращает токен с указанным назначением по хешу
+	GetActionTokenByHash(ctx context.Context, purpose, hash string) (*models.ActionToken, error)
+	// Помечает токен использованным, если он ещё не использован
+	ConsumeActionToken(ctx context.Context, id uint) error
+	// Помечает использованными все неиспользованные токены пользователя с указанным назначением
+	InvalidateUserActionTokens(ctx context.Context, userID uint, purpose string) error
+	// Учитывает неудачную попытку; после maxAttempts попыток токен становится использованным
+	RecordActionTokenFailure(ctx context.Context, id uint, maxAttempts int) error
+}
+
+// Реализация репозитория токенов действий на GORM
+type actionTokenRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория токенов действий
+func NewActionTokenRepository(db *gorm.DB) ActionTokenRepository {
+	return &actionTokenRepository{db: db}
+}
+
+// Сохраняет новый токен
+func (r *actionTokenRepository) CreateActionToken(ctx context.Context, token *models.ActionToken) error {
+	result := r.db.WithContext(ctx).Create(token)
+	if result.Error != nil {
+		utils.Error("Failed to create action token in DB: %v", result.Error)
+		return errors.New("failed to create action token: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Возвращает токен с указанным назначением по хешу
+func (r *actionTokenRepository) GetActionTokenByHash(ctx context.Context, purpose, hash string) (*models.ActionToken, error) {
+	var token models.ActionToken
+	result := r.db.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, hash).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get action token by hash: %v", result.Error)
+		return nil, errors.New("failed to get action token: " + result.Error.Error())
+	}
+	return &token, nil
+}
+
+// Помечает токен использованным, если он ещё не использован
+// Условие used_at IS NULL делает использование атомарным: из двух одновременных запросов
+// только один получит RowsAffected = 1, второй получит gorm.ErrRecordNotFound
+func (r *actionTokenRepository) ConsumeActionToken(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.ActionToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to consume action token id=%d: %v", id, result.Error)
+		return errors.New("failed to consume action token: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Помечает использованными все неиспользованные токены пользователя с указанным назначением
+func (r *actionTokenRepository) InvalidateUserActionTokens(ctx context.Context, userID uint, purpose string) error {
+	result := r.db.WithContext(ctx).Model(&models.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to invalidate %s tokens of user_id=%d: %v", purpose, userID, result.Error)
+		return errors.New("failed to invalidate action tokens: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Учитывает неудачную попытку; после maxAttempts попыток токен становится использованным
+// Счётчик и отметка использования меняются одним UPDATE, поэтому одновременные
+// неудачные попытки не позволяют превысить лимит
+func (r *actionTokenRepository) RecordActionTokenFailure(ctx context.Context, id uint, maxAttempts int) error {
+	result := r.db.WithContext(ctx).Model(&models.ActionToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"attempts": gorm.Expr("attempts + 1"),
+			"used_at":  gorm.Expr("CASE WHEN attempts + 1 >= ? THEN ?::timestamp ELSE NULL END", maxAttempts, time.Now().UTC()),
+		})
+	if result.Error != nil {
+		utils.Error("Failed to record failed attempt for action token id=%d: %v", id, result.Error)
+		return errors.New("failed to record failed attempt: " + result.Error.Error())
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория API-ключей для работы с БД
+type APIKeyRepository interface {
+	// Сохраняет новый API-ключ
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	// Возвращает API-ключ по хешу
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	// Возвращает неотозванные API-ключи пользователя, новые первыми
+	ListUserAPIKeys(ctx context.Context, userID uint) ([]models.APIKey, error)
+	// Отзывает API-ключ пользователя, если он ещё не отозван
+	RevokeAPIKey(ctx context.Context, userID, id uint) error
+	// Запоминает время последнего использования ключа
+	TouchAPIKey(ctx context.Context, id uint, usedAt time.Time) error
+}
+
+// Реализация репозитория API-ключей на GORM
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория API-ключей
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// Сохраняет новый API-ключ
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	result := r.db.WithContext(ctx).Create(key)
+	if result.Error != nil {
+		utils.Error("Failed to create API key in DB: %v", result.Error)
+		return errors.New("failed to create API key: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Возвращает API-ключ по хешу
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	result := r.db.WithContext(ctx).Where("key_hash = ?", hash).First(&key)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get API key by hash: %v", result.Error)
+		return nil, errors.New("failed to get API key: " + result.Error.Error())
+	}
+	return &key, nil
+}
+
+// Возвращает неотозванные API-ключи пользователя, новые первыми
+func (r *apiKeyRepository) ListUserAPIKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	result := r.db.WithContext(ctx).Where("user_id = ? AND revoked_at IS NULL", userID).Order("id DESC").Find(&keys)
+	if result.Error != nil {
+		utils.Error("Failed to list API keys of user_id=%d: %v", userID, result.Error)
+		return nil, errors.New("failed to list API keys: " + result.Error.Error())
+	}
+	return keys, nil
+}
+
+// Отзывает API-ключ пользователя, если он ещё не отозван
+// Чужой, неизвестный или уже отозванный ключ даёт gorm.ErrRecordNotFound
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, userID, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to revoke API key id=%d: %v", id, result.Error)
+		return errors.New("failed to revoke API key: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Запоминает время последнего использования ключа
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id uint, usedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt)
+	if result.Error != nil {
+		utils.Error("Failed to update last use of API key id=%d: %v", id, result.Error)
+		return errors.New("failed to update API key: " + result.Error.Error())
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория журнала имперсонации для работы с БД
+type ImpersonationRepository interface {
+	// Сохраняет запись о выдаче токена имперсонации
+	CreateImpersonation(ctx context.Context, impersonation *models.Impersonation) error
+}
+
+// Реализация репозитория журнала имперсонации на GORM
+type impersonationRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория журнала имперсонации
+func NewImpersonationRepository(db *gorm.DB) ImpersonationRepository {
+	return &impersonationRepository{db: db}
+}
+
+// Сохраняет запись о выдаче токена имперсонации
+func (r *impersonationRepository) CreateImpersonation(ctx context.Context, impersonation *models.Impersonation) error {
+	result := r.db.WithContext(ctx).Create(impersonation)
+	if result.Error != nil {
+		utils.Error("Failed to create impersonation record in DB: %v", result.Error)
+		return errors.New("failed to create impersonation record: " + result.Error.Error())
+	}
+	return nil
+}
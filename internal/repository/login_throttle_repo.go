@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория счётчиков неудачных попыток входа для работы с БД
+type LoginThrottleRepository interface {
+	// Возвращает счётчик по области и ключу
+	GetLoginThrottle(ctx context.Context, scope, subject string) (*models.LoginThrottle, error)
+	// Увеличивает счётчик неудач и возвращает его новое состояние
+	RecordLoginFailure(ctx context.Context, scope, subject string, now, windowStart time.Time) (*models.LoginThrottle, error)
+	// Запрещает вход для ключа до указанного момента
+	LockLogin(ctx context.Context, scope, subject string, until time.Time) error
+	// Удаляет счётчик, снимая блокировку
+	DeleteLoginThrottle(ctx context.Context, scope, subject string) error
+}
+
+// Реализация репозитория счётчиков неудачных попыток входа на GORM
+type loginThrottleRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория счётчиков неудачных попыток входа
+func NewLoginThrottleRepository(db *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+// Возвращает счётчик по области и ключу
+func (r *loginThrottleRepository) GetLoginThrottle(ctx context.Context, scope, subject string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	result := r.db.WithContext(ctx).Where("scope = ? AND subject = ?", scope, subject).First(&throttle)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get login throttle %s: %v", scope, result.Error)
+		return nil, errors.New("failed to get login throttle: " + result.Error.Error())
+	}
+	return &throttle, nil
+}
+
+// Увеличивает счётчик неудач и возвращает его новое состояние
+// Одним запросом, чтобы одновременные попытки не терялись. Если последняя неудача
+// и конец блокировки раньше windowStart, счётчик начинается заново
+func (r *loginThrottleRepository) RecordLoginFailure(ctx context.Context, scope, subject string, now, windowStart time.Time) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	result := r.db.WithContext(ctx).Raw(`INSERT INTO login_throttles (scope, subject, failures, last_failure_at) VALUES (?, ?, 1, ?)
+ON CONFLICT (scope, subject) DO UPDATE SET
+    failures = CASE
+        WHEN GREATEST(login_throttles.last_failure_at, COALESCE(login_throttles.locked_until, login_throttles.last_failure_at)) < ? THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING scope, subject, failures, last_failure_at, locked_until`, scope, subject, now, windowStart).Scan(&throttle)
+	if result.Error != nil {
+		utils.Error("Failed to record login failure %s: %v", scope, result.Error)
+		return nil, errors.New("failed to record login failure: " + result.Error.Error())
+	}
+	return &throttle, nil
+}
+
+// Запрещает вход для ключа до указанного момента
+func (r *loginThrottleRepository) LockLogin(ctx context.Context, scope, subject string, until time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.LoginThrottle{}).
+		Where("scope = ? AND subject = ?", scope, subject).
+		Update("locked_until", until)
+	if result.Error != nil {
+		utils.Error("Failed to lock login %s: %v", scope, result.Error)
+		return errors.New("failed to lock login: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Удаляет счётчик, снимая блокировку
+func (r *loginThrottleRepository) DeleteLoginThrottle(ctx context.Context, scope, subject string) error {
+	result := r.db.WithContext(ctx).Where("scope = ? AND subject = ?", scope, subject).Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		utils.Error("Failed to delete login throttle %s: %v", scope, result.Error)
+		return errors.New("failed to delete login throttle: " + result.Error.Error())
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория двухфакторной аутентификации для работы с БД
+type MFARepository interface {
+	// Возвращает TOTP-фактор пользователя
+	GetTOTPFactor(ctx context.Context, userID uint) (*models.TOTPFactor, error)
+	// Сохраняет новый неподтверждённый фактор, заменяя прежний неподтверждённый
+	SaveTOTPFactor(ctx context.Context, factor *models.TOTPFactor) error
+	// Подтверждает фактор и заменяет коды восстановления пользователя
+	EnableTOTPFactor(ctx context.Context, userID uint, step int64, codeHashes []string) error
+	// Запоминает шаг принятого кода, если он новее последнего принятого
+	UseTOTPStep(ctx context.Context, userID uint, step int64) error
+	// Удаляет фактор и коды восстановления пользователя
+	DeleteTOTPFactor(ctx context.Context, userID uint) error
+	// Помечает код восстановления использованным, если он ещё не использован
+	ConsumeRecoveryCode(ctx context.Context, userID uint, codeHash string) error
+}
+
+// Реализация репозитория двухфакторной аутентификации на GORM
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория двухфакторной аутентификации
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// Возвращает TOTP-фактор пользователя
+func (r *mfaRepository) GetTOTPFactor(ctx context.Context, userID uint) (*models.TOTPFactor, error) {
+	var factor models.TOTPFactor
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&factor)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get TOTP factor of user_id=%d: %v", userID, result.Error)
+		return nil, errors.New("failed to get TOTP factor: " + result.Error.Error())
+	}
+	return &factor, nil
+}
+
+// Сохраняет новый неподтверждённый фактор, заменяя прежний неподтверждённый
+// Подтверждённый фактор не заменяется: вставка нарушит уникальность user_id
+func (r *mfaRepository) SaveTOTPFactor(ctx context.Context, factor *models.TOTPFactor) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND confirmed_at IS NULL", factor.UserID).Delete(&models.TOTPFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(factor).Error
+	})
+	if err != nil {
+		utils.Error("Failed to save TOTP factor of user_id=%d: %v", factor.UserID, err)
+		return errors.New("failed to save TOTP factor: " + err.Error())
+	}
+	return nil
+}
+
+// Подтверждает фактор и заменяет коды восстановления пользователя
+// Если неподтверждённого фактора нет, возвращает gorm.ErrRecordNotFound
+func (r *mfaRepository) EnableTOTPFactor(ctx context.Context, userID uint, step int64, codeHashes []string) error {
+	now := time.Now().UTC()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TOTPFactor{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]interface{}{"confirmed_at": now, "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		utils.Error("Failed to enable TOTP factor of user_id=%d: %v", userID, err)
+		return errors.New("failed to enable TOTP factor: " + err.Error())
+	}
+	return nil
+}
+
+// Запоминает шаг принятого кода, если он новее последнего принятого
+// Условие last_used_step < step защищает от повторного использования кода, в том числе
+// при одновременных запросах: повтор получает gorm.ErrRecordNotFound
+func (r *mfaRepository) UseTOTPStep(ctx context.Context, userID uint, step int64) error {
+	result := r.db.WithContext(ctx).Model(&models.TOTPFactor{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		utils.Error("Failed to record TOTP step of user_id=%d: %v", userID, result.Error)
+		return errors.New("failed to record TOTP step: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Удаляет фактор и коды восстановления пользователя
+func (r *mfaRepository) DeleteTOTPFactor(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.TOTPFactor{}).Error
+	})
+	if err != nil {
+		utils.Error("Failed to delete TOTP factor of user_id=%d: %v", userID, err)
+		return errors.New("failed to delete TOTP factor: " + err.Error())
+	}
+	return nil
+}
+
+// Помечает код восстановления использованным, если он ещё не использован
+// Неизвестный или уже использованный код даёт gorm.ErrRecordNotFound
+func (r *mfaRepository) ConsumeRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to consume recovery code of user_id=%d: %v", userID, result.Error)
+		return errors.New("failed to consume recovery code: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория клиентов OAuth2 для работы с БД
+type OAuthClientRepository interface {
+	// Сохраняет нового клиента
+	CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error
+	// Возвращает клиента по публичному идентификатору client_id
+	GetOAuthClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	// Возвращает неотозванных клиентов, новые первыми
+	ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error)
+	// Отзывает клиента, если он ещё не отозван
+	RevokeOAuthClient(ctx context.Context, id uint) error
+	// Запоминает время последней выдачи токена клиенту
+	TouchOAuthClient(ctx context.Context, id uint, usedAt time.Time) error
+}
+
+// Реализация репозитория клиентов OAuth2 на GORM
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория клиентов OAuth2
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+// Сохраняет нового клиента
+func (r *oauthClientRepository) CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error {
+	result := r.db.WithContext(ctx).Create(client)
+	if result.Error != nil {
+		utils.Error("Failed to create OAuth client in DB: %v", result.Error)
+		return errors.New("failed to create OAuth client: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Возвращает клиента по публичному идентификатору client_id
+func (r *oauthClientRepository) GetOAuthClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	result := r.db.WithContext(ctx).Where("client_id = ?", clientID).First(&client)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get OAuth client by client_id=%s: %v", clientID, result.Error)
+		return nil, errors.New("failed to get OAuth client: " + result.Error.Error())
+	}
+	return &client, nil
+}
+
+// Возвращает неотозванных клиентов, новые первыми
+func (r *oauthClientRepository) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	result := r.db.WithContext(ctx).Where("revoked_at IS NULL").Order("id DESC").Find(&clients)
+	if result.Error != nil {
+		utils.Error("Failed to list OAuth clients: %v", result.Error)
+		return nil, errors.New("failed to list OAuth clients: " + result.Error.Error())
+	}
+	return clients, nil
+}
+
+// Отзывает клиента, если он ещё не отозван
+// Неизвестный или уже отозванный клиент даёт gorm.ErrRecordNotFound
+func (r *oauthClientRepository) RevokeOAuthClient(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.OAuthClient{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to revoke OAuth client id=%d: %v", id, result.Error)
+		return errors.New("failed to revoke OAuth client: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Запоминает время последней выдачи токена клиенту
+func (r *oauthClientRepository) TouchOAuthClient(ctx context.Context, id uint, usedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.OAuthClient{}).Where("id = ?", id).Update("last_used_at", usedAt)
+	if result.Error != nil {
+		utils.Error("Failed to update last use of OAuth client id=%d: %v", id, result.Error)
+		return errors.New("failed to update OAuth client: " + result.Error.Error())
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория refresh-токенов для работы с БД
+type RefreshTokenRepository interface {
+	// Сохраняет новый refresh-токен
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// Возвращает refresh-токен по хешу
+	GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// Отзывает refresh-токен, если он ещё не отозван
+	RevokeRefreshToken(ctx context.Context, id uint) error
+	// Отзывает все токены семейства
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	// Отзывает все токены пользователя
+	RevokeUserRefreshTokens(ctx context.Context, userID uint) error
+}
+
+// Реализация репозитория refresh-токенов на GORM
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория refresh-токенов
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+// Сохраняет новый refresh-токен
+func (r *refreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	result := r.db.WithContext(ctx).Create(token)
+	if result.Error != nil {
+		utils.Error("Failed to create refresh token in DB: %v", result.Error)
+		return errors.New("failed to create refresh token: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Возвращает refresh-токен по хешу
+func (r *refreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	result := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get refresh token by hash: %v", result.Error)
+		return nil, errors.New("failed to get refresh token: " + result.Error.Error())
+	}
+	return &token, nil
+}
+
+// Отзывает refresh-токен, если он ещё не отозван
+// Условие revoked_at IS NULL делает ротацию атомарной: при гонке двух запросов
+// только один из них получит RowsAffected = 1, второй получит gorm.ErrRecordNotFound
+func (r *refreshTokenRepository) RevokeRefreshToken(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		utils.Error("Failed to revoke refresh token id=%d: %v", id, result.Error)
+		return errors.New("failed to revoke refresh token: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Отзывает все токены семейства
+func (r *refreshTokenRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	result := r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		utils.Error("Failed to revoke refresh token family %s: %v", familyID, result.Error)
+		return errors.New("failed to revoke refresh token family: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Отзывает все токены пользователя
+func (r *refreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID uint) error {
+	result := r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		utils.Error("Failed to revoke refresh tokens of user_id=%d: %v", userID, result.Error)
+		return errors.New("failed to revoke user refresh tokens: " + result.Error.Error())
+	}
+	return nil
+}
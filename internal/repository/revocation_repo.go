@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Интерфейс репозитория отозванных токенов для работы с БД
+type RevocationRepository interface {
+	// Сохраняет отзыв отдельного токена по его jti
+	RevokeToken(ctx context.Context, token *models.RevokedToken) error
+	// Сохраняет (или сдвигает) отметку массового отзыва токенов пользователя
+	RevokeUserTokens(ctx context.Context, revocation *models.UserTokenRevocation) error
+	// Сохраняет отзыв всех токенов сессии
+	RevokeSession(ctx context.Context, revocation *models.RevokedSession) error
+	// Возвращает отзывы токенов, срок действия которых ещё не истёк
+	ListActiveRevokedTokens(ctx context.Context, now time.Time) ([]models.RevokedToken, error)
+	// Возвращает массовые отзывы, которые ещё действуют
+	ListActiveUserRevocations(ctx context.Context, now time.Time) ([]models.UserTokenRevocation, error)
+	// Возвращает отзывы сессий, которые ещё действуют
+	ListActiveRevokedSessions(ctx context.Context, now time.Time) ([]models.RevokedSession, error)
+	// Удаляет истёкшие записи
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// Реализация репозитория отозванных токенов на GORM
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория отозванных токенов
+func NewRevocationRepository(db *gorm.DB) RevocationRepository {
+	return &revocationRepository{db: db}
+}
+
+// Сохраняет отзыв отдельного токена; повторный отзыв того же jti игнорируется
+func (r *revocationRepository) RevokeToken(ctx context.Context, token *models.RevokedToken) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(token)
+	if result.Error != nil {
+		utils.Error("Failed to revoke token jti=%s: %v", token.JTI, result.Error)
+		return errors.New("failed to revoke token: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Сохраняет (или сдвигает) отметку массового отзыва токенов пользователя
+func (r *revocationRepository) RevokeUserTokens(ctx context.Context, revocation *models.UserTokenRevocation) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "expires_at"}),
+	}).Create(revocation)
+	if result.Error != nil {
+		utils.Error("Failed to revoke tokens of user_id=%d: %v", revocation.UserID, result.Error)
+		return errors.New("failed to revoke user tokens: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Сохраняет отзыв всех токенов сессии; повторный отзыв той же сессии игнорируется
+func (r *revocationRepository) RevokeSession(ctx context.Context, revocation *models.RevokedSession) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(revocation)
+	if result.Error != nil {
+		utils.Error("Failed to revoke tokens of session id=%d: %v", revocation.SessionID, result.Error)
+		return errors.New("failed to revoke session tokens: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Возвращает отзывы токенов, срок действия которых ещё не истёк
+func (r *revocationRepository) ListActiveRevokedTokens(ctx context.Context, now time.Time) ([]models.RevokedToken, error) {
+	var tokens []models.RevokedToken
+	result := r.db.WithContext(ctx).Where("expires_at > ?", now).Find(&tokens)
+	if result.Error != nil {
+		utils.Error("Failed to list revoked tokens: %v", result.Error)
+		return nil, errors.New("failed to list revoked tokens: " + result.Error.Error())
+	}
+	return tokens, nil
+}
+
+// Возвращает массовые отзывы, которые ещё действуют
+func (r *revocationRepository) ListActiveUserRevocations(ctx context.Context, now time.Time) ([]models.UserTokenRevocation, error) {
+	var revocations []models.UserTokenRevocation
+	result := r.db.WithContext(ctx).Where("expires_at > ?", now).Find(&revocations)
+	if result.Error != nil {
+		utils.Error("Failed to list user token revocations: %v", result.Error)
+		return nil, errors.New("failed to list user token revocations: " + result.Error.Error())
+	}
+	return revocations, nil
+}
+
+// Возвращает отзывы сессий, которые ещё действуют
+func (r *revocationRepository) ListActiveRevokedSessions(ctx context.Context, now time.Time) ([]models.RevokedSession, error) {
+	var sessions []models.RevokedSession
+	result := r.db.WithContext(ctx).Where("expires_at > ?", now).Find(&sessions)
+	if result.Error != nil {
+		utils.Error("Failed to list revoked sessions: %v", result.Error)
+		return nil, errors.New("failed to list revoked sessions: " + result.Error.Error())
+	}
+	return sessions, nil
+}
+
+// Удаляет истёкшие записи
+func (r *revocationRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	if err := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		utils.Error("Failed to delete expired revoked tokens: %v", err)
+		return errors.New("failed to delete expired revoked tokens: " + err.Error())
+	}
+	if err := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.UserTokenRevocation{}).Error; err != nil {
+		utils.Error("Failed to delete expired user token revocations: %v", err)
+		return errors.New("failed to delete expired user token revocations: " + err.Error())
+	}
+	if err := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.RevokedSession{}).Error; err != nil {
+		utils.Error("Failed to delete expired revoked sessions: %v", err)
+		return errors.New("failed to delete expired revoked sessions: " + err.Error())
+	}
+	return nil
+}
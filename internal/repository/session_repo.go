@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Интерфейс репозитория сессий для работы с БД
+type SessionRepository interface {
+	// Сохраняет новую сессию
+	CreateSession(ctx context.Context, session *models.Session) error
+	// Возвращает сессию по ID
+	GetSessionByID(ctx context.Context, id uint) (*models.Session, error)
+	// Возвращает сессию по семейству refresh-токенов
+	GetSessionByFamilyID(ctx context.Context, familyID string) (*models.Session, error)
+	// Возвращает неотозванные и неистёкшие сессии пользователя, недавно активные первыми
+	ListUserSessions(ctx context.Context, userID uint, now time.Time) ([]models.Session, error)
+	// Сохраняет данные сессии после выдачи новой пары токенов
+	UpdateSession(ctx context.Context, session *models.Session) error
+	// Отзывает сессию пользователя, если она ещё не отозвана
+	RevokeSession(ctx context.Context, userID, id uint) error
+	// Отзывает сессию семейства refresh-токенов
+	RevokeSessionByFamilyID(ctx context.Context, familyID string) error
+	// Отзывает все сессии пользователя, кроме указанной (0 — все)
+	RevokeUserSessions(ctx context.Context, userID, exceptID uint) error
+}
+
+// Реализация репозитория сессий на GORM
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// Конструктор репозитория сессий
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Сохраняет новую сессию
+func (r *sessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	result := r.db.WithContext(ctx).Create(session)
+	if result.Error != nil {
+		utils.Error("Failed to create session in DB: %v", result.Error)
+		return errors.New("failed to create session: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Возвращает сессию по ID
+func (r *sessionRepository) GetSessionByID(ctx context.Context, id uint) (*models.Session, error) {
+	var session models.Session
+	result := r.db.WithContext(ctx).First(&session, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get session by ID=%d: %v", id, result.Error)
+		return nil, errors.New("failed to get session: " + result.Error.Error())
+	}
+	return &session, nil
+}
+
+// Возвращает сессию по семейству refresh-токенов
+func (r *sessionRepository) GetSessionByFamilyID(ctx context.Context, familyID string) (*models.Session, error) {
+	var session models.Session
+	result := r.db.WithContext(ctx).Where("family_id = ?", familyID).First(&session)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get session by family %s: %v", familyID, result.Error)
+		return nil, errors.New("failed to get session: " + result.Error.Error())
+	}
+	return &session, nil
+}
+
+// Возвращает неотозванные и неистёкшие сессии пользователя, недавно активные первыми
+func (r *sessionRepository) ListUserSessions(ctx context.Context, userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").Find(&sessions)
+	if result.Error != nil {
+		utils.Error("Failed to list sessions of user_id=%d: %v", userID, result.Error)
+		return nil, errors.New("failed to list sessions: " + result.Error.Error())
+	}
+	return sessions, nil
+}
+
+// Сохраняет данные сессии после выдачи новой пары токенов
+func (r *sessionRepository) UpdateSession(ctx context.Context, session *models.Session) error {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+		"family_id":    session.FamilyID,
+		"ip_address":   session.IPAddress,
+		"expires_at":   session.ExpiresAt,
+		"last_seen_at": session.LastSeenAt,
+	})
+	if result.Error != nil {
+		utils.Error("Failed to update session id=%d: %v", session.ID, result.Error)
+		return errors.New("failed to update session: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Отзывает сессию пользователя, если она ещё не отозвана
+// Чужая, неизвестная или уже отозванная сессия даёт gorm.ErrRecordNotFound
+func (r *sessionRepository) RevokeSession(ctx context.Context, userID, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to revoke session id=%d: %v", id, result.Error)
+		return errors.New("failed to revoke session: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Отзывает сессию семейства refresh-токенов
+func (r *sessionRepository) RevokeSessionByFamilyID(ctx context.Context, familyID string) error {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to revoke session of family %s: %v", familyID, result.Error)
+		return errors.New("failed to revoke session: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Отзывает все сессии пользователя, кроме указанной (0 — все)
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID, exceptID uint) error {
+	query := r.db.WithContext(ctx).Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptID != 0 {
+		query = query.Where("id <> ?", exceptID)
+	}
+	result := query.Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		utils.Error("Failed to revoke sessions of user_id=%d: %v", userID, result.Error)
+		return errors.New("failed to revoke sessions: " + result.Error.Error())
+	}
+	return nil
+}
@@ -3,22 +3,38 @@ package repository
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/utils"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrUserHasOrders = errors.New("user has orders")
+var ErrEmailTaken = errors.New("email is used by another user")
+
+// Код ошибки PostgreSQL при нарушении ограничения уникальности
+const uniqueViolationCode = "23505"
+
 // Интерфейс репозитория пользователей для работы с БД
 // Описывает методы для CRUD-операций и поиска пользователей
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *models.User) error
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 	GetUserByID(ctx context.Context, id uint) (*models.User, error)
-	ListUsers(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int64, error)
+	ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error)
 	UpdateUser(ctx context.Context, user *models.User) error
-	DeleteUser(ctx context.Context, id uint) error
+	UpdateUserFields(ctx context.Context, id uint, version int, fields map[string]interface{}) error
+	UpdatePasswordHash(ctx context.Context, id uint, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uint) error
+	DeleteUser(ctx context.Context, id uint, orderPolicy string) error
+	GetDeletedUserByID(ctx context.Context, id uint) (*models.User, error)
+	RestoreUser(ctx context.Context, id uint) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, orderPolicy string) (int64, error)
 }
 
 // Реализация репозитория пользователей на GORM
@@ -69,25 +85,29 @@ func (r *userRepository) GetUserByID(ctx context.Context, id uint) (*models.User
 	return &user, nil
 }
 
-// Возвращает список пользователей с пагинацией и фильтрацией по возрасту
-func (r *userRepository) ListUsers(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int64, error) {
-	// minAge/maxAge — фильтрация по возрасту
-	// page/limit — постраничный вывод
+// Возвращает страницу пользователей по спецификации выборки и общее число подходящих пользователей
+func (r *userRepository) ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error) {
 	var users []models.User
 	var total int64
-	query := r.db.WithContext(ctx).Model(&models.User{})
-	if minAge > 0 {
-		query = query.Where("age >= ?", minAge)
+	db := r.db.WithContext(ctx).Model(&models.User{})
+	if query.MinAge > 0 {
+		db = db.Where("age >= ?", query.MinAge)
+	}
+	if query.MaxAge > 0 {
+		db = db.Where("age <= ?", query.MaxAge)
+	}
+	if query.Search != "" {
+		pattern := "%" + escapeLike(query.Search) + "%"
+		db = db.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
 	}
-	if maxAge > 0 {
-		query = query.Where("age <= ?", maxAge)
+	if len(query.EmailDomains) > 0 {
+		db = db.Where("LOWER(SPLIT_PART(email, '@', 2)) IN ?", query.EmailDomains)
 	}
-	if err := query.Count(&total).Error; err != nil {
+	if err := db.Count(&total).Error; err != nil {
 		utils.Error("Failed to count users: %v", err)
 		return nil, 0, errors.New("failed to count users: " + err.Error())
 	}
-	offset := (page - 1) * limit
-	result := query.Offset(offset).Limit(limit).Find(&users)
+	result := applyListQuery(db, query.ListQuery).Find(&users)
 	if result.Error != nil {
 		utils.Error("Failed to list users: %v", result.Error)
 		return nil, 0, errors.New("failed to list users: " + result.Error.Error())
@@ -95,9 +115,41 @@ func (r *userRepository) ListUsers(ctx context.Context, page, limit, minAge, max
 	return users, total, nil
 }
 
-// Обновляет данные пользователя
+// Применяет к запросу сортировку и страницу из спецификации выборки
+// Поля сортировки должны быть заранее проверены по белому списку. В конец добавляется сортировка по id,
+// чтобы порядок был однозначным и записи не повторялись и не терялись при переходе между страницами
+func applyListQuery(db *gorm.DB, query models.ListQuery) *gorm.DB {
+	byID := false
+	for _, field := range query.Sort {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Field}, Desc: field.Desc})
+		byID = byID || field.Field == "id"
+	}
+	if !byID {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+	return db.Offset(query.Offset()).Limit(query.Limit)
+}
+
+// Экранирует спецсимволы LIKE, чтобы строка поиска совпадала буквально
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Обновляет данные пользователя, если его версия не изменилась с момента чтения
+// Условие WHERE version = ? защищает от потери изменений при одновременном редактировании;
+// при успехе версия увеличивается, и user.Version получает новое значение.
+// Отметка о подтверждении email записывается вместе с остальными полями: при смене email сервис её снимает.
+// Если пользователь удалён или его уже изменили, возвращает gorm.ErrRecordNotFound
 func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
-	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).Updates(user)
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND version = ?", user.ID, user.Version).
+		Updates(map[string]interface{}{
+			"name":              user.Name,
+			"email":             user.Email,
+			"age":               user.Age,
+			"email_verified_at": user.EmailVerifiedAt,
+			"version":           gorm.Expr("version + 1"),
+		})
 	if result.Error != nil {
 		utils.Error("Failed to update user in DB: %v", result.Error)
 		return errors.New("failed to update user: " + result.Error.Error())
@@ -105,18 +157,195 @@ func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) erro
 	if result.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
 	}
+	user.Version++
 	return nil
 }
 
-// Удаляет пользователя по ID
-func (r *userRepository) DeleteUser(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).Delete(&models.User{}, id)
+// Обновляет только переданные столбцы пользователя, если его версия не изменилась с момента чтения
+// В отличие от UpdateUser записывает и нулевые значения; версия увеличивается
+func (r *userRepository) UpdateUserFields(ctx context.Context, id uint, version int, fields map[string]interface{}) error {
+	updates := make(map[string]interface{}, len(fields)+1)
+	for column, value := range fields {
+		updates[column] = value
+	}
+	updates["version"] = gorm.Expr("version + 1")
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ? AND version = ?", id, version).Updates(updates)
 	if result.Error != nil {
-		utils.Error("Failed to delete user in DB: %v", result.Error)
-		return errors.New("failed to delete user: " + result.Error.Error())
+		utils.Error("Failed to update fields of user id=%d in DB: %v", id, result.Error)
+		return errors.New("failed to update user: " + result.Error.Error())
 	}
 	if result.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
+
+// Обновляет хеш пароля пользователя
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id uint, passwordHash string) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password_hash": passwordHash,
+		"version":       gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		utils.Error("Failed to update password of user id=%d in DB: %v", id, result.Error)
+		return errors.New("failed to update password: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Отмечает email пользователя подтверждённым
+// Повторное подтверждение не меняет момент первого
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"email_verified_at": time.Now().UTC(),
+			"version":           gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		utils.Error("Failed to mark email verified for user id=%d in DB: %v", id, result.Error)
+		return errors.New("failed to mark email verified: " + result.Error.Error())
+	}
+	return nil
+}
+
+// Мягко удаляет пользователя по ID: строка остаётся в БД с отметкой deleted_at
+// При orderPolicy restrict пользователь с заказами не удаляется и возвращается ErrUserHasOrders.
+// Проверка заказов и удаление выполняются в одной транзакции под блокировкой строки пользователя:
+// проверка внешнего ключа при создании заказа блокирует ту же строку, поэтому заказ не появится между ними
+func (r *userRepository) DeleteUser(ctx context.Context, id uint, orderPolicy string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if orderPolicy == models.OrderPolicyRestrict {
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, id).Error; err != nil {
+				return err
+			}
+			var orders int64
+			if err := tx.Model(&models.Order{}).Where("user_id = ?", id).Count(&orders).Error; err != nil {
+				return err
+			}
+			if orders > 0 {
+				return ErrUserHasOrders
+			}
+		}
+		result := tx.Delete(&models.User{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrUserHasOrders) {
+			return err
+		}
+		utils.Error("Failed to delete user in DB: %v", err)
+		return errors.New("failed to delete user: " + err.Error())
+	}
+	return nil
+}
+
+// Получает мягко удалённого пользователя по ID
+// Возвращает nil, если пользователя нет или он не удалён
+func (r *userRepository) GetDeletedUserByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&user, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		utils.Error("Failed to get deleted user by id: %v", result.Error)
+		return nil, errors.New("failed to get deleted user by id: " + result.Error.Error())
+	}
+	return &user, nil
+}
+
+// Восстанавливает мягко удалённого пользователя
+// Если пользователь не удалён или уже удалён окончательно, возвращает gorm.ErrRecordNotFound.
+// Если его email занял другой неудалённый пользователь, уникальный индекс отклоняет запись и возвращается ErrEmailTaken
+func (r *userRepository) RestoreUser(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(result.Error, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return ErrEmailTaken
+		}
+		utils.Error("Failed to restore user id=%d in DB: %v", id, result.Error)
+		return errors.New("failed to restore user: " + result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Данные, принадлежащие пользователю: при окончательном удалении пользователя они удаляются вместе с ним
+var userOwnedModels = []interface{}{
+	&models.Session{},
+	&models.RefreshToken{},
+	&models.APIKey{},
+	&models.TOTPFactor{},
+	&models.RecoveryCode{},
+	&models.ActionToken{},
+	&models.RevokedToken{},
+	&models.UserTokenRevocation{},
+	&models.RevokedSession{},
+}
+
+// Окончательно удаляет пользователей, мягко удалённых раньше deletedBefore
+// Строки удаляемых пользователей сначала блокируются, и все операции выполняются по этому списку ID:
+// пользователь, восстановленный или получивший заказ после выборки, не удаляется наполовину.
+// Заказы удаляемых пользователей обрабатываются по orderPolicy в той же транзакции:
+// при restrict пользователи с заказами пропускаются, при cascade заказы удаляются,
+// при anonymize у заказов обнуляется user_id. Сессии, токены, API-ключи и факторы MFA
+// удаляются вместе с пользователем, чтобы не оставалось строк без владельца. Возвращает число удалённых пользователей
+func (r *userRepository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, orderPolicy string) (int64, error) {
+	var purged int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Unscoped().Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore)
+		if orderPolicy == models.OrderPolicyRestrict {
+			query = query.Where("NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id)")
+		}
+		var ids []uint
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		switch orderPolicy {
+		case models.OrderPolicyCascade:
+			if err := tx.Where("user_id IN ?", ids).Delete(&models.Order{}).Error; err != nil {
+				return err
+			}
+		case models.OrderPolicyAnonymize:
+			if err := tx.Model(&models.Order{}).Where("user_id IN ?", ids).Update("user_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range userOwnedModels {
+			if err := tx.Where("user_id IN ?", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.User{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		utils.Error("Failed to purge deleted users in DB: %v", err)
+		return 0, errors.New("failed to purge deleted users: " + err.Error())
+	}
+	return purged, nil
+}
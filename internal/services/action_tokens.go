@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Размер одноразового токена действия в байтах (до кодирования в base64url)
+const actionTokenSize = 32
+
+// Выпускает одноразовый токен действия и сохраняет его хеш
+// Прежние неиспользованные токены пользователя с тем же назначением становятся недействительными
+func issueActionToken(ctx context.Context, repo repository.ActionTokenRepository, userID uint, purpose string, ttl time.Duration) (string, error) {
+	if err := repo.InvalidateUserActionTokens(ctx, userID, purpose); err != nil {
+		return "", fmt.Errorf("failed to invalidate previous %s tokens of user id=%d: %w", purpose, userID, err)
+	}
+	token, err := utils.GenerateOpaqueToken(actionTokenSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate %s token for user id=%d: %w", purpose, userID, err)
+	}
+	stored := &models.ActionToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+	if err := repo.CreateActionToken(ctx, stored); err != nil {
+		return "", fmt.Errorf("failed to store %s token for user id=%d: %w", purpose, userID, err)
+	}
+	return token, nil
+}
+
+// Проверяет одноразовый токен действия и атомарно помечает его использованным
+// Неизвестный, использованный или истёкший токен даёт ошибку invalidErr
+func consumeActionToken(ctx context.Context, repo repository.ActionTokenRepository, purpose, token string, invalidErr error) (*models.ActionToken, error) {
+	stored, err := findActionToken(ctx, repo, purpose, token, invalidErr)
+	if err != nil {
+		return nil, err
+	}
+	if err := useActionToken(ctx, repo, stored, invalidErr); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Находит действующий токен действия, не помечая его использованным
+// Неизвестный, использованный или истёкший токен даёт ошибку invalidErr
+func findActionToken(ctx context.Context, repo repository.ActionTokenRepository, purpose, token string, invalidErr error) (*models.ActionToken, error) {
+	stored, err := repo.GetActionTokenByHash(ctx, purpose, utils.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s token: %w", purpose, err)
+	}
+	if stored == nil || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, invalidErr
+	}
+	return stored, nil
+}
+
+// Атомарно помечает найденный токен использованным
+// Проигравший в гонке запрос получает отказ invalidErr
+func useActionToken(ctx context.Context, repo repository.ActionTokenRepository, stored *models.ActionToken, invalidErr error) error {
+	if err := repo.ConsumeActionToken(ctx, stored.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalidErr
+		}
+		return fmt.Errorf("failed to consume %s token id=%d: %w", stored.Purpose, stored.ID, err)
+	}
+	return nil
+}
+
+// Формирует ссылку из письма: добавляет токен параметром token к адресу страницы
+func actionTokenLink(baseURL, token string) string {
+	link, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL + "?token=" + url.QueryEscape(token)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+var ErrAPIKeyNotFound = errors.New("API key not found")
+var ErrInvalidAPIKeyExpiry = errors.New("API key expiry must be in the future")
+
+// Параметры API-ключей
+const (
+	// Начало каждого ключа: по нему ключ легко найти в логах и репозиториях кода
+	apiKeyMarker = "uoa_"
+	// Размер случайной видимой части в байтах (до hex-кодирования)
+	apiKeyPrefixSize = 4
+	// Размер секретной части в байтах (до кодирования в base64url)
+	apiKeySecretSize = 32
+)
+
+// Созданный API-ключ: сам ключ показывается владельцу один раз
+type CreatedAPIKey struct {
+	Key    string
+	APIKey *models.APIKey
+}
+
+// Интерфейс сервиса персональных API-ключей
+type APIKeyService interface {
+	// Создаёт API-ключ пользователя с необязательными областями действия и сроком действия
+	CreateAPIKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (*CreatedAPIKey, error)
+	// Возвращает действующие API-ключи пользователя
+	ListAPIKeys(ctx context.Context, userID uint) ([]models.APIKey, error)
+	// Отзывает API-ключ пользователя
+	RevokeAPIKey(ctx context.Context, userID, keyID uint) error
+	// Проверяет ключ из заголовка Authorization и возвращает идентичность его владельца
+	Authenticate(ctx context.Context, key string) (Principal, error)
+}
+
+// Реализация сервиса API-ключей
+// Ключ имеет вид uoa_<видимая часть>_<секрет>; в БД хранятся видимая часть и SHA-256 хеш всего ключа.
+// Запрос с ключом выполняется с ролью владельца на момент запроса
+type apiKeyService struct {
+	userRepo repository.UserRepository
+	keyRepo  repository.APIKeyRepository
+	authz    Authorizer
+}
+
+// Конструктор сервиса API-ключей
+func NewAPIKeyService(userRepo repository.UserRepository, keyRepo repository.APIKeyRepository, authz Authorizer) APIKeyService {
+	return &apiKeyService{userRepo: userRepo, keyRepo: keyRepo, authz: authz}
+}
+
+// Создаёт API-ключ пользователя с необязательными областями действия и сроком действия
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (*CreatedAPIKey, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanCreateAPIKey(ctx, userID); err != nil {
+		return nil, err
+	}
+	// Ключ не может получить больше прав, чем токен, которым его создают
+	scopes, err := grantScopes(ctx, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	// Ключ со всеми областями хранится с пустым списком
+	if hasAllScopes(scopes) {
+		scopes = nil
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id=%d for API key creation: %w", userID, err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	prefixID, err := utils.GenerateRandomID(apiKeyPrefixSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key prefix for user id=%d: %w", userID, err)
+	}
+	secret, err := utils.GenerateOpaqueToken(apiKeySecretSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key for user id=%d: %w", userID, err)
+	}
+	prefix := apiKeyMarker + prefixID
+	key := prefix + "_" + secret
+	stored := &models.APIKey{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: utils.HashToken(key),
+		Scopes:  models.FormatScopes(scopes),
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		stored.ExpiresAt = &expiresAt
+	}
+	if err := s.keyRepo.CreateAPIKey(ctx, stored); err != nil {
+		return nil, fmt.Errorf("failed to store API key for user id=%d: %w", userID, err)
+	}
+	utils.Info("API key created: id=%d, prefix=%s, user_id=%d", stored.ID, stored.Prefix, userID)
+	return &CreatedAPIKey{Key: key, APIKey: stored}, nil
+}
+
+// Возвращает действующие API-ключи пользователя
+func (s *apiKeyService) ListAPIKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageAPIKeys(ctx, userID); err != nil {
+		return nil, err
+	}
+	keys, err := s.keyRepo.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys of user id=%d: %w", userID, err)
+	}
+	return keys, nil
+}
+
+// Отзывает API-ключ пользователя
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, userID, keyID uint) error {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageAPIKeys(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.keyRepo.RevokeAPIKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to revoke API key id=%d of user id=%d: %w", keyID, userID, err)
+	}
+	utils.Info("API key revoked: id=%d, user_id=%d", keyID, userID)
+	return nil
+}
+
+// Проверяет ключ из заголовка Authorization и возвращает идентичность его владельца
+// Неизвестный, отозванный и истёкший ключ, как и ключ удалённого пользователя, дают ErrInvalidAPIKey
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (Principal, error) {
+	if !strings.HasPrefix(key, apiKeyMarker) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	stored, err := s.keyRepo.GetAPIKeyByHash(ctx, utils.HashToken(key))
+	if err != nil {
+		return Principal{}, fmt.Errorf("failed to get API key: %w", err)
+	}
+	now := time.Now().UTC()
+	if stored == nil || stored.RevokedAt != nil || (stored.ExpiresAt != nil && now.After(*stored.ExpiresAt)) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	user, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return Principal{}, fmt.Errorf("failed to get owner of API key id=%d: %w", stored.ID, err)
+	}
+	if user == nil {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	// Время последнего использования носит справочный характер, сбой записи не мешает запросу
+	if err := s.keyRepo.TouchAPIKey(ctx, stored.ID, now); err != nil {
+		utils.Warn("Failed to record use of API key id=%d: %v", stored.ID, err)
+	}
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return Principal{UserID: user.ID, Role: role, APIKeyID: stored.ID, Scopes: stored.ScopeList()}, nil
+}
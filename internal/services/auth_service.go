@@ -2,44 +2,507 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
+	"unicode/utf8"
 
+	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/repository"
 	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
 )
 
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+// Размер refresh-токена в байтах (до кодирования в base64url)
+const refreshTokenSize = 32
+
+// Максимальная длина User-Agent, сохраняемого в сессии, в символах
+const maxSessionUserAgentLength = 255
+
+// Данные клиента, выполняющего вход или обновление токенов
+// IP-адрес используется для ограничения числа неудачных попыток, оба поля сохраняются в сессии
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// Пара токенов, выдаваемая при входе и при обновлении
+// AccessToken — короткоживущий JWT, RefreshToken — непрозрачный токен для ротации,
+// Scopes — области действия, выданные access-токену
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+	Scopes       []string
+}
+
+// Результат входа по паролю
+// Если у пользователя включена двухфакторная аутентификация, вместо токенов возвращается MFA
+type LoginResult struct {
+	Tokens *TokenPair
+	MFA    *MFAChallenge
+}
+
+// Настройки сервиса авторизации
+// RequireVerifiedEmail запрещает вход, пока пользователь не подтвердил email
+type AuthSettings struct {
+	RequireVerifiedEmail bool
+}
+
 // Интерфейс сервиса авторизации
 type AuthService interface {
-	// Выполняет вход пользователя по email и паролю, возвращает JWT-токен
-	Login(ctx context.Context, email, password string) (string, error)
+	// Выполняет вход пользователя по email и паролю, возвращает пару токенов
+	// или, если включена двухфакторная аутентификация, токен второго шага
+	// Каждый вход открывает сессию с данными клиента, scopes — запрошенные области действия (пустой список — все)
+	Login(ctx context.Context, email, password string, client ClientInfo, scopes []string) (*LoginResult, error)
+	// Завершает вход с двухфакторной аутентификацией: обменивает токен второго шага и код на пару токенов
+	LoginMFA(ctx context.Context, mfaToken, code string, client ClientInfo, scopes []string) (*TokenPair, error)
+	// Обменивает refresh-токен на новую пару токенов (ротация) и отмечает активность сессии
+	Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, error)
+	// Завершает текущий вход: отзывает access-токен и, если передан, refresh-токен
+	Logout(ctx context.Context, userID uint, jti string, expiresAt time.Time, refreshToken string) error
+	// Завершает все входы пользователя: отзывает все его access- и refresh-токены
+	LogoutAll(ctx context.Context, userID uint) error
+	// Завершает все входы пользователя, кроме текущего: отзывает все его токены
+	// и выдаёт вызывающему новую пару
+	RevokeOtherSessions(ctx context.Context, userID uint) (*TokenPair, error)
+	// Снимает блокировку входа, наложенную после неудачных попыток (только для администратора)
+	UnlockAccount(ctx context.Context, userID uint) error
 }
 
 // Реализация сервиса авторизации
 // Использует репозиторий пользователей для проверки данных
+// и репозиторий refresh-токенов для их хранения и ротации, репозиторий сессий — для учёта входов по устройствам
+// Хранилище отзывов используется для выхода из системы, сервис MFA — для второго шага входа,
+// защита от перебора — для блокировки входа после неудачных попыток
 type authService struct {
-	userRepo repository.UserRepository
+	userRepo    repository.UserRepository
+	refreshRepo repository.RefreshTokenRepository
+	sessionRepo repository.SessionRepository
+	revocations RevocationStore
+	mfa         MFAService
+	throttle    LoginThrottle
+	authz       Authorizer
+	settings    AuthSettings
 }
 
 // Конструктор сервиса авторизации
-func NewAuthService(userRepo repository.UserRepository) AuthService {
-	return &authService{userRepo: userRepo}
+func NewAuthService(userRepo repository.UserRepository, refreshRepo repository.RefreshTokenRepository, sessionRepo repository.SessionRepository, revocations RevocationStore, mfa MFAService, throttle LoginThrottle, authz Authorizer, settings AuthSettings) AuthService {
+	return &authService{userRepo: userRepo, refreshRepo: refreshRepo, sessionRepo: sessionRepo, revocations: revocations, mfa: mfa, throttle: throttle, authz: authz, settings: settings}
 }
 
-// Выполняет вход пользователя по email и паролю, возвращает JWT-токен
-func (s *authService) Login(ctx context.Context, email, password string) (string, error) {
+// Выполняет вход пользователя по email и паролю, возвращает пару токенов
+// или, если включена двухфакторная аутентификация, токен второго шага
+// Пока аккаунт или IP-адрес заблокированы после неудачных попыток, пароль не проверяется
+func (s *authService) Login(ctx context.Context, email, password string, client ClientInfo, scopes []string) (*LoginResult, error) {
+	// Неподдерживаемые области действия отклоняются до проверки пароля и не считаются неудачной попыткой
+	scopes, err := loginScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.Check(ctx, email, client.IP); err != nil {
+		return nil, err
+	}
 	// Получаем пользователя по email
 	user, err := s.userRepo.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", fmt.Errorf("database error during login for email %s: %w", email, err)
+		return nil, fmt.Errorf("database error during login for email %s: %w", email, err)
+	}
+	// Проверяем пароль; для неизвестного email сравниваем с хешем-заглушкой,
+	// чтобы по времени ответа нельзя было узнать, зарегистрирован ли адрес
+	var passwordOK bool
+	if user == nil {
+		passwordOK = utils.CheckDummyPasswordHash(password)
+	} else {
+		passwordOK = utils.CheckPasswordHash(password, user.PasswordHash)
+	}
+	if !passwordOK {
+		s.recordLoginFailure(ctx, email, client.IP)
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.throttle.RecordSuccess(ctx, email); err != nil {
+		utils.Warn("Failed to reset login failures after successful login: %v", err)
+	}
+	if utils.PasswordNeedsRehash(user.PasswordHash) {
+		s.rehashPassword(ctx, user, password)
+	}
+	// Проверяем подтверждение email только после пароля, чтобы не раскрывать статус чужих адресов
+	if s.settings.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+	// При включённой двухфакторной аутентификации токены выдаются только после кода
+	mfaEnabled, err := s.mfa.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check two-factor authentication for user id=%d: %w", user.ID, err)
+	}
+	if mfaEnabled {
+		challenge, err := s.mfa.StartChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start two-factor login for user id=%d: %w", user.ID, err)
+		}
+		return &LoginResult{MFA: challenge}, nil
+	}
+	tokens, err := s.startSession(ctx, user, scopes, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
+}
+
+// Завершает вход с двухфакторной аутентификацией: обменивает токен второго шага и код на пару токенов
+// Неверные коды учитываются в счётчике IP-адреса
+func (s *authService) LoginMFA(ctx context.Context, mfaToken, code string, client ClientInfo, scopes []string) (*TokenPair, error) {
+	scopes, err := loginScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.Check(ctx, "", client.IP); err != nil {
+		return nil, err
+	}
+	userID, err := s.mfa.CompleteChallenge(ctx, mfaToken, code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) || errors.Is(err, ErrInvalidMFAToken) {
+			s.recordLoginFailure(ctx, "", client.IP)
+		}
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id=%d for two-factor login: %w", userID, err)
+	}
+	if user == nil {
+		return nil, ErrInvalidMFAToken
+	}
+	return s.startSession(ctx, user, scopes, client)
+}
+
+// Обменивает refresh-токен на новую пару токенов (ротация) и отмечает активность сессии
+// Повторное использование уже обменянного токена считается признаком кражи:
+// в этом случае отзывается всё семейство токенов вместе с сессией
+func (s *authService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, error) {
+	// Ищем токен по хешу
+	stored, err := s.refreshRepo.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if stored == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	// Токен уже был обменян или отозван — повторное использование
+	if stored.RevokedAt != nil {
+		return nil, s.revokeFamilyOnReuse(ctx, stored)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	// Атомарно помечаем токен использованным; проигравший в гонке запрос считается повторным
+	if err := s.refreshRepo.RevokeRefreshToken(ctx, stored.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, s.revokeFamilyOnReuse(ctx, stored)
+		}
+		return nil, fmt.Errorf("failed to rotate refresh token id=%d: %w", stored.ID, err)
+	}
+	// Проверяем, что пользователь всё ещё существует
+	user, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id=%d for refresh: %w", stored.UserID, err)
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	// Новая пара получает те же области действия, что и весь вход; у токенов, выпущенных
+	// до появления областей действия, список пуст, и они сохраняют все права
+	scopes := models.ParseScopes(stored.Scopes)
+	if len(scopes) == 0 {
+		scopes = models.AllScopes
+	}
+	session, err := s.sessionRepo.GetSessionByFamilyID(ctx, stored.FamilyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session of token family %s: %w", stored.FamilyID, err)
+	}
+	if session == nil {
+		// Вход, выполненный до появления сессий, получает сессию при первом обновлении токенов
+		session, err = s.createSession(ctx, user, stored.FamilyID, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	session.IPAddress = client.IP
+	return s.issueTokens(ctx, user, session, scopes)
+}
+
+// Завершает текущий вход: отзывает access-токен, его сессию и, если передан, refresh-токен
+// Чужой или неизвестный refresh-токен молча игнорируется, чтобы выход был идемпотентным
+func (s *authService) Logout(ctx context.Context, userID uint, jti string, expiresAt time.Time, refreshToken string) error {
+	if err := s.revocations.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token of user id=%d: %w", userID, err)
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok && principal.SessionID != 0 {
+		session, err := s.sessionRepo.GetSessionByID(ctx, principal.SessionID)
+		if err != nil {
+			return fmt.Errorf("failed to get session id=%d for logout: %w", principal.SessionID, err)
+		}
+		if session != nil && session.UserID == userID {
+			if err := s.endSession(ctx, session.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	stored, err := s.refreshRepo.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		return fmt.Errorf("failed to get refresh token for logout: %w", err)
+	}
+	if stored == nil || stored.UserID != userID {
+		return nil
+	}
+	return s.endSession(ctx, stored.FamilyID)
+}
+
+// Завершает все входы пользователя: отзывает все его access- и refresh-токены и сессии
+func (s *authService) LogoutAll(ctx context.Context, userID uint) error {
+	return s.revokeAllTokens(ctx, userID, 0)
+}
+
+// Завершает все входы пользователя, кроме текущего: отзывает все его токены
+// и выдаёт вызывающему новую пару
+// Новый access-токен выпущен после отметки отзыва и поэтому остаётся действительным;
+// он получает те же области действия, что и токен вызывающего.
+// Текущая сессия сохраняется, но получает новое семейство refresh-токенов,
+// чтобы повторная отправка прежнего refresh-токена не отозвала новую пару
+func (s *authService) RevokeOtherSessions(ctx context.Context, userID uint) (*TokenPair, error) {
+	scopes, err := grantScopes(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.currentSession(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var keepSessionID uint
+	if current != nil {
+		keepSessionID = current.ID
+	}
+	if err := s.revokeAllTokens(ctx, userID, keepSessionID); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id=%d for new session: %w", userID, err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if current == nil {
+		return s.startSession(ctx, user, scopes, ClientInfo{})
+	}
+	current.FamilyID, err = utils.GenerateRandomID(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token family for user id=%d: %w", user.ID, err)
+	}
+	return s.issueTokens(ctx, user, current, scopes)
+}
+
+// Снимает блокировку входа, наложенную после неудачных попыток (только для администратора)
+func (s *authService) UnlockAccount(ctx context.Context, userID uint) error {
+	if err := s.authz.CanUnlockAccount(ctx, userID); err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user id=%d for unlock: %w", userID, err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.throttle.Unlock(ctx, user.Email); err != nil {
+		return fmt.Errorf("failed to unlock user id=%d: %w", userID, err)
+	}
+	return nil
+}
+
+// Пересчитывает хеш пароля текущим алгоритмом и параметрами
+// Вход не должен зависеть от успеха пересчёта, поэтому ошибка только логируется:
+// хеш будет пересчитан при следующем входе
+func (s *authService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		utils.Warn("Failed to rehash password of user id=%d: %v", user.ID, err)
+		return
+	}
+	if err := s.userRepo.UpdatePasswordHash(ctx, user.ID, hash); err != nil {
+		utils.Warn("Failed to store rehashed password of user id=%d: %v", user.ID, err)
+		return
+	}
+	user.PasswordHash = hash
+	utils.Info("Password hash of user id=%d upgraded", user.ID)
+}
+
+// Учитывает неудачную попытку входа
+// Ошибка учёта не должна менять ответ на неверные данные, поэтому только логируется
+func (s *authService) recordLoginFailure(ctx context.Context, email, clientIP string) {
+	if err := s.throttle.RecordFailure(ctx, email, clientIP); err != nil {
+		utils.Error("Failed to record failed login attempt: %v", err)
+	}
+}
+
+// Проверяет области действия, запрошенные при входе; пустой запрос даёт все области
+func loginScopes(requested []string) ([]string, error) {
+	scopes, err := normalizeScopes(requested)
+	if err != nil {
+		return nil, err
+	}
+	if len(scopes) == 0 {
+		return models.AllScopes, nil
+	}
+	return scopes, nil
+}
+
+// Начинает новый вход: каждый вход открывает новую сессию с новым семейством refresh-токенов
+func (s *authService) startSession(ctx context.Context, user *models.User, scopes []string, client ClientInfo) (*TokenPair, error) {
+	familyID, err := utils.GenerateRandomID(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token family for user id=%d: %w", user.ID, err)
+	}
+	session, err := s.createSession(ctx, user, familyID, client)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, session, scopes)
+}
+
+// Сохраняет сессию семейства refresh-токенов; токены сессии выпускает issueTokens
+func (s *authService) createSession(ctx context.Context, user *models.User, familyID string, client ClientInfo) (*models.Session, error) {
+	now := time.Now().UTC()
+	session := &models.Session{
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		UserAgent:  truncateUserAgent(client.UserAgent),
+		IPAddress:  client.IP,
+		ExpiresAt:  now.Add(utils.RefreshTokenExpiration()),
+		LastSeenAt: now,
+	}
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session for user id=%d: %w", user.ID, err)
+	}
+	return session, nil
+}
+
+// Возвращает действующую сессию, токеном которой выполнен запрос
+// Для токенов без сессии и для чужой или отозванной сессии возвращает nil
+func (s *authService) currentSession(ctx context.Context, userID uint) (*models.Session, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok || principal.SessionID == 0 {
+		return nil, nil
+	}
+	session, err := s.sessionRepo.GetSessionByID(ctx, principal.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session id=%d: %w", principal.SessionID, err)
+	}
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Отзывает семейство refresh-токенов, его сессию и все access-токены сессии (по claim sid)
+// Так завершается сессия при выходе и при повторном использовании refresh-токена:
+// ранее выданные токены сессии, в том числе украденные, перестают приниматься сразу
+func (s *authService) endSession(ctx context.Context, familyID string) error {
+	if err := s.refreshRepo.RevokeTokenFamily(ctx, familyID); err != nil {
+		return fmt.Errorf("failed to revoke token family %s: %w", familyID, err)
+	}
+	session, err := s.sessionRepo.GetSessionByFamilyID(ctx, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to get session of token family %s: %w", familyID, err)
+	}
+	if err := s.sessionRepo.RevokeSessionByFamilyID(ctx, familyID); err != nil {
+		return fmt.Errorf("failed to revoke session of token family %s: %w", familyID, err)
+	}
+	// У входа, выполненного до появления сессий, сессии нет, и его access-токены не содержат sid
+	if session == nil {
+		return nil
+	}
+	if err := s.revocations.RevokeSession(ctx, session.ID, session.UserID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens of session id=%d: %w", session.ID, err)
 	}
-	// Проверяем пароль
-	if user == nil || !utils.CheckPasswordHash(password, user.PasswordHash) {
-		return "", ErrInvalidCredentials
+	return nil
+}
+
+// Отзывает все токены и сессии пользователя, кроме указанной сессии (0 — все)
+// Access-токен сохранённой сессии тоже отзывается, вызывающий должен выдать ей новую пару
+func (s *authService) revokeAllTokens(ctx context.Context, userID, keepSessionID uint) error {
+	if err := s.refreshRepo.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user id=%d: %w", userID, err)
+	}
+	if err := s.sessionRepo.RevokeUserSessions(ctx, userID, keepSessionID); err != nil {
+		return fmt.Errorf("failed to revoke sessions of user id=%d: %w", userID, err)
 	}
+	if err := s.revocations.RevokeAllForUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens of user id=%d: %w", userID, err)
+	}
+	return nil
+}
+
+// Отзывает семейство токенов и его сессию при обнаружении повторного использования
+func (s *authService) revokeFamilyOnReuse(ctx context.Context, stored *models.RefreshToken) error {
+	if err := s.endSession(ctx, stored.FamilyID); err != nil {
+		return err
+	}
+	return fmt.Errorf("refresh token id=%d of user id=%d used twice: %w", stored.ID, stored.UserID, ErrRefreshTokenReused)
+}
+
+// Выпускает access-токен и новый refresh-токен в семействе сессии и отмечает активность сессии
+// Роль берётся из текущих данных пользователя, поэтому её смена вступает в силу при обновлении токенов.
+// Области действия сохраняются в refresh-токене, чтобы ротация их не расширяла
+func (s *authService) issueTokens(ctx context.Context, user *models.User, session *models.Session, scopes []string) (*TokenPair, error) {
 	// Генерируем JWT-токен
-	token, err := utils.GenerateJWT(user.ID)
+	accessToken, _, err := utils.GenerateSessionJWT(user.ID, user.Role, scopes, session.ID)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate JWT for user id=%d: %w", user.ID, err)
+		return nil, fmt.Errorf("failed to generate JWT for user id=%d: %w", user.ID, err)
+	}
+	// Генерируем и сохраняем refresh-токен
+	refreshToken, err := utils.GenerateOpaqueToken(refreshTokenSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token for user id=%d: %w", user.ID, err)
+	}
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  session.FamilyID,
+		Scopes:    models.FormatScopes(scopes),
+		ExpiresAt: time.Now().UTC().Add(utils.RefreshTokenExpiration()),
+	}
+	if err := s.refreshRepo.CreateRefreshToken(ctx, stored); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token for user id=%d: %w", user.ID, err)
+	}
+	now := time.Now().UTC()
+	session.ExpiresAt = stored.ExpiresAt
+	session.LastSeenAt = now
+	if err := s.sessionRepo.UpdateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to update session id=%d: %w", session.ID, err)
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenExpiration().Seconds()),
+		Scopes:       scopes,
+	}, nil
+}
+
+// Обрезает User-Agent до длины, которая помещается в сессию, не разрывая символы
+func truncateUserAgent(userAgent string) string {
+	if utf8.RuneCountInString(userAgent) <= maxSessionUserAgentLength {
+		return userAgent
 	}
-	return token, nil
+	return string([]rune(userAgent)[:maxSessionUserAgentLength])
 }
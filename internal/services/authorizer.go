@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+)
+
+var ErrForbidden = errors.New("forbidden")
+var ErrImpersonationRestricted = errors.New("operation is not allowed while impersonating a user")
+
+// Идентичность вызывающего, извлечённая из токена или API-ключа
+// Кладётся в контекст запроса middleware авторизации; APIKeyID не равен нулю, если запрос выполнен по API-ключу.
+// ClientID не пуст у клиента OAuth2 (сервиса): у такого вызывающего нет UserID и роли.
+// Scopes — области действия токена или ключа; пустой список означает все области.
+// SessionID не равен нулю, если токен выдан в рамках сессии (входа на устройстве).
+// ActorID не равен нулю при имперсонации: это ID администратора, работающего от имени пользователя UserID
+type Principal struct {
+	UserID    uint
+	Role      string
+	APIKeyID  uint
+	ClientID  string
+	Scopes    []string
+	SessionID uint
+	ActorID   uint
+}
+
+// Признак сервиса, вызывающего API по токену клиента OAuth2, а не от имени пользователя
+func (p Principal) IsClient() bool {
+	return p.ClientID != ""
+}
+
+// Признак запроса, который администратор выполняет от имени пользователя
+func (p Principal) IsImpersonated() bool {
+	return p.ActorID != 0
+}
+
+// Признак администратора
+func (p Principal) IsAdmin() bool {
+	return p.Role == models.RoleAdmin
+}
+
+// Возвращает области действия вызывающего, раскрывая пустой список во все области
+func (p Principal) GrantedScopes() []string {
+	if len(p.Scopes) == 0 {
+		return models.AllScopes
+	}
+	return p.Scopes
+}
+
+// Ключ контекста для Principal
+type principalKey struct{}
+
+// Возвращает копию контекста с идентичностью вызывающего
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Извлекает идентичность вызывающего из контекста
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Интерфейс слоя авторизации
+// Все правила доступа к пользователям и заказам собраны здесь; при отказе
+// методы возвращают ошибку, обёртывающую ErrForbidden
+type Authorizer interface {
+	// Проверяет право изменять или удалять аккаунт пользователя
+	CanManageUser(ctx context.Context, userID uint) error
+	// Проверяет право просматривать заказы пользователя
+	CanViewOrders(ctx context.Context, userID uint) error
+	// Проверяет право создавать заказы от имени пользователя
+	CanCreateOrder(ctx context.Context, userID uint) error
+	// Проверяет право сменить пароль пользователя
+	CanChangePassword(ctx context.Context, userID uint) error
+	// Проверяет право подключать и отключать двухфакторную аутентификацию пользователя
+	CanManageMFA(ctx context.Context, userID uint) error
+	// Проверяет право снять блокировку входа с аккаунта пользователя
+	CanUnlockAccount(ctx context.Context, userID uint) error
+	// Проверяет право создать API-ключ пользователя
+	CanCreateAPIKey(ctx context.Context, userID uint) error
+	// Проверяет право просматривать и отзывать API-ключи пользователя
+	CanManageAPIKeys(ctx context.Context, userID uint) error
+	// Проверяет право регистрировать, просматривать и отзывать клиентов OAuth2
+	CanManageOAuthClients(ctx context.Context) error
+	// Проверяет право просматривать и завершать сессии пользователя
+	CanManageSessions(ctx context.Context, userID uint) error
+	// Проверяет право получить токен для работы от имени пользователя
+	CanImpersonate(ctx context.Context, userID uint) error
+	// Проверяет право восстановить удалённого пользователя
+	CanRestoreUser(ctx context.Context, userID uint) error
+}
+
+// Реализация авторизации на основе владельца ресурса и роли
+// Администраторы управляют любыми пользователями и видят любые заказы,
+// обычные пользователи — только свой аккаунт и свои заказы.
+// Создавать заказы, менять пароль и настраивать второй фактор можно только от своего имени,
+// снимать блокировку входа — только администратору.
+// Пароль, второй фактор и API-ключи нельзя менять по API-ключу; отозвать ключ может и администратор.
+// Клиенты OAuth2 (сервисы) только просматривают и создают заказы любых пользователей в пределах своих областей действия:
+// аккаунты, учётные данные и сессии пользователей им недоступны, как и действия администратора.
+// Администратор, работающий от имени пользователя, получает права этого пользователя, кроме управления учётными данными
+type authorizer struct{}
+
+// Конструктор слоя авторизации
+func NewAuthorizer() Authorizer {
+	return &authorizer{}
+}
+
+// Проверяет право изменять или удалять аккаунт пользователя
+func (a *authorizer) CanManageUser(ctx context.Context, userID uint) error {
+	return a.requireSelfOrAdmin(ctx, userID, "manage user")
+}
+
+// Проверяет право просматривать заказы пользователя
+func (a *authorizer) CanViewOrders(ctx context.Context, userID uint) error {
+	if isClient(ctx) {
+		return nil
+	}
+	return a.requireSelfOrAdmin(ctx, userID, "view orders of user")
+}
+
+// Проверяет право создавать заказы от имени пользователя
+func (a *authorizer) CanCreateOrder(ctx context.Context, userID uint) error {
+	if isClient(ctx) {
+		return nil
+	}
+	return a.requireSelf(ctx, userID, "create orders for user")
+}
+
+// Проверяет право сменить пароль пользователя
+// Смена требует текущего пароля, поэтому администратору она не доступна
+func (a *authorizer) CanChangePassword(ctx context.Context, userID uint) error {
+	return a.requireSelfWithoutAPIKey(ctx, userID, "change password of user")
+}
+
+// Проверяет право подключать и отключать двухфакторную аутентификацию пользователя
+func (a *authorizer) CanManageMFA(ctx context.Context, userID uint) error {
+	return a.requireSelfWithoutAPIKey(ctx, userID, "manage two-factor authentication of user")
+}
+
+// Проверяет право снять блокировку входа с аккаунта пользователя
+func (a *authorizer) CanUnlockAccount(ctx context.Context, userID uint) error {
+	return a.requireAdmin(ctx, userID, "unlock account of user")
+}
+
+// Проверяет право создать API-ключ пользователя
+func (a *authorizer) CanCreateAPIKey(ctx context.Context, userID uint) error {
+	return a.requireSelfWithoutAPIKey(ctx, userID, "create API keys for user")
+}
+
+// Проверяет право просматривать и отзывать API-ключи пользователя
+func (a *authorizer) CanManageAPIKeys(ctx context.Context, userID uint) error {
+	return a.requireSelfOrAdmin(ctx, userID, "manage API keys of user")
+}
+
+// Проверяет право регистрировать, просматривать и отзывать клиентов OAuth2
+// Регистрация клиента выдаёт новые учётные данные, поэтому по API-ключу она недоступна
+func (a *authorizer) CanManageOAuthClients(ctx context.Context) error {
+	principal, err := a.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if !principal.IsAdmin() || principal.APIKeyID != 0 {
+		return fmt.Errorf("%w: user %d with role %q cannot manage OAuth clients", ErrForbidden, principal.UserID, principal.Role)
+	}
+	return nil
+}
+
+// Проверяет право просматривать и завершать сессии пользователя
+func (a *authorizer) CanManageSessions(ctx context.Context, userID uint) error {
+	return a.requireSelfOrAdmin(ctx, userID, "manage sessions of user")
+}
+
+// Проверяет право получить токен для работы от имени пользователя
+// Доступно только администратору по его собственному токену: не по API-ключу и не из-под другой имперсонации.
+// Работать от своего имени через имперсонацию бессмысленно, поэтому это тоже запрещено
+func (a *authorizer) CanImpersonate(ctx context.Context, userID uint) error {
+	if err := a.requireAdmin(ctx, userID, "impersonate user"); err != nil {
+		return err
+	}
+	principal, _ := PrincipalFromContext(ctx)
+	if principal.APIKeyID != 0 || principal.IsImpersonated() || principal.UserID == userID {
+		return fmt.Errorf("%w: user %d cannot impersonate user %d with this token", ErrForbidden, principal.UserID, userID)
+	}
+	return nil
+}
+
+// Проверяет право восстановить удалённого пользователя
+// Удалить аккаунт может и сам пользователь, а восстановить — только администратор
+func (a *authorizer) CanRestoreUser(ctx context.Context, userID uint) error {
+	return a.requireAdmin(ctx, userID, "restore user")
+}
+
+// Общее правило «сам пользователь или администратор»
+func (a *authorizer) requireSelfOrAdmin(ctx context.Context, userID uint, action string) error {
+	principal, err := a.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsClient() {
+		return fmt.Errorf("%w: OAuth client %s cannot %s %d", ErrForbidden, principal.ClientID, action, userID)
+	}
+	if principal.UserID != userID && !principal.IsAdmin() {
+		return fmt.Errorf("%w: user %d with role %q cannot %s %d", ErrForbidden, principal.UserID, principal.Role, action, userID)
+	}
+	return nil
+}
+
+// Общее правило «только сам пользователь»
+func (a *authorizer) requireSelf(ctx context.Context, userID uint, action string) error {
+	principal, err := a.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsClient() {
+		return fmt.Errorf("%w: OAuth client %s cannot %s %d", ErrForbidden, principal.ClientID, action, userID)
+	}
+	if principal.UserID != userID {
+		return fmt.Errorf("%w: user %d cannot %s %d", ErrForbidden, principal.UserID, action, userID)
+	}
+	return nil
+}
+
+// Правило «только сам пользователь и не по API-ключу» для действий с учётными данными
+// Иначе утёкший ключ мог бы выпустить себе замену, получить новую пару токенов или сменить второй фактор.
+// Администраторы, работающие от имени пользователя, учётными данными пользователей не управляют
+func (a *authorizer) requireSelfWithoutAPIKey(ctx context.Context, userID uint, action string) error {
+	if err := a.requireSelf(ctx, userID, action); err != nil {
+		return err
+	}
+	principal, _ := PrincipalFromContext(ctx)
+	if principal.IsImpersonated() {
+		return fmt.Errorf("%w: %w: admin %d cannot %s %d", ErrForbidden, ErrImpersonationRestricted, principal.ActorID, action, userID)
+	}
+	if principal.APIKeyID != 0 {
+		return fmt.Errorf("%w: API key id=%d cannot %s %d", ErrForbidden, principal.APIKeyID, action, userID)
+	}
+	return nil
+}
+
+// Общее правило «только администратор»
+func (a *authorizer) requireAdmin(ctx context.Context, userID uint, action string) error {
+	principal, err := a.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if !principal.IsAdmin() {
+		return fmt.Errorf("%w: user %d with role %q cannot %s %d", ErrForbidden, principal.UserID, principal.Role, action, userID)
+	}
+	return nil
+}
+
+// Признак запроса от клиента OAuth2; клиенту доступны только действия, где это разрешено явно
+func isClient(ctx context.Context) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	return ok && principal.IsClient()
+}
+
+// Извлекает идентичность вызывающего; без неё любое действие запрещено
+func (a *authorizer) principal(ctx context.Context) (Principal, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: caller identity missing in context", ErrForbidden)
+	}
+	return principal, nil
+}
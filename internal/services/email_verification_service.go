@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/mail"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+var ErrEmailNotVerified = errors.New("email is not verified")
+
+// Настройки подтверждения email
+// VerifyURL — адрес, к которому добавляется параметр token; по умолчанию это GET /auth/verify-email
+type EmailVerificationSettings struct {
+	TokenTTL  time.Duration
+	VerifyURL string
+}
+
+// Интерфейс сервиса подтверждения email
+type EmailVerificationService interface {
+	// Отправляет пользователю письмо со ссылкой для подтверждения email
+	SendVerification(ctx context.Context, user *models.User) error
+	// Повторно отправляет письмо, если email зарегистрирован и ещё не подтверждён
+	ResendVerification(ctx context.Context, email string) error
+	// Подтверждает email по одноразовому токену из письма
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+// Реализация сервиса подтверждения email
+// Токены одноразовые, в БД хранится только их хеш
+type emailVerificationService struct {
+	userRepo   repository.UserRepository
+	actionRepo repository.ActionTokenRepository
+	mailer     mail.Mailer
+	settings   EmailVerificationSettings
+}
+
+// Конструктор сервиса подтверждения email
+func NewEmailVerificationService(userRepo repository.UserRepository, actionRepo repository.ActionTokenRepository, mailer mail.Mailer, settings EmailVerificationSettings) EmailVerificationService {
+	return &emailVerificationService{userRepo: userRepo, actionRepo: actionRepo, mailer: mailer, settings: settings}
+}
+
+// Отправляет пользователю письмо со ссылкой для подтверждения email
+// Новое письмо делает недействительными ссылки из прежних
+func (s *emailVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	token, err := issueActionToken(ctx, s.actionRepo, user.ID, models.ActionEmailVerification, s.settings.TokenTTL)
+	if err != nil {
+		return err
+	}
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo confirm your email address, open the link below. It is valid for %s.\n\n%s\n\nIf you did not sign up, ignore this email.",
+			user.Name, s.settings.TokenTTL, actionTokenLink(s.settings.VerifyURL, token)),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send verification email to user id=%d: %w", user.ID, err)
+	}
+	return nil
+}
+
+// Повторно отправляет письмо, если email зарегистрирован и ещё не подтверждён
+// Для неизвестного или уже подтверждённого email ошибка не возвращается,
+// чтобы по ответу нельзя было узнать, зарегистрирован ли адрес
+func (s *emailVerificationService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user for email verification: %w", err)
+	}
+	if user == nil || user.EmailVerifiedAt != nil {
+		utils.Info("Verification email not resent: email unknown or already verified")
+		return nil
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// Подтверждает email по одноразовому токену из письма
+func (s *emailVerificationService) VerifyEmail(ctx context.Context, token string) error {
+	stored, err := consumeActionToken(ctx, s.actionRepo, models.ActionEmailVerification, token, ErrInvalidVerificationToken)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.MarkEmailVerified(ctx, stored.UserID); err != nil {
+		return fmt.Errorf("failed to mark email verified for user id=%d: %w", stored.UserID, err)
+	}
+	return nil
+}
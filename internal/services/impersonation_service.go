@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+var ErrImpersonationNotAllowed = errors.New("user cannot be impersonated")
+
+// Токен, с которым администратор работает от имени пользователя
+// Refresh-токен не выдаётся: по истечении срока нужно запросить новый токен, что оставит новую запись в журнале
+type ImpersonationToken struct {
+	AccessToken string
+	ExpiresIn   int64
+	Scopes      []string
+}
+
+// Настройки имперсонации
+// TokenTTL — время жизни токена, выдаваемого администратору
+type ImpersonationSettings struct {
+	TokenTTL time.Duration
+}
+
+// Интерфейс сервиса имперсонации
+type ImpersonationService interface {
+	// Выдаёт администратору токен для работы от имени пользователя и записывает это в журнал
+	Impersonate(ctx context.Context, userID uint, reason string, client ClientInfo) (*ImpersonationToken, error)
+}
+
+// Реализация сервиса имперсонации
+// Токен получает роль пользователя и области действия токена администратора, а в claim act — ID администратора.
+// Администраторов имперсонировать нельзя, иначе имперсонация давала бы права другого администратора
+type impersonationService struct {
+	userRepo          repository.UserRepository
+	impersonationRepo repository.ImpersonationRepository
+	authz             Authorizer
+	settings          ImpersonationSettings
+}
+
+// Конструктор сервиса имперсонации
+func NewImpersonationService(userRepo repository.UserRepository, impersonationRepo repository.ImpersonationRepository, authz Authorizer, settings ImpersonationSettings) ImpersonationService {
+	return &impersonationService{userRepo: userRepo, impersonationRepo: impersonationRepo, authz: authz, settings: settings}
+}
+
+// Выдаёт администратору токен для работы от имени пользователя и записывает это в журнал
+// Токен возвращается только после сохранения записи в журнале
+func (s *impersonationService) Impersonate(ctx context.Context, userID uint, reason string, client ClientInfo) (*ImpersonationToken, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanImpersonate(ctx, userID); err != nil {
+		return nil, err
+	}
+	admin, _ := PrincipalFromContext(ctx)
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id=%d for impersonation: %w", userID, err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Role == models.RoleAdmin {
+		return nil, fmt.Errorf("%w: user id=%d is an administrator", ErrImpersonationNotAllowed, userID)
+	}
+	scopes, err := grantScopes(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, jti, err := utils.GenerateImpersonationJWT(user.ID, user.Role, scopes, admin.UserID, s.settings.TokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate impersonation token for user id=%d: %w", userID, err)
+	}
+	record := &models.Impersonation{
+		AdminID:   admin.UserID,
+		UserID:    user.ID,
+		Reason:    reason,
+		TokenID:   jti,
+		IPAddress: client.IP,
+		ExpiresAt: time.Now().UTC().Add(s.settings.TokenTTL),
+	}
+	if err := s.impersonationRepo.CreateImpersonation(ctx, record); err != nil {
+		return nil, fmt.Errorf("failed to record impersonation of user id=%d by admin %d: %w", userID, admin.UserID, err)
+	}
+	utils.Info("Admin %d started impersonating user id=%d: %s", admin.UserID, userID, reason)
+	return &ImpersonationToken{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(s.settings.TokenTTL.Seconds()),
+		Scopes:      scopes,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+var ErrAccountLocked = errors.New("account is temporarily locked")
+var ErrTooManyLoginAttempts = errors.New("too many login attempts")
+
+// Ошибка временной блокировки входа
+// Обёртывает ErrAccountLocked (блокировка аккаунта) или ErrTooManyLoginAttempts (блокировка IP-адреса)
+// и сообщает, через сколько можно повторить попытку
+type LoginLockedError struct {
+	RetryAfter time.Duration
+	Reason     error
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", e.Reason, e.RetryAfter)
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return e.Reason
+}
+
+// Настройки защиты входа от перебора
+// После AccountMaxFailures неудач подряд для аккаунта (IPMaxFailures — для IP-адреса)
+// вход блокируется на Lockout; каждая следующая неудача удваивает блокировку, но не дольше MaxLockout.
+// Счётчик забывается, если после последней неудачи и конца блокировки прошло FailureWindow
+type LoginThrottleSettings struct {
+	AccountMaxFailures int
+	IPMaxFailures      int
+	Lockout            time.Duration
+	MaxLockout         time.Duration
+	FailureWindow      time.Duration
+}
+
+// Интерфейс защиты входа от перебора паролей
+// Пустой email или IP-адрес пропускается
+type LoginThrottle interface {
+	// Проверяет, не заблокирован ли вход для email и IP-адреса
+	Check(ctx context.Context, email, ip string) error
+	// Учитывает неудачную попытку входа
+	RecordFailure(ctx context.Context, email, ip string) error
+	// Сбрасывает счётчик аккаунта после успешной проверки пароля
+	RecordSuccess(ctx context.Context, email string) error
+	// Снимает блокировку аккаунта
+	Unlock(ctx context.Context, email string) error
+}
+
+// Реализация защиты входа на счётчиках в БД
+// Счётчики общие для всех экземпляров приложения. Счётчик ведётся и для незарегистрированных email,
+// чтобы по блокировке нельзя было узнать, существует ли аккаунт
+type loginThrottle struct {
+	repo     repository.LoginThrottleRepository
+	settings LoginThrottleSettings
+}
+
+// Конструктор защиты входа от перебора
+func NewLoginThrottle(repo repository.LoginThrottleRepository, settings LoginThrottleSettings) LoginThrottle {
+	return &loginThrottle{repo: repo, settings: settings}
+}
+
+// Проверяет, не заблокирован ли вход для email и IP-адреса
+// Блокировка IP-адреса проверяется первой
+func (t *loginThrottle) Check(ctx context.Context, email, ip string) error {
+	if ip != "" {
+		if err := t.checkLock(ctx, models.LoginThrottleIP, ip, ErrTooManyLoginAttempts); err != nil {
+			return err
+		}
+	}
+	if email != "" {
+		if err := t.checkLock(ctx, models.LoginThrottleAccount, normalizeLoginEmail(email), ErrAccountLocked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Учитывает неудачную попытку входа
+func (t *loginThrottle) RecordFailure(ctx context.Context, email, ip string) error {
+	if email != "" {
+		if err := t.recordFailure(ctx, models.LoginThrottleAccount, normalizeLoginEmail(email), t.settings.AccountMaxFailures); err != nil {
+			return err
+		}
+	}
+	if ip != "" {
+		if err := t.recordFailure(ctx, models.LoginThrottleIP, ip, t.settings.IPMaxFailures); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Сбрасывает счётчик аккаунта после успешной проверки пароля
+// Счётчик IP-адреса не сбрасывается: иначе перебор по чужим аккаунтам можно было бы
+// прерывать входами в свой
+func (t *loginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	return t.Unlock(ctx, email)
+}
+
+// Снимает блокировку аккаунта
+func (t *loginThrottle) Unlock(ctx context.Context, email string) error {
+	if err := t.repo.DeleteLoginThrottle(ctx, models.LoginThrottleAccount, normalizeLoginEmail(email)); err != nil {
+		return fmt.Errorf("failed to reset login failures of account: %w", err)
+	}
+	return nil
+}
+
+// Возвращает LoginLockedError, если ключ заблокирован
+func (t *loginThrottle) checkLock(ctx context.Context, scope, subject string, reason error) error {
+	throttle, err := t.repo.GetLoginThrottle(ctx, scope, subject)
+	if err != nil {
+		return fmt.Errorf("failed to check %s login lock: %w", scope, err)
+	}
+	if throttle == nil || throttle.LockedUntil == nil {
+		return nil
+	}
+	if retryAfter := time.Until(*throttle.LockedUntil); retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter, Reason: reason}
+	}
+	return nil
+}
+
+// Увеличивает счётчик и при достижении порога блокирует ключ
+func (t *loginThrottle) recordFailure(ctx context.Context, scope, subject string, maxFailures int) error {
+	now := time.Now().UTC()
+	throttle, err := t.repo.RecordLoginFailure(ctx, scope, subject, now, now.Add(-t.settings.FailureWindow))
+	if err != nil {
+		return fmt.Errorf("failed to record %s login failure: %w", scope, err)
+	}
+	if throttle.Failures < maxFailures {
+		return nil
+	}
+	lockout := t.lockoutFor(throttle.Failures - maxFailures)
+	if err := t.repo.LockLogin(ctx, scope, subject, now.Add(lockout)); err != nil {
+		return fmt.Errorf("failed to lock %s login: %w", scope, err)
+	}
+	utils.Warn("Login locked for %s after %d failed attempts, lockout %s", scope, throttle.Failures, lockout)
+	return nil
+}
+
+// Длительность блокировки: Lockout, удваиваемая за каждую неудачу сверх порога, но не больше MaxLockout
+func (t *loginThrottle) lockoutFor(extraFailures int) time.Duration {
+	lockout := t.settings.Lockout
+	for i := 0; i < extraFailures && lockout < t.settings.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, t.settings.MaxLockout)
+}
+
+// Email сравнивается без учёта регистра и пробелов, чтобы варианты написания не обходили счётчик
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
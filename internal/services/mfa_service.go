@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+var ErrMFANotEnrolled = errors.New("two-factor authentication enrollment is not started")
+var ErrMFANotEnabled = errors.New("two-factor authentication is not enabled")
+var ErrInvalidMFACode = errors.New("invalid two-factor authentication code")
+var ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+
+// Параметры кодов восстановления и второго шага входа
+const (
+	// Количество кодов восстановления, выдаваемых при включении
+	recoveryCodeCount = 10
+	// Длина кода восстановления без дефиса
+	recoveryCodeLength = 10
+	// Сколько неверных кодов можно ввести по одному mfa-токену
+	mfaMaxAttempts = 5
+)
+
+// Алфавит кодов восстановления без похожих символов (0/o, 1/l/i)
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// Настройки двухфакторной аутентификации
+// Issuer — название сервиса в приложении-аутентификаторе, PendingTokenTTL — время на ввод кода при входе
+type MFASettings struct {
+	Issuer          string
+	PendingTokenTTL time.Duration
+}
+
+// Данные для добавления аккаунта в приложение-аутентификатор
+type TOTPEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// Незавершённый вход: токен, который вместе с кодом обменивается на пару токенов
+type MFAChallenge struct {
+	Token     string
+	ExpiresIn int64
+}
+
+// Интерфейс сервиса двухфакторной аутентификации
+type MFAService interface {
+	// Начинает подключение TOTP: создаёт секрет и URI для QR-кода
+	EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollment, error)
+	// Включает TOTP по первому коду из приложения, возвращает коды восстановления
+	ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error)
+	// Отключает TOTP по коду из приложения или коду восстановления
+	DisableTOTP(ctx context.Context, userID uint, code string) error
+	// Проверяет, включена ли у пользователя двухфакторная аутентификация
+	IsEnabled(ctx context.Context, userID uint) (bool, error)
+	// Выдаёт токен второго шага входа
+	StartChallenge(ctx context.Context, userID uint) (*MFAChallenge, error)
+	// Проверяет токен второго шага и код, возвращает ID пользователя
+	CompleteChallenge(ctx context.Context, token, code string) (uint, error)
+}
+
+// Реализация сервиса двухфакторной аутентификации
+// Секрет TOTP хранится в БД как есть, коды восстановления — только в виде хеша.
+// Токен второго шага входа — одноразовый токен действия с ограниченным числом попыток
+type mfaService struct {
+	userRepo   repository.UserRepository
+	mfaRepo    repository.MFARepository
+	actionRepo repository.ActionTokenRepository
+	authz      Authorizer
+	settings   MFASettings
+}
+
+// Конструктор сервиса двухфакторной аутентификации
+func NewMFAService(userRepo repository.UserRepository, mfaRepo repository.MFARepository, actionRepo repository.ActionTokenRepository, authz Authorizer, settings MFASettings) MFAService {
+	return &mfaService{userRepo: userRepo, mfaRepo: mfaRepo, actionRepo: actionRepo, authz: authz, settings: settings}
+}
+
+// Начинает подключение TOTP: создаёт секрет и URI для QR-кода
+// Повторный вызов до подтверждения заменяет секрет
+func (s *mfaService) EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollment, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageMFA(ctx, userID); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id=%d for TOTP enrollment: %w", userID, err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	factor, err := s.mfaRepo.GetTOTPFactor(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP factor of user id=%d: %w", userID, err)
+	}
+	if factor != nil && factor.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret for user id=%d: %w", userID, err)
+	}
+	if err := s.mfaRepo.SaveTOTPFactor(ctx, &models.TOTPFactor{UserID: userID, Secret: secret}); err != nil {
+		return nil, fmt.Errorf("failed to save TOTP factor of user id=%d: %w", userID, err)
+	}
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(secret, s.settings.Issuer, user.Email),
+	}, nil
+}
+
+// Включает TOTP по первому коду из приложения, возвращает коды восстановления
+// Коды показываются один раз, в БД сохраняются только их хеши
+func (s *mfaService) ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageMFA(ctx, userID); err != nil {
+		return nil, err
+	}
+	factor, err := s.mfaRepo.GetTOTPFactor(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP factor of user id=%d: %w", userID, err)
+	}
+	if factor == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	step, ok := utils.ValidateTOTP(factor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes for user id=%d: %w", userID, err)
+	}
+	if err := s.mfaRepo.EnableTOTPFactor(ctx, userID, step, hashes); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("failed to enable TOTP factor of user id=%d: %w", userID, err)
+	}
+	utils.Info("Two-factor authentication enabled for user id=%d", userID)
+	return codes, nil
+}
+
+// Отключает TOTP по коду из приложения или коду восстановления
+func (s *mfaService) DisableTOTP(ctx context.Context, userID uint, code string) error {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageMFA(ctx, userID); err != nil {
+		return err
+	}
+	factor, err := s.mfaRepo.GetTOTPFactor(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get TOTP factor of user id=%d: %w", userID, err)
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return ErrMFANotEnabled
+	}
+	if err := s.verifyCode(ctx, factor, code); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.DeleteTOTPFactor(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete TOTP factor of user id=%d: %w", userID, err)
+	}
+	utils.Info("Two-factor authentication disabled for user id=%d", userID)
+	return nil
+}
+
+// Проверяет, включена ли у пользователя двухфакторная аутентификация
+func (s *mfaService) IsEnabled(ctx context.Context, userID uint) (bool, error) {
+	factor, err := s.mfaRepo.GetTOTPFactor(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get TOTP factor of user id=%d: %w", userID, err)
+	}
+	return factor != nil && factor.ConfirmedAt != nil, nil
+}
+
+// Выдаёт токен второго шага входа
+// Новый вход делает недействительными прежние незавершённые
+func (s *mfaService) StartChallenge(ctx context.Context, userID uint) (*MFAChallenge, error) {
+	token, err := issueActionToken(ctx, s.actionRepo, userID, models.ActionMFALogin, s.settings.PendingTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{Token: token, ExpiresIn: int64(s.settings.PendingTokenTTL.Seconds())}, nil
+}
+
+// Проверяет токен второго шага и код, возвращает ID пользователя
+// Каждый неверный код расходует попытку; после mfaMaxAttempts попыток нужно снова ввести пароль
+func (s *mfaService) CompleteChallenge(ctx context.Context, token, code string) (uint, error) {
+	stored, err := s.actionRepo.GetActionTokenByHash(ctx, models.ActionMFALogin, utils.HashToken(token))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get mfa token: %w", err)
+	}
+	if stored == nil || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return 0, ErrInvalidMFAToken
+	}
+	factor, err := s.mfaRepo.GetTOTPFactor(ctx, stored.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get TOTP factor of user id=%d: %w", stored.UserID, err)
+	}
+	// Фактор могли отключить, пока шёл вход
+	if factor == nil || factor.ConfirmedAt == nil {
+		return 0, ErrInvalidMFAToken
+	}
+	if err := s.verifyCode(ctx, factor, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if recordErr := s.actionRepo.RecordActionTokenFailure(ctx, stored.ID, mfaMaxAttempts); recordErr != nil {
+				return 0, fmt.Errorf("failed to record failed mfa attempt: %w", recordErr)
+			}
+		}
+		return 0, err
+	}
+	// Проигравший в гонке запрос получает отказ
+	if err := s.actionRepo.ConsumeActionToken(ctx, stored.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrInvalidMFAToken
+		}
+		return 0, fmt.Errorf("failed to consume mfa token id=%d: %w", stored.ID, err)
+	}
+	return stored.UserID, nil
+}
+
+// Проверяет код из приложения или код восстановления
+// Код из приложения принимается не более одного раза, код восстановления расходуется
+func (s *mfaService) verifyCode(ctx context.Context, factor *models.TOTPFactor, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == utils.TOTPDigits {
+		step, ok := utils.ValidateTOTP(factor.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		if err := s.mfaRepo.UseTOTPStep(ctx, factor.UserID, step); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidMFACode
+			}
+			return fmt.Errorf("failed to record TOTP step of user id=%d: %w", factor.UserID, err)
+		}
+		return nil
+	}
+	if err := s.mfaRepo.ConsumeRecoveryCode(ctx, factor.UserID, utils.HashToken(normalizeRecoveryCode(code))); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidMFACode
+		}
+		return fmt.Errorf("failed to consume recovery code of user id=%d: %w", factor.UserID, err)
+	}
+	utils.Info("Recovery code used by user id=%d", factor.UserID)
+	return nil
+}
+
+// Генерирует коды восстановления вида xxxxx-xxxxx и их хеши
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	alphabetSize := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeLength)
+		for j := range raw {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return nil, nil, err
+			}
+			raw[j] = recoveryCodeAlphabet[n.Int64()]
+		}
+		code := string(raw[:recoveryCodeLength/2]) + "-" + string(raw[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// Приводит код восстановления к виду, в котором хранится хеш: без дефисов и пробелов, в нижнем регистре
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidClient = errors.New("invalid client credentials")
+var ErrOAuthClientNotFound = errors.New("OAuth client not found")
+
+// Параметры клиентов OAuth2
+const (
+	// Начало идентификатора клиента: отличает его от API-ключей и токенов в логах
+	oauthClientIDMarker = "uoc_"
+	// Размер случайной части идентификатора в байтах (до hex-кодирования)
+	oauthClientIDSize = 8
+	// Размер секрета клиента в байтах (до кодирования в base64url)
+	oauthClientSecretSize = 32
+)
+
+// Зарегистрированный клиент: секрет показывается администратору один раз
+type RegisteredOAuthClient struct {
+	Secret string
+	Client *models.OAuthClient
+}
+
+// Токен, выданный клиенту по grant client_credentials
+// Refresh-токен клиенту не выдаётся: за новым токеном он обращается с теми же учётными данными
+type ClientToken struct {
+	AccessToken string
+	ExpiresIn   int64
+	Scopes      []string
+}
+
+// Интерфейс сервиса OAuth2 для межсервисных вызовов
+type OAuthService interface {
+	// Регистрирует клиента с заданными областями действия (только для администратора)
+	RegisterClient(ctx context.Context, req *models.CreateOAuthClientRequest) (*RegisteredOAuthClient, error)
+	// Возвращает действующих клиентов (только для администратора)
+	ListClients(ctx context.Context) ([]models.OAuthClient, error)
+	// Отзывает клиента (только для администратора)
+	RevokeClient(ctx context.Context, id uint) error
+	// Выдаёт токен клиенту по grant client_credentials; scopes — запрошенные области действия (пустой список — все области клиента)
+	IssueClientToken(ctx context.Context, clientID, clientSecret string, scopes []string) (*ClientToken, error)
+}
+
+// Реализация сервиса OAuth2
+// Идентификатор клиента имеет вид uoc_<hex>, в БД хранится SHA-256 хеш секрета.
+// Токен клиента — JWT с client_id вместо user_id; отзыв клиента запрещает выдачу новых токенов,
+// уже выданные действуют до истечения срока access-токена
+type oauthService struct {
+	clientRepo repository.OAuthClientRepository
+	authz      Authorizer
+}
+
+// Конструктор сервиса OAuth2
+func NewOAuthService(clientRepo repository.OAuthClientRepository, authz Authorizer) OAuthService {
+	return &oauthService{clientRepo: clientRepo, authz: authz}
+}
+
+// Регистрирует клиента с заданными областями действия (только для администратора)
+func (s *oauthService) RegisterClient(ctx context.Context, req *models.CreateOAuthClientRequest) (*RegisteredOAuthClient, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageOAuthClients(ctx); err != nil {
+		return nil, err
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	idPart, err := utils.GenerateRandomID(oauthClientIDSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate OAuth client ID: %w", err)
+	}
+	secret, err := utils.GenerateOpaqueToken(oauthClientSecretSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate OAuth client secret: %w", err)
+	}
+	client := &models.OAuthClient{
+		ClientID:   oauthClientIDMarker + idPart,
+		Name:       req.Name,
+		SecretHash: utils.HashToken(secret),
+		Scopes:     models.FormatScopes(scopes),
+	}
+	if err := s.clientRepo.CreateOAuthClient(ctx, client); err != nil {
+		return nil, fmt.Errorf("failed to store OAuth client: %w", err)
+	}
+	utils.Info("OAuth client registered: id=%d, client_id=%s", client.ID, client.ClientID)
+	return &RegisteredOAuthClient{Secret: secret, Client: client}, nil
+}
+
+// Возвращает действующих клиентов (только для администратора)
+func (s *oauthService) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageOAuthClients(ctx); err != nil {
+		return nil, err
+	}
+	clients, err := s.clientRepo.ListOAuthClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OAuth clients: %w", err)
+	}
+	return clients, nil
+}
+
+// Отзывает клиента (только для администратора)
+func (s *oauthService) RevokeClient(ctx context.Context, id uint) error {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageOAuthClients(ctx); err != nil {
+		return err
+	}
+	if err := s.clientRepo.RevokeOAuthClient(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOAuthClientNotFound
+		}
+		return fmt.Errorf("failed to revoke OAuth client id=%d: %w", id, err)
+	}
+	utils.Info("OAuth client revoked: id=%d", id)
+	return nil
+}
+
+// Выдаёт токен клиенту по grant client_credentials
+// Неизвестный и отозванный клиент, как и неверный секрет, дают ErrInvalidClient;
+// область сверх зарегистрированных у клиента — ErrScopeNotGranted
+func (s *oauthService) IssueClientToken(ctx context.Context, clientID, clientSecret string, scopes []string) (*ClientToken, error) {
+	requested, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.clientRepo.GetOAuthClientByClientID(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OAuth client %s: %w", clientID, err)
+	}
+	if client == nil || client.RevokedAt != nil {
+		return nil, ErrInvalidClient
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	granted := client.ScopeList()
+	if len(requested) == 0 {
+		requested = granted
+	}
+	for _, scope := range requested {
+		if !slices.Contains(granted, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrScopeNotGranted, scope)
+		}
+	}
+	accessToken, err := utils.GenerateClientJWT(client.ClientID, requested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT for OAuth client %s: %w", client.ClientID, err)
+	}
+	// Время последнего использования носит справочный характер, сбой записи не мешает выдаче токена
+	if err := s.clientRepo.TouchOAuthClient(ctx, client.ID, time.Now().UTC()); err != nil {
+		utils.Warn("Failed to record use of OAuth client id=%d: %v", client.ID, err)
+	}
+	return &ClientToken{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(utils.AccessTokenExpiration().Seconds()),
+		Scopes:      requested,
+	}, nil
+}
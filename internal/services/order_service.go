@@ -8,6 +8,12 @@ import (
 	"github.com/iwtcode/user-order-api/internal/repository"
 )
 
+// Настройки сервиса заказов
+// RequireVerifiedEmail запрещает создавать заказы пользователю с неподтверждённым email
+type OrderSettings struct {
+	RequireVerifiedEmail bool
+}
+
 // Интерфейс сервиса заказов, описывает бизнес-логику работы с заказами
 type OrderService interface {
 	// Создаёт новый заказ для пользователя (асинхронно)
@@ -18,21 +24,29 @@ type OrderService interface {
 }
 
 // Реализация сервиса заказов
-// Использует репозитории заказов и пользователей
+// Использует репозитории заказов и пользователей и слой авторизации для проверки прав
 type orderService struct {
 	orderRepo repository.OrderRepository
 	userRepo  repository.UserRepository
+	authz     Authorizer
+	settings  OrderSettings
 }
 
 // Конструктор сервиса заказов
-func NewOrderService(orderRepo repository.OrderRepository, userRepo repository.UserRepository) OrderService {
-	return &orderService{orderRepo: orderRepo, userRepo: userRepo}
+func NewOrderService(orderRepo repository.OrderRepository, userRepo repository.UserRepository, authz Authorizer, settings OrderSettings) OrderService {
+	return &orderService{orderRepo: orderRepo, userRepo: userRepo, authz: authz, settings: settings}
 }
 
 // Создаёт новый заказ для пользователя (асинхронно)
 func (s *orderService) CreateOrder(ctx context.Context, userID uint, req *models.OrderCreateRequest) <-chan OrderResult {
 	resultChan := make(chan OrderResult, 1)
 	go func() {
+		// Проверяем права вызывающего
+		if err := s.authz.CanCreateOrder(ctx, userID); err != nil {
+			resultChan <- OrderResult{Order: nil, Err: err}
+			close(resultChan)
+			return
+		}
 		// Проверяем, существует ли пользователь
 		user, err := s.userRepo.GetUserByID(ctx, userID)
 		if err != nil {
@@ -45,6 +59,11 @@ func (s *orderService) CreateOrder(ctx context.Context, userID uint, req *models
 			close(resultChan)
 			return
 		}
+		if s.settings.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+			resultChan <- OrderResult{Order: nil, Err: ErrEmailNotVerified}
+			close(resultChan)
+			return
+		}
 		// Формируем структуру заказа
 		order := &models.Order{
 			UserID:   userID,
@@ -67,6 +86,10 @@ func (s *orderService) CreateOrder(ctx context.Context, userID uint, req *models
 
 // Возвращает список заказов пользователя по его ID
 func (s *orderService) ListOrdersByUserID(ctx context.Context, userID uint) ([]models.Order, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanViewOrders(ctx, userID); err != nil {
+		return nil, err
+	}
 	// Проверяем, существует ли пользователь
 	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
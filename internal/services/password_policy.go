@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// Правила парольной политики; имя правила возвращается клиенту в списке нарушений
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleMaxLength    = "max_length"
+	PasswordRuleUppercase    = "uppercase"
+	PasswordRuleLowercase    = "lowercase"
+	PasswordRuleDigit        = "digit"
+	PasswordRuleSymbol       = "symbol"
+	PasswordRulePersonalInfo = "personal_info"
+	PasswordRuleBreached     = "breached"
+)
+
+// Минимальная длина имени или части email, которую ищем в пароле
+// Более короткие фрагменты дают слишком много ложных срабатываний
+const minPersonalInfoLength = 3
+
+// Нарушение одного правила парольной политики
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Ошибка проверки пароля со списком всех нарушенных правил
+// Сравнивается с ErrWeakPassword через errors.Is
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return fmt.Sprintf("%s: %s", ErrWeakPassword, strings.Join(rules, ", "))
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// Настройки парольной политики
+// MinLength считается в символах, MaxBytes — в байтах: bcrypt учитывает только первые 72 байта пароля.
+// BreachedPasswordsFile — файл со списком утёкших паролей, по одному в строке; пустой путь отключает проверку
+type PasswordPolicySettings struct {
+	MinLength             int
+	MaxBytes              int
+	RequireUppercase      bool
+	RequireLowercase      bool
+	RequireDigit          bool
+	RequireSymbol         bool
+	RejectPersonalInfo    bool
+	BreachedPasswordsFile string
+}
+
+// Интерфейс парольной политики
+type PasswordPolicy interface {
+	// Проверяет новый пароль; email и имя нужны для правила personal_info
+	// Возвращает *PasswordPolicyError со всеми нарушениями
+	Validate(password, email, name string) error
+}
+
+// Реализация парольной политики
+// Утёкшие пароли хранятся в памяти в нижнем регистре, сравнение без учёта регистра
+type passwordPolicy struct {
+	settings PasswordPolicySettings
+	breached map[string]struct{}
+}
+
+// Конструктор парольной политики, загружает список утёкших паролей
+func NewPasswordPolicy(settings PasswordPolicySettings) (PasswordPolicy, error) {
+	policy := &passwordPolicy{settings: settings}
+	if settings.BreachedPasswordsFile != "" {
+		breached, err := loadBreachedPasswords(settings.BreachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = breached
+	}
+	return policy, nil
+}
+
+// Проверяет новый пароль по всем правилам политики
+func (p *passwordPolicy) Validate(password, email, name string) error {
+	var violations []PasswordViolation
+	if p.settings.MinLength > 0 && utf8.RuneCountInString(password) < p.settings.MinLength {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.settings.MinLength)})
+	}
+	if p.settings.MaxBytes > 0 && len(password) > p.settings.MaxBytes {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", p.settings.MaxBytes)})
+	}
+	if p.settings.RequireUppercase && !strings.ContainsFunc(password, unicode.IsUpper) {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleUppercase, Message: "Password must contain an uppercase letter"})
+	}
+	if p.settings.RequireLowercase && !strings.ContainsFunc(password, unicode.IsLower) {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleLowercase, Message: "Password must contain a lowercase letter"})
+	}
+	if p.settings.RequireDigit && !strings.ContainsFunc(password, unicode.IsDigit) {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleDigit, Message: "Password must contain a digit"})
+	}
+	if p.settings.RequireSymbol && !strings.ContainsFunc(password, isPasswordSymbol) {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleSymbol, Message: "Password must contain a symbol"})
+	}
+	if p.settings.RejectPersonalInfo && containsPersonalInfo(password, email, name) {
+		violations = append(violations, PasswordViolation{Rule: PasswordRulePersonalInfo, Message: "Password must not contain your email or name"})
+	}
+	if _, found := p.breached[strings.ToLower(password)]; found {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleBreached, Message: "Password has appeared in a data breach, choose another one"})
+	}
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Символом считается любой печатный знак, кроме букв, цифр и пробелов
+func isPasswordSymbol(r rune) bool {
+	return unicode.IsPrint(r) && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
+
+// Проверяет, содержит ли пароль email целиком, его локальную часть или слово из имени
+func containsPersonalInfo(password, email, name string) bool {
+	lowered := strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	fragments := strings.Fields(strings.ToLower(name))
+	if email != "" {
+		fragments = append(fragments, email)
+		if local, _, found := strings.Cut(email, "@"); found {
+			fragments = append(fragments, local)
+		}
+	}
+	for _, fragment := range fragments {
+		if utf8.RuneCountInString(fragment) >= minPersonalInfoLength && strings.Contains(lowered, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Читает список утёкших паролей: по одному в строке, пустые строки и строки с # пропускаются
+func loadBreachedPasswords(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached passwords file: %w", err)
+	}
+	defer file.Close()
+
+	breached := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached passwords file: %w", err)
+	}
+	return breached, nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/mail"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+var ErrIncorrectPassword = errors.New("current password is incorrect")
+
+// Настройки сброса пароля
+// ResetURL — адрес страницы сброса пароля, к которому добавляется параметр token
+type PasswordResetSettings struct {
+	TokenTTL time.Duration
+	ResetURL string
+}
+
+// Интерфейс сервиса управления паролями
+type PasswordService interface {
+	// Отправляет на email ссылку для сброса пароля, если пользователь с таким email существует
+	ForgotPassword(ctx context.Context, email string) error
+	// Устанавливает новый пароль по одноразовому токену сброса
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	// Меняет пароль по текущему паролю и выдаёт вызывающему новую пару токенов
+	ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) (*TokenPair, error)
+}
+
+// Реализация сервиса управления паролями
+// Токены сброса одноразовые, в БД хранится только их хеш.
+// Новый пароль проверяется парольной политикой.
+// После смены пароля все входы пользователя завершаются через сервис авторизации
+type passwordService struct {
+	userRepo   repository.UserRepository
+	actionRepo repository.ActionTokenRepository
+	authz      Authorizer
+	policy     PasswordPolicy
+	auth       AuthService
+	mailer     mail.Mailer
+	settings   PasswordResetSettings
+}
+
+// Конструктор сервиса управления паролями
+func NewPasswordService(userRepo repository.UserRepository, actionRepo repository.ActionTokenRepository, authz Authorizer, policy PasswordPolicy, auth AuthService, mailer mail.Mailer, settings PasswordResetSettings) PasswordService {
+	return &passwordService{userRepo: userRepo, actionRepo: actionRepo, authz: authz, policy: policy, auth: auth, mailer: mailer, settings: settings}
+}
+
+// Отправляет на email ссылку для сброса пароля, если пользователь с таким email существует
+// Для неизвестного email ошибка не возвращается, чтобы по ответу нельзя было узнать,
+// зарегистрирован ли адрес. По той же причине токен и письмо готовятся в фоне: ответ не ждёт
+// записи в БД и SMTP-сервера, а сбой отправки только логируется. Новый запрос делает недействительными прежние токены
+func (s *passwordService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user for password reset: %w", err)
+	}
+	if user == nil {
+		utils.Info("Password reset requested for unknown email")
+		return nil
+	}
+	// Письмо отправляется после ответа, поэтому отмена запроса не должна его прерывать
+	go s.sendResetEmail(context.WithoutCancel(ctx), user)
+	return nil
+}
+
+// Выпускает токен сброса и отправляет письмо со ссылкой; ошибки только логируются
+func (s *passwordService) sendResetEmail(ctx context.Context, user *models.User) {
+	token, err := issueActionToken(ctx, s.actionRepo, user.ID, models.ActionPasswordReset, s.settings.TokenTTL)
+	if err != nil {
+		utils.Error("Failed to issue password reset token for user id=%d: %v", user.ID, err)
+		return
+	}
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo set a new password, open the link below. It is valid for %s and can be used once.\n\n%s\n\nIf you did not request a password reset, ignore this email.",
+			user.Name, s.settings.TokenTTL, actionTokenLink(s.settings.ResetURL, token)),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		utils.Error("Failed to send reset email to user id=%d: %v", user.ID, err)
+	}
+}
+
+// Устанавливает новый пароль по одноразовому токену сброса
+// Пароль проверяется до использования токена, чтобы после отказа политики можно было повторить запрос с тем же токеном
+func (s *passwordService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	stored, err := findActionToken(ctx, s.actionRepo, models.ActionPasswordReset, token, ErrInvalidResetToken)
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user id=%d for password reset: %w", stored.UserID, err)
+	}
+	if user == nil {
+		return ErrInvalidResetToken
+	}
+	if err := s.policy.Validate(newPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+	if err := useActionToken(ctx, s.actionRepo, stored, ErrInvalidResetToken); err != nil {
+		return err
+	}
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password for user id=%d: %w", stored.UserID, err)
+	}
+	if err := s.userRepo.UpdatePasswordHash(ctx, stored.UserID, hashedPassword); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("failed to update password of user id=%d: %w", stored.UserID, err)
+	}
+	if err := s.actionRepo.InvalidateUserActionTokens(ctx, stored.UserID, models.ActionPasswordReset); err != nil {
+		return fmt.Errorf("failed to invalidate reset tokens of user id=%d: %w", stored.UserID, err)
+	}
+	// Старый пароль мог быть скомпрометирован, поэтому завершаем все входы
+	if err := s.auth.LogoutAll(ctx, stored.UserID); err != nil {
+		return fmt.Errorf("failed to revoke sessions of user id=%d after password reset: %w", stored.UserID, err)
+	}
+	return nil
+}
+
+// Меняет пароль по текущему паролю и выдаёт вызывающему новую пару токенов
+// Все прочие входы пользователя завершаются, текущий продолжается с новыми токенами
+func (s *passwordService) ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) (*TokenPair, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanChangePassword(ctx, userID); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user id=%d for password change: %w", userID, err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !utils.CheckPasswordHash(currentPassword, user.PasswordHash) {
+		return nil, ErrIncorrectPassword
+	}
+	if err := s.policy.Validate(newPassword, user.Email, user.Name); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password for user id=%d: %w", userID, err)
+	}
+	if err := s.userRepo.UpdatePasswordHash(ctx, userID, hashedPassword); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to update password of user id=%d: %w", userID, err)
+	}
+	// Ссылки сброса, выданные для старого пароля, больше не нужны
+	if err := s.actionRepo.InvalidateUserActionTokens(ctx, userID, models.ActionPasswordReset); err != nil {
+		return nil, fmt.Errorf("failed to invalidate reset tokens of user id=%d: %w", userID, err)
+	}
+	tokens, err := s.auth.RevokeOtherSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions of user id=%d after password change: %w", userID, err)
+	}
+	return tokens, nil
+}
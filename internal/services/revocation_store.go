@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Интерфейс хранилища отозванных access-токенов
+// Проверяется middleware авторизации на каждом запросе
+type RevocationStore interface {
+	// Отзывает токен по jti до момента его истечения
+	RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error
+	// Отзывает все токены пользователя, выпущенные до текущего момента
+	RevokeAllForUser(ctx context.Context, userID uint) error
+	// Отзывает все токены сессии, выпущенные до текущего момента и после него
+	RevokeSession(ctx context.Context, sessionID, userID uint) error
+	// Проверяет, отозван ли токен; sessionID — claim sid токена, 0 — токен выдан вне сессии
+	IsRevoked(ctx context.Context, jti string, userID, sessionID uint, issuedAt time.Time) (bool, error)
+}
+
+// Реализация хранилища: Postgres как источник истины и кэш в памяти
+// Собственные отзывы попадают в кэш сразу, отзывы других экземпляров приложения —
+// при очередной синхронизации, не реже одного раза в syncInterval
+type revocationStore struct {
+	repo         repository.RevocationRepository
+	syncInterval time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	users    map[uint]time.Time
+	sessions map[uint]time.Time
+	lastSync time.Time
+}
+
+// Конструктор хранилища отозванных токенов
+func NewRevocationStore(repo repository.RevocationRepository, syncInterval time.Duration) RevocationStore {
+	return &revocationStore{
+		repo:         repo,
+		syncInterval: syncInterval,
+		tokens:       make(map[string]time.Time),
+		users:        make(map[uint]time.Time),
+		sessions:     make(map[uint]time.Time),
+	}
+}
+
+// Отзывает токен по jti до момента его истечения
+func (s *revocationStore) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	err := s.repo.RevokeToken(ctx, &models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt.UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token jti=%s: %w", jti, err)
+	}
+	s.mu.Lock()
+	s.tokens[jti] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// Отзывает все токены пользователя, выпущенные до текущего момента
+// Запись живёт не дольше access-токена: позже все такие токены истекут сами
+// Отметка округляется до миллисекунд — с той же точностью в токене хранится iat,
+// поэтому токен, выпущенный сразу после отзыва, остаётся действительным
+func (s *revocationStore) RevokeAllForUser(ctx context.Context, userID uint) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	revocation := &models.UserTokenRevocation{
+		UserID:        userID,
+		RevokedBefore: now,
+		ExpiresAt:     now.Add(utils.AccessTokenExpiration()),
+	}
+	if err := s.repo.RevokeUserTokens(ctx, revocation); err != nil {
+		return fmt.Errorf("failed to revoke tokens of user id=%d: %w", userID, err)
+	}
+	s.mu.Lock()
+	s.users[userID] = now
+	s.mu.Unlock()
+	return nil
+}
+
+// Отзывает все токены сессии
+// Завершённая сессия новых токенов не получает, поэтому запись живёт не дольше access-токена
+func (s *revocationStore) RevokeSession(ctx context.Context, sessionID, userID uint) error {
+	expiresAt := time.Now().UTC().Add(utils.AccessTokenExpiration())
+	err := s.repo.RevokeSession(ctx, &models.RevokedSession{SessionID: sessionID, UserID: userID, ExpiresAt: expiresAt})
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens of session id=%d: %w", sessionID, err)
+	}
+	s.mu.Lock()
+	s.sessions[sessionID] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// Проверяет, отозван ли токен
+func (s *revocationStore) IsRevoked(ctx context.Context, jti string, userID, sessionID uint, issuedAt time.Time) (bool, error) {
+	if err := s.syncIfStale(ctx); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tokens[jti]; ok {
+		return true, nil
+	}
+	if _, ok := s.sessions[sessionID]; sessionID != 0 && ok {
+		return true, nil
+	}
+	if revokedBefore, ok := s.users[userID]; ok && issuedAt.Before(revokedBefore) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Перечитывает кэш из БД, если с последней синхронизации прошло больше syncInterval
+func (s *revocationStore) syncIfStale(ctx context.Context) error {
+	s.mu.RLock()
+	fresh := !s.lastSync.IsZero() && time.Since(s.lastSync) < s.syncInterval
+	s.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Другой запрос мог уже выполнить синхронизацию, пока мы ждали блокировку
+	if !s.lastSync.IsZero() && time.Since(s.lastSync) < s.syncInterval {
+		return nil
+	}
+	now := time.Now().UTC()
+	if err := s.repo.DeleteExpired(ctx, now); err != nil {
+		utils.Warn("Failed to clean up expired token revocations: %v", err)
+	}
+	tokens, err := s.repo.ListActiveRevokedTokens(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to load revoked tokens: %w", err)
+	}
+	users, err := s.repo.ListActiveUserRevocations(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to load user token revocations: %w", err)
+	}
+	sessions, err := s.repo.ListActiveRevokedSessions(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to load revoked sessions: %w", err)
+	}
+	s.tokens = make(map[string]time.Time, len(tokens))
+	for _, t := range tokens {
+		s.tokens[t.JTI] = t.ExpiresAt
+	}
+	s.users = make(map[uint]time.Time, len(users))
+	for _, u := range users {
+		s.users[u.UserID] = u.RevokedBefore
+	}
+	s.sessions = make(map[uint]time.Time, len(sessions))
+	for _, rs := range sessions {
+		s.sessions[rs.SessionID] = rs.ExpiresAt
+	}
+	s.lastSync = now
+	return nil
+}
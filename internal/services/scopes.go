@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+)
+
+var ErrInvalidScope = errors.New("unsupported scope")
+var ErrScopeNotGranted = errors.New("scope exceeds the caller's scopes")
+
+// Проверяет запрошенные области действия и приводит список к каноническому виду
+// Повторы не имеют смысла, порядок не важен: результат отсортирован и без повторов.
+// Пустой запрос даёт nil — вызывающий сам решает, что он означает
+func normalizeScopes(scopes []string) ([]string, error) {
+	for _, scope := range scopes {
+		if !models.IsKnownScope(scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, nil
+	}
+	return slices.Compact(slices.Sorted(slices.Values(scopes))), nil
+}
+
+// Определяет области действия для токена или ключа, выпускаемого от имени вызывающего
+// Выпустить можно только подмножество собственных областей: пустой запрос даёт все области вызывающего,
+// превышение — ErrScopeNotGranted. Без идентичности в контексте (вход по паролю) доступны все области
+func grantScopes(ctx context.Context, requested []string) ([]string, error) {
+	scopes, err := normalizeScopes(requested)
+	if err != nil {
+		return nil, err
+	}
+	granted := models.AllScopes
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		granted = principal.GrantedScopes()
+	}
+	if len(scopes) == 0 {
+		return slices.Clone(granted), nil
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrScopeNotGranted, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// Проверяет, что список содержит все поддерживаемые области действия
+func hasAllScopes(scopes []string) bool {
+	for _, scope := range models.AllScopes {
+		if !slices.Contains(scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Интерфейс сервиса сессий пользователя
+type SessionService interface {
+	// Возвращает действующие сессии пользователя
+	ListSessions(ctx context.Context, userID uint) ([]models.Session, error)
+	// Завершает сессию пользователя: отзывает её refresh- и access-токены
+	RevokeSession(ctx context.Context, userID, sessionID uint) error
+}
+
+// Реализация сервиса сессий
+// Сессии открывает и продлевает сервис авторизации; здесь их можно просмотреть и завершить.
+// Завершение сессии отзывает семейство её refresh-токенов и все выданные в ней access-токены (по claim sid),
+// поэтому запросы с устройства перестают проходить сразу, в том числе с ранее выданными и украденными токенами
+type sessionService struct {
+	sessionRepo repository.SessionRepository
+	refreshRepo repository.RefreshTokenRepository
+	revocations RevocationStore
+	authz       Authorizer
+}
+
+// Конструктор сервиса сессий
+func NewSessionService(sessionRepo repository.SessionRepository, refreshRepo repository.RefreshTokenRepository, revocations RevocationStore, authz Authorizer) SessionService {
+	return &sessionService{sessionRepo: sessionRepo, refreshRepo: refreshRepo, revocations: revocations, authz: authz}
+}
+
+// Возвращает действующие сессии пользователя
+func (s *sessionService) ListSessions(ctx context.Context, userID uint) ([]models.Session, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	sessions, err := s.sessionRepo.ListUserSessions(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions of user id=%d: %w", userID, err)
+	}
+	return sessions, nil
+}
+
+// Завершает сессию пользователя: отзывает её refresh- и access-токены
+// Чужая, неизвестная и уже завершённая сессия дают ErrSessionNotFound
+func (s *sessionService) RevokeSession(ctx context.Context, userID, sessionID uint) error {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageSessions(ctx, userID); err != nil {
+		return err
+	}
+	session, err := s.sessionRepo.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session id=%d: %w", sessionID, err)
+	}
+	if session == nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	if err := s.sessionRepo.RevokeSession(ctx, userID, sessionID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to revoke session id=%d of user id=%d: %w", sessionID, userID, err)
+	}
+	if err := s.refreshRepo.RevokeTokenFamily(ctx, session.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of session id=%d: %w", sessionID, err)
+	}
+	if err := s.revocations.RevokeSession(ctx, sessionID, userID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens of session id=%d: %w", sessionID, err)
+	}
+	utils.Info("Session revoked: id=%d, user_id=%d", sessionID, userID)
+	return nil
+}
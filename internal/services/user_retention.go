@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/iwtcode/user-order-api/internal/utils"
+)
+
+// Настройки хранения удалённых пользователей
+// Retention — сколько мягко удалённый пользователь хранится до окончательного удаления,
+// Interval — как часто запускается очистка, OrderPolicy — что делать с заказами удаляемых пользователей
+type UserRetentionSettings struct {
+	Retention   time.Duration
+	Interval    time.Duration
+	OrderPolicy string
+}
+
+// Интерфейс фоновой задачи, окончательно удаляющей пользователей после срока хранения
+type UserRetentionJob interface {
+	// Окончательно удаляет пользователей, срок хранения которых истёк; возвращает их число
+	PurgeExpired(ctx context.Context) (int64, error)
+	// Запускает очистку сразу и затем раз в Interval, пока не отменён контекст
+	Run(ctx context.Context)
+}
+
+// Реализация задачи очистки на основе репозитория пользователей
+type userRetentionJob struct {
+	userRepo repository.UserRepository
+	settings UserRetentionSettings
+}
+
+// Конструктор задачи очистки удалённых пользователей
+func NewUserRetentionJob(userRepo repository.UserRepository, settings UserRetentionSettings) UserRetentionJob {
+	return &userRetentionJob{userRepo: userRepo, settings: settings}
+}
+
+// Окончательно удаляет пользователей, срок хранения которых истёк
+func (j *userRetentionJob) PurgeExpired(ctx context.Context) (int64, error) {
+	deletedBefore := time.Now().UTC().Add(-j.settings.Retention)
+	purged, err := j.userRepo.PurgeDeletedUsers(ctx, deletedBefore, j.settings.OrderPolicy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge users deleted before %s: %w", deletedBefore.Format(time.RFC3339), err)
+	}
+	if purged > 0 {
+		utils.Info("Purged %d users deleted before %s", purged, deletedBefore.Format(time.RFC3339))
+	}
+	return purged, nil
+}
+
+// Запускает очистку сразу и затем раз в Interval, пока не отменён контекст
+// Ошибка очистки не останавливает задачу: следующая попытка будет через Interval
+func (j *userRetentionJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.settings.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.PurgeExpired(ctx); err != nil {
+			utils.Error("User retention job failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
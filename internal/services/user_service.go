@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 
+	"github.com/iwtcode/user-order-api/internal/mail"
 	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/repository"
 	"github.com/iwtcode/user-order-api/internal/utils"
@@ -16,40 +18,78 @@ var ErrEmailExists = errors.New("email already exists")
 var ErrUserNotFound = errors.New("user not found")
 var ErrInvalidCredentials = errors.New("invalid credentials")
 var ErrOrderUserNotFound = errors.New("order user not found")
+var ErrVersionMismatch = errors.New("user version mismatch")
+var ErrUserHasOrders = errors.New("user has orders")
+
+// Результат регистрации
+// В режиме приватной регистрации User не заполняется, а Accepted означает, что запрос принят и итог отправлен письмом
+type SignupResult struct {
+	User     *models.User
+	Accepted bool
+}
+
+// Настройки сервиса пользователей
+// В режиме приватной регистрации попытка зарегистрировать занятый email выполняет ту же работу,
+// что и регистрация (хеширование пароля и письмо), а владельцу адреса приходит уведомление.
+// В обоих случаях CreateUser возвращает принятый запрос без пользователя.
+// OrderPolicy — политика обращения с заказами при удалении пользователя (models.OrderPolicy*)
+type UserSettings struct {
+	SignupPrivacyMode bool
+	OrderPolicy       string
+}
 
 // Интерфейс сервиса пользователей, описывает бизнес-логику работы с пользователями
 type UserService interface {
-	// Создаёт нового пользователя
-	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
-	// Возвращает список пользователей с пагинацией и фильтрацией
-	ListUsers(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int64, error)
+	// Создаёт нового пользователя; в режиме приватной регистрации возвращает только признак принятого запроса
+	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*SignupResult, error)
+	// Возвращает страницу пользователей по спецификации выборки и общее число подходящих пользователей
+	ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error)
 	// Получает пользователя по ID
 	GetUserByID(ctx context.Context, id uint) (*models.User, error)
-	// Обновляет данные пользователя
-	UpdateUser(ctx context.Context, id uint, req *models.UpdateUserRequest) (*models.User, error)
-	// Удаляет пользователя по ID
+	// Обновляет данные пользователя; ifMatch — допустимые версии из If-Match, nil — без условия
+	UpdateUser(ctx context.Context, id uint, req *models.UpdateUserRequest, ifMatch []int) (*models.User, error)
+	// Частично обновляет пользователя: меняются только переданные поля; ifMatch — как в UpdateUser
+	PatchUser(ctx context.Context, id uint, req *models.PatchUserRequest, ifMatch []int) (*models.User, error)
+	// Удаляет пользователя по ID (мягкое удаление); при политике restrict пользователя с заказами удалить нельзя
 	DeleteUser(ctx context.Context, id uint) error
+	// Восстанавливает мягко удалённого пользователя
+	RestoreUser(ctx context.Context, id uint) (*models.User, error)
 }
 
 // Реализация сервиса пользователей
-// Использует репозиторий для доступа к данным
+// Использует репозиторий для доступа к данным и слой авторизации для проверки прав
+// Пароль проверяется парольной политикой, после регистрации отправляется письмо для подтверждения email
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo     repository.UserRepository
+	authz        Authorizer
+	policy       PasswordPolicy
+	verification EmailVerificationService
+	auth         AuthService
+	mailer       mail.Mailer
+	settings     UserSettings
 }
 
 // Конструктор сервиса пользователей
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repository.UserRepository, authz Authorizer, policy PasswordPolicy, verification EmailVerificationService, auth AuthService, mailer mail.Mailer, settings UserSettings) UserService {
+	return &userService{userRepo: userRepo, authz: authz, policy: policy, verification: verification, auth: auth, mailer: mailer, settings: settings}
 }
 
 // Создаёт нового пользователя
-func (s *userService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+func (s *userService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*SignupResult, error) {
+	// Пароль проверяется до поиска email, поэтому ответ на слабый пароль не зависит от того, занят ли адрес
+	if err := s.policy.Validate(req.Password, req.Email, req.Name); err != nil {
+		return nil, err
+	}
 	// Проверяем, существует ли пользователь с таким email
 	existingUser, err := s.userRepo.GetUserByEmail(ctx, req.Email)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, fmt.Errorf("error checking for existing email: %w", err)
 	}
 	if existingUser != nil {
+		if s.settings.SignupPrivacyMode {
+			s.handleDuplicateSignup(ctx, existingUser, req.Password)
+			return &SignupResult{Accepted: true}, nil
+		}
 		return nil, fmt.Errorf("attempt to create user with duplicate email: %s %w", req.Email, ErrEmailExists)
 	}
 	// Хешируем пароль
@@ -63,18 +103,46 @@ func (s *userService) CreateUser(ctx context.Context, req *models.CreateUserRequ
 		Email:        req.Email,
 		Age:          req.Age,
 		PasswordHash: hashedPassword,
+		Role:         models.RoleUser,
 	}
 	err = s.userRepo.CreateUser(ctx, newUser)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user in database for email %s: %v", req.Email, err)
 	}
+	// Аккаунт уже создан, поэтому сбой отправки письма не отменяет регистрацию:
+	// письмо можно запросить повторно
+	if err := s.verification.SendVerification(ctx, newUser); err != nil {
+		utils.Warn("Failed to send verification email to user id=%d: %v", newUser.ID, err)
+	}
+	// Ответ не должен отличаться от ответа на занятый email
+	if s.settings.SignupPrivacyMode {
+		return &SignupResult{Accepted: true}, nil
+	}
 	// Возвращаем созданного пользователя
-	return newUser, nil
+	return &SignupResult{User: newUser}, nil
+}
+
+// Выполняет для занятого email ту же работу, что и регистрация, и уведомляет владельца адреса
+// Ошибки только логируются: ответ на запрос не должен от них зависеть
+func (s *userService) handleDuplicateSignup(ctx context.Context, existingUser *models.User, password string) {
+	// Хеш не нужен, но его вычисление уравнивает время ответа с настоящей регистрацией
+	if _, err := utils.HashPassword(password); err != nil {
+		utils.Warn("Failed to hash password during duplicate signup: %v", err)
+	}
+	msg := mail.Message{
+		To:      existingUser.Email,
+		Subject: "Sign-up attempt with your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nSomeone tried to create a new account with this email address, but you already have one.\n\nIf it was you, sign in or reset your password. Otherwise, ignore this email.",
+			existingUser.Name),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		utils.Warn("Failed to send duplicate signup notice to user id=%d: %v", existingUser.ID, err)
+	}
 }
 
-// Возвращает список пользователей с пагинацией и фильтрацией
-func (s *userService) ListUsers(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int64, error) {
-	users, total, err := s.userRepo.ListUsers(ctx, page, limit, minAge, maxAge)
+// Возвращает страницу пользователей по спецификации выборки и общее число подходящих пользователей
+func (s *userService) ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error) {
+	users, total, err := s.userRepo.ListUsers(ctx, query)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to list users: %w", err)
 	}
@@ -94,7 +162,13 @@ func (s *userService) GetUserByID(ctx context.Context, id uint) (*models.User, e
 }
 
 // Обновляет данные пользователя
-func (s *userService) UpdateUser(ctx context.Context, id uint, req *models.UpdateUserRequest) (*models.User, error) {
+// Запись выполняется только при неизменной с момента чтения версии, поэтому одновременные правки не затирают друг друга.
+// При смене email подтверждение снимается, и на новый адрес отправляется письмо для подтверждения
+func (s *userService) UpdateUser(ctx context.Context, id uint, req *models.UpdateUserRequest, ifMatch []int) (*models.User, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageUser(ctx, id); err != nil {
+		return nil, err
+	}
 	// Получаем пользователя по ID
 	user, err := s.userRepo.GetUserByID(ctx, id)
 	if err != nil {
@@ -103,6 +177,9 @@ func (s *userService) UpdateUser(ctx context.Context, id uint, req *models.Updat
 	if user == nil {
 		return nil, ErrUserNotFound
 	}
+	if err := checkUserVersion(user, ifMatch); err != nil {
+		return nil, err
+	}
 	// Проверяем уникальность email, если он изменился
 	if user.Email != req.Email {
 		existingUser, err := s.userRepo.GetUserByEmail(ctx, req.Email)
@@ -113,19 +190,120 @@ func (s *userService) UpdateUser(ctx context.Context, id uint, req *models.Updat
 			return nil, ErrEmailExists
 		}
 	}
-	// Обновляем поля пользователя
+	// Обновляем поля пользователя; подтверждение старого адреса к новому не относится
+	emailChanged := user.Email != req.Email
 	user.Name = req.Name
 	user.Email = req.Email
 	user.Age = req.Age
+	if emailChanged {
+		user.EmailVerifiedAt = nil
+	}
 	// Сохраняем изменения
 	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		// Пользователя изменили или удалили между чтением и записью
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: user id=%d was modified concurrently", ErrVersionMismatch, id)
+		}
 		return nil, err
 	}
+	if emailChanged {
+		s.sendVerificationForNewEmail(ctx, user)
+	}
 	return user, nil
 }
 
+// Частично обновляет пользователя: меняются только переданные поля
+// В БД записываются лишь столбцы, значения которых действительно изменились; при смене email подтверждение снимается, как в UpdateUser
+func (s *userService) PatchUser(ctx context.Context, id uint, req *models.PatchUserRequest, ifMatch []int) (*models.User, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageUser(ctx, id); err != nil {
+		return nil, err
+	}
+	// Получаем пользователя по ID
+	user, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := checkUserVersion(user, ifMatch); err != nil {
+		return nil, err
+	}
+	// Собираем изменённые столбцы
+	fields := make(map[string]interface{})
+	if req.Name != nil && *req.Name != user.Name {
+		fields["name"] = *req.Name
+	}
+	if req.Email != nil && *req.Email != user.Email {
+		// Проверяем уникальность нового email
+		existingUser, err := s.userRepo.GetUserByEmail(ctx, *req.Email)
+		if err != nil {
+			return nil, err
+		}
+		if existingUser != nil && existingUser.ID != id {
+			return nil, ErrEmailExists
+		}
+		fields["email"] = *req.Email
+		fields["email_verified_at"] = nil
+	}
+	if req.Age != nil && *req.Age != user.Age {
+		fields["age"] = *req.Age
+	}
+	if len(fields) == 0 {
+		return user, nil
+	}
+	// Сохраняем изменения
+	if err := s.userRepo.UpdateUserFields(ctx, id, user.Version, fields); err != nil {
+		// Пользователя изменили или удалили между чтением и записью
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: user id=%d was modified concurrently", ErrVersionMismatch, id)
+		}
+		return nil, err
+	}
+	user.Version++
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if req.Age != nil {
+		user.Age = *req.Age
+	}
+	if _, emailChanged := fields["email"]; emailChanged {
+		user.Email = *req.Email
+		user.EmailVerifiedAt = nil
+		s.sendVerificationForNewEmail(ctx, user)
+	}
+	return user, nil
+}
+
+// Отправляет письмо для подтверждения нового email
+// Изменение уже сохранено, поэтому сбой отправки только логируется: письмо можно запросить повторно
+func (s *userService) sendVerificationForNewEmail(ctx context.Context, user *models.User) {
+	if err := s.verification.SendVerification(ctx, user); err != nil {
+		utils.Warn("Failed to send verification email to new address of user id=%d: %v", user.ID, err)
+	}
+}
+
+// Проверяет, что версия пользователя входит в список из If-Match
+// nil означает, что условие не задано
+func checkUserVersion(user *models.User, ifMatch []int) error {
+	if ifMatch != nil && !slices.Contains(ifMatch, user.Version) {
+		return fmt.Errorf("%w: user id=%d has version %d", ErrVersionMismatch, user.ID, user.Version)
+	}
+	return nil
+}
+
 // Удаляет пользователя по ID
+// Удаление мягкое: пользователь пропадает из выборок и не может войти, но его заказы остаются связаны с ним,
+// а администратор может восстановить аккаунт до истечения срока хранения.
+// Все сессии, refresh- и access-токены пользователя отзываются, как при выходе со всех устройств.
+// При политике restrict пользователя с заказами удалить нельзя: возвращается ErrUserHasOrders,
+// иначе он не был бы удалён окончательно по истечении срока хранения
 func (s *userService) DeleteUser(ctx context.Context, id uint) error {
+	// Проверяем права вызывающего
+	if err := s.authz.CanManageUser(ctx, id); err != nil {
+		return err
+	}
 	// Проверяем, существует ли пользователь
 	user, err := s.userRepo.GetUserByID(ctx, id)
 	if err != nil {
@@ -134,9 +312,59 @@ func (s *userService) DeleteUser(ctx context.Context, id uint) error {
 	if user == nil {
 		return ErrUserNotFound
 	}
-	// Удаляем пользователя
-	if err := s.userRepo.DeleteUser(ctx, id); err != nil {
+	// Удаляем пользователя; политика restrict проверяется в той же транзакции
+	if err := s.userRepo.DeleteUser(ctx, id, s.settings.OrderPolicy); err != nil {
+		if errors.Is(err, repository.ErrUserHasOrders) {
+			return fmt.Errorf("%w: user id=%d", ErrUserHasOrders, id)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
 		return err
 	}
+	// Завершаем все входы удалённого пользователя
+	if err := s.auth.LogoutAll(ctx, id); err != nil {
+		return fmt.Errorf("failed to revoke tokens of deleted user id=%d: %w", id, err)
+	}
 	return nil
 }
+
+// Восстанавливает мягко удалённого пользователя
+// Если email пользователя за это время занял другой аккаунт, возвращает ErrEmailExists
+func (s *userService) RestoreUser(ctx context.Context, id uint) (*models.User, error) {
+	// Проверяем права вызывающего
+	if err := s.authz.CanRestoreUser(ctx, id); err != nil {
+		return nil, err
+	}
+	// Ищем удалённого пользователя
+	user, err := s.userRepo.GetDeletedUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	// Проверяем, что email не занят
+	existingUser, err := s.userRepo.GetUserByEmail(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser != nil {
+		return nil, fmt.Errorf("cannot restore user id=%d: email %s is used by user id=%d: %w", id, user.Email, existingUser.ID, ErrEmailExists)
+	}
+	// Снимаем отметку об удалении
+	if err := s.userRepo.RestoreUser(ctx, id); err != nil {
+		// Пользователя восстановили или удалили окончательно между чтением и записью
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		// Email занял другой аккаунт между проверкой и записью
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return nil, fmt.Errorf("cannot restore user id=%d: email %s is already used: %w", id, user.Email, ErrEmailExists)
+		}
+		return nil, err
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Version++
+	return user, nil
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestActionTokenRepository_CreateActionToken(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewActionTokenRepository(db)
+	token := &models.ActionToken{UserID: 1, Purpose: models.ActionPasswordReset, TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "action_tokens"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	err := repo.CreateActionToken(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), token.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActionTokenRepository_GetActionTokenByHash(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewActionTokenRepository(db)
+	mock.ExpectQuery(`SELECT \* FROM "action_tokens" WHERE purpose = \$1 AND token_hash = \$2`).WithArgs(models.ActionPasswordReset, "hash", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "token_hash"}).AddRow(3, 1, models.ActionPasswordReset, "hash"))
+	token, err := repo.GetActionTokenByHash(context.Background(), models.ActionPasswordReset, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), token.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActionTokenRepository_GetActionTokenByHash_NotFound(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewActionTokenRepository(db)
+	mock.ExpectQuery(`SELECT \* FROM "action_tokens" WHERE purpose = \$1 AND token_hash = \$2`).WithArgs(models.ActionPasswordReset, "hash", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	token, err := repo.GetActionTokenByHash(context.Background(), models.ActionPasswordReset, "hash")
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActionTokenRepository_ConsumeActionToken_AlreadyUsed(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewActionTokenRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "action_tokens" SET "used_at"=\$1 WHERE id = \$2 AND used_at IS NULL`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err := repo.ConsumeActionToken(context.Background(), 3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActionTokenRepository_InvalidateUserActionTokens(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewActionTokenRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "action_tokens" SET "used_at"=\$1 WHERE user_id = \$2 AND purpose = \$3 AND used_at IS NULL`).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	err := repo.InvalidateUserActionTokens(context.Background(), 1, models.ActionPasswordReset)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
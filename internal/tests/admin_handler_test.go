@@ -0,0 +1,191 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockImpersonationService struct {
+	mock.Mock
+}
+
+func (m *mockImpersonationService) Impersonate(ctx context.Context, userID uint, reason string, client services.ClientInfo) (*services.ImpersonationToken, error) {
+	args := m.Called(ctx, userID, reason, client)
+	token, _ := args.Get(0).(*services.ImpersonationToken)
+	return token, args.Error(1)
+}
+
+func TestAdminHandler_UnlockUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		id           string
+		mockSetup    func(m *mockAuthService)
+		expectedCode int
+	}{
+		{
+			name: "success",
+			id:   "1",
+			mockSetup: func(m *mockAuthService) {
+				m.On("UnlockAccount", mock.Anything, uint(1)).Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "not admin",
+			id:   "1",
+			mockSetup: func(m *mockAuthService) {
+				m.On("UnlockAccount", mock.Anything, uint(1)).Return(services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "user not found",
+			id:   "2",
+			mockSetup: func(m *mockAuthService) {
+				m.On("UnlockAccount", mock.Anything, uint(2)).Return(services.ErrUserNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "invalid id",
+			id:           "abc",
+			mockSetup:    func(m *mockAuthService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "service error",
+			id:   "1",
+			mockSetup: func(m *mockAuthService) {
+				m.On("UnlockAccount", mock.Anything, uint(1)).Return(errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockAuthService)
+			tt.mockSetup(svc)
+			h := handlers.NewAdminHandler(svc, new(mockImpersonationService))
+			router := gin.New()
+			router.POST("/admin/users/:id/unlock", h.UnlockUser)
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/users/"+tt.id+"/unlock", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminHandler_ImpersonateUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client := services.ClientInfo{IP: "192.0.2.1"}
+	tests := []struct {
+		name         string
+		id           string
+		body         string
+		mockSetup    func(m *mockImpersonationService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "success",
+			id:   "2",
+			body: `{"reason":"support ticket #42"}`,
+			mockSetup: func(m *mockImpersonationService) {
+				m.On("Impersonate", mock.Anything, uint(2), "support ticket #42", client).Return(&services.ImpersonationToken{
+					AccessToken: "access", ExpiresIn: 900, Scopes: []string{"orders:read", "users:read"},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"access_token":"access","token_type":"Bearer","expires_in":900,"scope":"orders:read users:read","user_id":2}`,
+		},
+		{
+			name:         "missing reason",
+			id:           "2",
+			body:         `{}`,
+			mockSetup:    func(m *mockImpersonationService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "invalid id",
+			id:           "abc",
+			body:         `{"reason":"support ticket #42"}`,
+			mockSetup:    func(m *mockImpersonationService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "not admin",
+			id:   "2",
+			body: `{"reason":"support ticket #42"}`,
+			mockSetup: func(m *mockImpersonationService) {
+				m.On("Impersonate", mock.Anything, uint(2), "support ticket #42", client).Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "admin target",
+			id:   "3",
+			body: `{"reason":"support ticket #42"}`,
+			mockSetup: func(m *mockImpersonationService) {
+				m.On("Impersonate", mock.Anything, uint(3), "support ticket #42", client).Return(nil, services.ErrImpersonationNotAllowed)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":"This user cannot be impersonated"}`,
+		},
+		{
+			name: "user not found",
+			id:   "4",
+			body: `{"reason":"support ticket #42"}`,
+			mockSetup: func(m *mockImpersonationService) {
+				m.On("Impersonate", mock.Anything, uint(4), "support ticket #42", client).Return(nil, services.ErrUserNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "service error",
+			id:   "2",
+			body: `{"reason":"support ticket #42"}`,
+			mockSetup: func(m *mockImpersonationService) {
+				m.On("Impersonate", mock.Anything, uint(2), "support ticket #42", client).Return(nil, errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockImpersonationService)
+			tt.mockSetup(svc)
+			h := handlers.NewAdminHandler(new(mockAuthService), svc)
+			router := gin.New()
+			router.POST("/admin/users/:id/impersonate", h.ImpersonateUser)
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/users/"+tt.id+"/impersonate", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "192.0.2.1:1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *mockAPIKeyService) CreateAPIKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (*services.CreatedAPIKey, error) {
+	args := m.Called(ctx, userID, req)
+	created, _ := args.Get(0).(*services.CreatedAPIKey)
+	return created, args.Error(1)
+}
+func (m *mockAPIKeyService) ListAPIKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	args := m.Called(ctx, userID)
+	keys, _ := args.Get(0).([]models.APIKey)
+	return keys, args.Error(1)
+}
+func (m *mockAPIKeyService) RevokeAPIKey(ctx context.Context, userID, keyID uint) error {
+	args := m.Called(ctx, userID, keyID)
+	return args.Error(0)
+}
+func (m *mockAPIKeyService) Authenticate(ctx context.Context, key string) (services.Principal, error) {
+	args := m.Called(ctx, key)
+	principal, _ := args.Get(0).(services.Principal)
+	return principal, args.Error(1)
+}
+
+func TestAPIKeyHandler_CreateAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		mockSetup    func(m *mockAPIKeyService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "success",
+			path: "/users/1/api-keys",
+			body: `{"name":"export","scopes":["orders:read"]}`,
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("CreateAPIKey", mock.Anything, uint(1), &models.CreateAPIKeyRequest{Name: "export", Scopes: []string{"orders:read"}}).
+					Return(&services.CreatedAPIKey{Key: "uoa_0a1b2c3d_secret", APIKey: &models.APIKey{ID: 3, Name: "export", Prefix: "uoa_0a1b2c3d", Scopes: "orders:read", CreatedAt: createdAt}}, nil)
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":3,"name":"export","prefix":"uoa_0a1b2c3d","scopes":["orders:read"],"expires_at":null,"last_used_at":null,"created_at":"2026-01-02T03:04:05Z","key":"uoa_0a1b2c3d_secret"}`,
+		},
+		{
+			name: "unknown scope",
+			path: "/users/1/api-keys",
+			body: `{"name":"export","scopes":["admin:all"]}`,
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("CreateAPIKey", mock.Anything, uint(1), mock.Anything).Return(nil, fmt.Errorf("%w: %q", services.ErrInvalidScope, "admin:all"))
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "scope beyond token",
+			path: "/users/1/api-keys",
+			body: `{"name":"export","scopes":["orders:write"]}`,
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("CreateAPIKey", mock.Anything, uint(1), mock.Anything).Return(nil, fmt.Errorf("%w: %q", services.ErrScopeNotGranted, "orders:write"))
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":"Access denied: requested scope exceeds the scopes of your token"}`,
+		},
+		{
+			name: "expiry in past",
+			path: "/users/1/api-keys",
+			body: `{"name":"export","expires_at":"2020-01-01T00:00:00Z"}`,
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("CreateAPIKey", mock.Anything, uint(1), mock.Anything).Return(nil, services.ErrInvalidAPIKeyExpiry)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: `{"error":"API key expiry must be in the future"}`,
+		},
+		{
+			name: "forbidden",
+			path: "/users/2/api-keys",
+			body: `{"name":"export"}`,
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("CreateAPIKey", mock.Anything, uint(2), mock.Anything).Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "missing name",
+			path:         "/users/1/api-keys",
+			body:         `{"scopes":["orders:read"]}`,
+			mockSetup:    func(m *mockAPIKeyService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "invalid user id",
+			path:         "/users/abc/api-keys",
+			body:         `{"name":"export"}`,
+			mockSetup:    func(m *mockAPIKeyService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockAPIKeyService)
+			tt.mockSetup(svc)
+			h := handlers.NewAPIKeyHandler(svc)
+			router := gin.New()
+			router.POST("/users/:id/api-keys", h.CreateAPIKey)
+
+			req, _ := http.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAPIKeyHandler_ListAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := new(mockAPIKeyService)
+	svc.On("ListAPIKeys", mock.Anything, uint(1)).Return([]models.APIKey{{ID: 3, Name: "export", Prefix: "uoa_0a1b2c3d", KeyHash: "secret-hash"}}, nil)
+	h := handlers.NewAPIKeyHandler(svc)
+	router := gin.New()
+	router.GET("/users/:id/api-keys", h.ListAPIKeys)
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/1/api-keys", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret-hash")
+	var resp struct {
+		APIKeys []models.APIKeyResponse `json:"api_keys"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []models.APIKeyResponse{{ID: 3, Name: "export", Prefix: "uoa_0a1b2c3d", Scopes: []string{}}}, resp.APIKeys)
+}
+
+func TestAPIKeyHandler_RevokeAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		path         string
+		mockSetup    func(m *mockAPIKeyService)
+		expectedCode int
+	}{
+		{
+			name: "success",
+			path: "/users/1/api-keys/3",
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("RevokeAPIKey", mock.Anything, uint(1), uint(3)).Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "not found",
+			path: "/users/1/api-keys/3",
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("RevokeAPIKey", mock.Anything, uint(1), uint(3)).Return(services.ErrAPIKeyNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "service error",
+			path: "/users/1/api-keys/3",
+			mockSetup: func(m *mockAPIKeyService) {
+				m.On("RevokeAPIKey", mock.Anything, uint(1), uint(3)).Return(errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "invalid key id",
+			path:         "/users/1/api-keys/abc",
+			mockSetup:    func(m *mockAPIKeyService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockAPIKeyService)
+			tt.mockSetup(svc)
+			h := handlers.NewAPIKeyHandler(svc)
+			router := gin.New()
+			router.DELETE("/users/:id/api-keys/:key_id", h.RevokeAPIKey)
+
+			req, _ := http.NewRequest(http.MethodDelete, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			svc.AssertExpectations(t)
+		})
+	}
+}
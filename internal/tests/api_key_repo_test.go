@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAPIKeyRepository_RevokeAPIKey(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewAPIKeyRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "api_keys" SET "revoked_at"=\$1 WHERE id = \$2 AND user_id = \$3 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.RevokeAPIKey(context.Background(), 1, 3)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_RevokeAPIKey_NotOwned(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewAPIKeyRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "api_keys" SET "revoked_at"=\$1 WHERE id = \$2 AND user_id = \$3 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 3, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err := repo.RevokeAPIKey(context.Background(), 2, 3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_GetAPIKeyByHash_NotFound(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewAPIKeyRepository(db)
+	mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE key_hash = \$1`).
+		WithArgs("hash", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "key_hash"}))
+	key, err := repo.GetAPIKeyByHash(context.Background(), "hash")
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,186 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockAPIKeyRepo struct {
+	mock.Mock
+}
+
+func (m *mockAPIKeyRepo) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+func (m *mockAPIKeyRepo) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	args := m.Called(ctx, hash)
+	key, _ := args.Get(0).(*models.APIKey)
+	return key, args.Error(1)
+}
+func (m *mockAPIKeyRepo) ListUserAPIKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	args := m.Called(ctx, userID)
+	keys, _ := args.Get(0).([]models.APIKey)
+	return keys, args.Error(1)
+}
+func (m *mockAPIKeyRepo) RevokeAPIKey(ctx context.Context, userID, id uint) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+func (m *mockAPIKeyRepo) TouchAPIKey(ctx context.Context, id uint, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func TestAPIKeyService_CreateAPIKey(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	keyRepo := new(mockAPIKeyRepo)
+	svc := services.NewAPIKeyService(userRepo, keyRepo, services.NewAuthorizer())
+	ctx := contextWithUser(1, models.RoleUser)
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1}, nil)
+	keyRepo.On("CreateAPIKey", ctx, mock.AnythingOfType("*models.APIKey")).Return(nil)
+
+	created, err := svc.CreateAPIKey(ctx, 1, &models.CreateAPIKeyRequest{
+		Name:      "nightly export",
+		Scopes:    []string{models.ScopeOrdersRead, models.ScopeUsersRead, models.ScopeOrdersRead},
+		ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `^uoa_[0-9a-f]{8}_[A-Za-z0-9_-]{43}$`, created.Key)
+	assert.True(t, strings.HasPrefix(created.Key, created.APIKey.Prefix+"_"))
+	// В БД попадает только хеш ключа
+	assert.Equal(t, utils.HashToken(created.Key), created.APIKey.KeyHash)
+	assert.Equal(t, "orders:read users:read", created.APIKey.Scopes)
+	assert.Equal(t, "nightly export", created.APIKey.Name)
+	assert.WithinDuration(t, expiresAt, *created.APIKey.ExpiresAt, time.Second)
+}
+
+func TestAPIKeyService_CreateAPIKey_ScopesLimitedByToken(t *testing.T) {
+	readOnly := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, Scopes: []string{models.ScopeOrdersRead, models.ScopeUsersWrite}})
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		scopes   []string
+		expected string
+	}{
+		{name: "token with all scopes, none requested", ctx: contextWithUser(1, models.RoleUser), expected: ""},
+		{name: "limited token, none requested", ctx: readOnly, expected: "orders:read users:write"},
+		{name: "limited token, subset requested", ctx: readOnly, scopes: []string{models.ScopeOrdersRead}, expected: "orders:read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(mockUserRepo)
+			keyRepo := new(mockAPIKeyRepo)
+			svc := services.NewAPIKeyService(userRepo, keyRepo, services.NewAuthorizer())
+
+			userRepo.On("GetUserByID", tt.ctx, uint(1)).Return(&models.User{ID: 1}, nil)
+			keyRepo.On("CreateAPIKey", tt.ctx, mock.AnythingOfType("*models.APIKey")).Return(nil)
+
+			created, err := svc.CreateAPIKey(tt.ctx, 1, &models.CreateAPIKeyRequest{Name: "report", Scopes: tt.scopes})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, created.APIKey.Scopes)
+		})
+	}
+}
+
+func TestAPIKeyService_CreateAPIKey_Invalid(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		req      *models.CreateAPIKeyRequest
+		expected error
+	}{
+		{name: "unknown scope", ctx: contextWithUser(1, models.RoleUser), req: &models.CreateAPIKeyRequest{Name: "job", Scopes: []string{"admin:all"}}, expected: services.ErrInvalidScope},
+		{
+			name:     "scope beyond token",
+			ctx:      services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, Scopes: []string{models.ScopeUsersWrite}}),
+			req:      &models.CreateAPIKeyRequest{Name: "job", Scopes: []string{models.ScopeOrdersWrite}},
+			expected: services.ErrScopeNotGranted,
+		},
+		{name: "expiry in past", ctx: contextWithUser(1, models.RoleUser), req: &models.CreateAPIKeyRequest{Name: "job", ExpiresAt: &past}, expected: services.ErrInvalidAPIKeyExpiry},
+		{name: "admin for other user", ctx: contextWithUser(9, models.RoleAdmin), req: &models.CreateAPIKeyRequest{Name: "job"}, expected: services.ErrForbidden},
+		{
+			name:     "through API key",
+			ctx:      services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, APIKeyID: 5}),
+			req:      &models.CreateAPIKeyRequest{Name: "job"},
+			expected: services.ErrForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyRepo := new(mockAPIKeyRepo)
+			svc := services.NewAPIKeyService(new(mockUserRepo), keyRepo, services.NewAuthorizer())
+
+			created, err := svc.CreateAPIKey(tt.ctx, 1, tt.req)
+			assert.ErrorIs(t, err, tt.expected)
+			assert.Nil(t, created)
+			keyRepo.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAPIKeyService_RevokeAPIKey_NotFound(t *testing.T) {
+	keyRepo := new(mockAPIKeyRepo)
+	svc := services.NewAPIKeyService(new(mockUserRepo), keyRepo, services.NewAuthorizer())
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	keyRepo.On("RevokeAPIKey", ctx, uint(1), uint(3)).Return(gorm.ErrRecordNotFound)
+
+	err := svc.RevokeAPIKey(ctx, 1, 3)
+	assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	const key = "uoa_0a1b2c3d_secret"
+	expired := time.Now().Add(-time.Minute)
+	revoked := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name     string
+		key      string
+		stored   *models.APIKey
+		owner    *models.User
+		expected error
+	}{
+		{name: "valid", key: key, stored: &models.APIKey{ID: 5, UserID: 1, Scopes: "orders:read"}, owner: &models.User{ID: 1, Role: models.RoleAdmin}},
+		{name: "unknown", key: key, expected: services.ErrInvalidAPIKey},
+		{name: "revoked", key: key, stored: &models.APIKey{ID: 5, UserID: 1, RevokedAt: &revoked}, expected: services.ErrInvalidAPIKey},
+		{name: "expired", key: key, stored: &models.APIKey{ID: 5, UserID: 1, ExpiresAt: &expired}, expected: services.ErrInvalidAPIKey},
+		{name: "owner deleted", key: key, stored: &models.APIKey{ID: 5, UserID: 1}, expected: services.ErrInvalidAPIKey},
+		{name: "not an API key", key: "eyJhbGciOi", expected: services.ErrInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(mockUserRepo)
+			keyRepo := new(mockAPIKeyRepo)
+			svc := services.NewAPIKeyService(userRepo, keyRepo, services.NewAuthorizer())
+			ctx := context.Background()
+
+			keyRepo.On("GetAPIKeyByHash", ctx, utils.HashToken(tt.key)).Return(tt.stored, nil).Maybe()
+			userRepo.On("GetUserByID", ctx, uint(1)).Return(tt.owner, nil).Maybe()
+			keyRepo.On("TouchAPIKey", ctx, uint(5), mock.AnythingOfType("time.Time")).Return(nil).Maybe()
+
+			principal, err := svc.Authenticate(ctx, tt.key)
+			if tt.expected != nil {
+				assert.ErrorIs(t, err, tt.expected)
+				keyRepo.AssertNotCalled(t, "TouchAPIKey", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, services.Principal{UserID: 1, Role: models.RoleAdmin, APIKeyID: 5, Scopes: []string{models.ScopeOrdersRead}}, principal)
+			keyRepo.AssertCalled(t, "TouchAPIKey", ctx, uint(5), mock.AnythingOfType("time.Time"))
+		})
+	}
+}
@@ -3,15 +3,20 @@ package test
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/iwtcode/user-order-api/internal/handlers"
 	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -20,9 +25,38 @@ type mockAuthService struct {
 	mock.Mock
 }
 
-func (m *mockAuthService) Login(ctx context.Context, email, password string) (string, error) {
-	args := m.Called(ctx, email, password)
-	return args.String(0), args.Error(1)
+func (m *mockAuthService) Login(ctx context.Context, email, password string, client services.ClientInfo, scopes []string) (*services.LoginResult, error) {
+	args := m.Called(ctx, email, password, client, scopes)
+	result, _ := args.Get(0).(*services.LoginResult)
+	return result, args.Error(1)
+}
+func (m *mockAuthService) LoginMFA(ctx context.Context, mfaToken, code string, client services.ClientInfo, scopes []string) (*services.TokenPair, error) {
+	args := m.Called(ctx, mfaToken, code, client, scopes)
+	tokens, _ := args.Get(0).(*services.TokenPair)
+	return tokens, args.Error(1)
+}
+func (m *mockAuthService) Refresh(ctx context.Context, refreshToken string, client services.ClientInfo) (*services.TokenPair, error) {
+	args := m.Called(ctx, refreshToken, client)
+	tokens, _ := args.Get(0).(*services.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *mockAuthService) Logout(ctx context.Context, userID uint, jti string, expiresAt time.Time, refreshToken string) error {
+	args := m.Called(ctx, userID, jti, expiresAt, refreshToken)
+	return args.Error(0)
+}
+func (m *mockAuthService) LogoutAll(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+func (m *mockAuthService) RevokeOtherSessions(ctx context.Context, userID uint) (*services.TokenPair, error) {
+	args := m.Called(ctx, userID)
+	tokens, _ := args.Get(0).(*services.TokenPair)
+	return tokens, args.Error(1)
+}
+func (m *mockAuthService) UnlockAccount(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
 }
 
 func TestAuthHandler_Login(t *testing.T) {
@@ -39,16 +73,43 @@ func TestAuthHandler_Login(t *testing.T) {
 			name:        "success",
 			requestBody: gin.H{"email": "test@example.com", "password": "pass123"},
 			mockSetup: func(m *mockAuthService) {
-				m.On("Login", mock.Anything, "test@example.com", "pass123").Return("token123", nil)
+				m.On("Login", mock.Anything, "test@example.com", "pass123", mock.Anything, mock.Anything).Return(&services.LoginResult{Tokens: &services.TokenPair{AccessToken: "token123", RefreshToken: "refresh123", ExpiresIn: 900}}, nil)
 			},
 			expectedCode: http.StatusOK,
-			expectedBody: map[string]interface{}{"token": "token123"},
+			expectedBody: map[string]interface{}{"token": "token123", "refresh_token": "refresh123", "token_type": "Bearer", "expires_in": float64(900)},
+		},
+		{
+			name:        "requested scopes",
+			requestBody: gin.H{"email": "test@example.com", "password": "pass123", "scopes": []string{"orders:read"}},
+			mockSetup: func(m *mockAuthService) {
+				m.On("Login", mock.Anything, "test@example.com", "pass123", mock.Anything, []string{"orders:read"}).Return(&services.LoginResult{Tokens: &services.TokenPair{AccessToken: "token123", RefreshToken: "refresh123", ExpiresIn: 900, Scopes: []string{"orders:read"}}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"token": "token123", "scope": "orders:read"},
+		},
+		{
+			name:        "unknown scope",
+			requestBody: gin.H{"email": "test@example.com", "password": "pass123", "scopes": []string{"admin:all"}},
+			mockSetup: func(m *mockAuthService) {
+				m.On("Login", mock.Anything, "test@example.com", "pass123", mock.Anything, []string{"admin:all"}).Return(nil, fmt.Errorf("%w: %q", services.ErrInvalidScope, "admin:all"))
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: map[string]interface{}{"error": "Unsupported scope"},
+		},
+		{
+			name:        "mfa required",
+			requestBody: gin.H{"email": "test@example.com", "password": "pass123"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("Login", mock.Anything, "test@example.com", "pass123", mock.Anything, mock.Anything).Return(&services.LoginResult{MFA: &services.MFAChallenge{Token: "mfa123", ExpiresIn: 300}}, nil)
+			},
+			expectedCode: http.StatusAccepted,
+			expectedBody: map[string]interface{}{"mfa_required": true, "mfa_token": "mfa123", "expires_in": float64(300), "token": nil},
 		},
 		{
 			name:        "invalid credentials",
 			requestBody: gin.H{"email": "test@example.com", "password": "wrong"},
 			mockSetup: func(m *mockAuthService) {
-				m.On("Login", mock.Anything, "test@example.com", "wrong").Return("", services.ErrInvalidCredentials)
+				m.On("Login", mock.Anything, "test@example.com", "wrong", mock.Anything, mock.Anything).Return(nil, services.ErrInvalidCredentials)
 			},
 			expectedCode: http.StatusUnauthorized,
 			expectedBody: map[string]interface{}{"error": "Invalid email or password"},
@@ -64,7 +125,7 @@ func TestAuthHandler_Login(t *testing.T) {
 			name:        "internal error",
 			requestBody: gin.H{"email": "test@example.com", "password": "pass123"},
 			mockSetup: func(m *mockAuthService) {
-				m.On("Login", mock.Anything, "test@example.com", "pass123").Return("", errors.New("db error"))
+				m.On("Login", mock.Anything, "test@example.com", "pass123", mock.Anything, mock.Anything).Return(nil, errors.New("db error"))
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: map[string]interface{}{"error": "Login failed"},
@@ -98,3 +159,332 @@ func TestAuthHandler_Login(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandler_Login_Locked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		reason       error
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name:         "account locked",
+			reason:       services.ErrAccountLocked,
+			expectedCode: http.StatusLocked,
+			expectedBody: map[string]interface{}{"error": "Account is temporarily locked due to too many failed login attempts"},
+		},
+		{
+			name:         "ip throttled",
+			reason:       services.ErrTooManyLoginAttempts,
+			expectedCode: http.StatusTooManyRequests,
+			expectedBody: map[string]interface{}{"error": "Too many login attempts, try again later"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockAuthService)
+			locked := &services.LoginLockedError{RetryAfter: 89500 * time.Millisecond, Reason: tt.reason}
+			mockSvc.On("Login", mock.Anything, "test@example.com", "pass123", services.ClientInfo{IP: "192.0.2.1", UserAgent: "test-agent/1.0"}, mock.Anything).Return(nil, locked)
+			h := handlers.NewAuthHandler(mockSvc)
+
+			r := gin.New()
+			r.POST("/login", h.Login)
+
+			body, _ := json.Marshal(gin.H{"email": "test@example.com", "password": "pass123"})
+			req, _ := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "test-agent/1.0")
+			req.RemoteAddr = "192.0.2.1:1234"
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			// Retry-After округляется вверх до целых секунд
+			assert.Equal(t, "90", w.Header().Get("Retry-After"))
+			var resp map[string]interface{}
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			assert.Equal(t, tt.expectedBody, resp)
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_LoginMFA(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		requestBody  gin.H
+		mockSetup    func(m *mockAuthService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name:        "success",
+			requestBody: gin.H{"mfa_token": "mfa123", "code": "123456"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("LoginMFA", mock.Anything, "mfa123", "123456", mock.Anything, mock.Anything).Return(&services.TokenPair{AccessToken: "token123", RefreshToken: "refresh123", ExpiresIn: 900}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"token": "token123", "refresh_token": "refresh123"},
+		},
+		{
+			name:        "invalid code",
+			requestBody: gin.H{"mfa_token": "mfa123", "code": "000000"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("LoginMFA", mock.Anything, "mfa123", "000000", mock.Anything, mock.Anything).Return(nil, services.ErrInvalidMFACode)
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: map[string]interface{}{"error": "Invalid two-factor authentication code"},
+		},
+		{
+			name:        "invalid token",
+			requestBody: gin.H{"mfa_token": "expired", "code": "123456"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("LoginMFA", mock.Anything, "expired", "123456", mock.Anything, mock.Anything).Return(nil, services.ErrInvalidMFAToken)
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: map[string]interface{}{"error": "Invalid or expired MFA token"},
+		},
+		{
+			name:         "validation error",
+			requestBody:  gin.H{"mfa_token": "mfa123"},
+			mockSetup:    func(m *mockAuthService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockAuthService)
+			tt.mockSetup(mockSvc)
+			h := handlers.NewAuthHandler(mockSvc)
+
+			r := gin.New()
+			r.POST("/auth/login/mfa", h.LoginMFA)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequest(http.MethodPost, "/auth/login/mfa", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			var resp map[string]interface{}
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			for k, v := range tt.expectedBody {
+				assert.Equal(t, v, resp[k])
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		requestBody  gin.H
+		mockSetup    func(m *mockAuthService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name:        "success",
+			requestBody: gin.H{"refresh_token": "refresh123"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("Refresh", mock.Anything, "refresh123", mock.Anything).Return(&services.TokenPair{AccessToken: "token456", RefreshToken: "refresh456", ExpiresIn: 900}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"token": "token456", "refresh_token": "refresh456"},
+		},
+		{
+			name:        "reused token",
+			requestBody: gin.H{"refresh_token": "refresh123"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("Refresh", mock.Anything, "refresh123", mock.Anything).Return(nil, services.ErrRefreshTokenReused)
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: map[string]interface{}{"error": "Refresh token has already been used, please log in again"},
+		},
+		{
+			name:        "invalid token",
+			requestBody: gin.H{"refresh_token": "bad"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("Refresh", mock.Anything, "bad", mock.Anything).Return(nil, services.ErrInvalidRefreshToken)
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: map[string]interface{}{"error": "Invalid or expired refresh token"},
+		},
+		{
+			name:         "validation error",
+			requestBody:  gin.H{},
+			mockSetup:    func(m *mockAuthService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: map[string]interface{}{"error": "Validation failed"},
+		},
+		{
+			name:        "internal error",
+			requestBody: gin.H{"refresh_token": "refresh123"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("Refresh", mock.Anything, "refresh123", mock.Anything).Return(nil, errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: map[string]interface{}{"error": "Token refresh failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockAuthService)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockSvc)
+			}
+			h := handlers.NewAuthHandler(mockSvc)
+
+			r := gin.Default()
+			r.POST("/refresh", h.Refresh)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequest(http.MethodPost, "/refresh", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			var resp map[string]interface{}
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			for k, v := range tt.expectedBody {
+				assert.Equal(t, v, resp[k])
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Logout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exp := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name         string
+		requestBody  string
+		mockSetup    func(m *mockAuthService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name:        "success without body",
+			requestBody: "",
+			mockSetup: func(m *mockAuthService) {
+				m.On("Logout", mock.Anything, uint(1), "jti-1", exp, "").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:        "success with refresh token",
+			requestBody: `{"refresh_token":"refresh123"}`,
+			mockSetup: func(m *mockAuthService) {
+				m.On("Logout", mock.Anything, uint(1), "jti-1", exp, "refresh123").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "invalid body",
+			requestBody:  `{"refresh_token":`,
+			mockSetup:    func(m *mockAuthService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:        "internal error",
+			requestBody: "",
+			mockSetup: func(m *mockAuthService) {
+				m.On("Logout", mock.Anything, uint(1), "jti-1", exp, "").Return(errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: map[string]interface{}{"error": "Logout failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockAuthService)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockSvc)
+			}
+			h := handlers.NewAuthHandler(mockSvc)
+
+			r := gin.Default()
+			r.Use(func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				c.Set("jti", "jti-1")
+				c.Set("token_expires_at", exp)
+				c.Next()
+			})
+			r.POST("/logout", h.Logout)
+
+			req, _ := http.NewRequest(http.MethodPost, "/logout", bytes.NewBufferString(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			var resp map[string]interface{}
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			for k, v := range tt.expectedBody {
+				assert.Equal(t, v, resp[k])
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_LogoutAll(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockSvc := new(mockAuthService)
+	mockSvc.On("LogoutAll", mock.Anything, uint(1)).Return(nil)
+	h := handlers.NewAuthHandler(mockSvc)
+
+	r := gin.Default()
+	r.Use(addUserIDToContext(1))
+	r.POST("/logout-all", h.LogoutAll)
+
+	req, _ := http.NewRequest(http.MethodPost, "/logout-all", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestAuthHandler_JWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm:      utils.JWTAlgEdDSA,
+		KeyID:          "main",
+		PrivateKeyFile: writePrivateKeyPEM(t, "signing.pem", edKey),
+	})
+
+	h := handlers.NewAuthHandler(new(mockAuthService))
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", h.JWKS)
+
+	req, _ := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string][]map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Len(t, resp["keys"], 1)
+	assert.Equal(t, "main", resp["keys"][0]["kid"])
+	assert.Equal(t, "OKP", resp["keys"][0]["kty"])
+	assert.NotContains(t, resp["keys"][0], "d")
+}
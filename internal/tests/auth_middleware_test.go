@@ -0,0 +1,366 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iwtcode/user-order-api/internal/middleware"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validToken, _ := utils.GenerateJWT(1, models.RoleUser, nil)
+
+	tests := []struct {
+		name         string
+		header       string
+		mockSetup    func(m *mockRevocationStore)
+		expectedCode int
+	}{
+		{
+			name:   "valid token",
+			header: "Bearer " + validToken,
+			mockSetup: func(m *mockRevocationStore) {
+				m.On("IsRevoked", mock.Anything, mock.AnythingOfType("string"), uint(1), mock.Anything, mock.Anything).Return(false, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "revoked token",
+			header: "Bearer " + validToken,
+			mockSetup: func(m *mockRevocationStore) {
+				m.On("IsRevoked", mock.Anything, mock.AnythingOfType("string"), uint(1), mock.Anything, mock.Anything).Return(true, nil)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "revocation store error",
+			header: "Bearer " + validToken,
+			mockSetup: func(m *mockRevocationStore) {
+				m.On("IsRevoked", mock.Anything, mock.AnythingOfType("string"), uint(1), mock.Anything, mock.Anything).Return(false, errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "missing header",
+			header:       "",
+			mockSetup:    func(m *mockRevocationStore) {},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "malformed token",
+			header:       "Bearer not-a-token",
+			mockSetup:    func(m *mockRevocationStore) {},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(mockRevocationStore)
+			tt.mockSetup(store)
+
+			r := gin.New()
+			r.Use(middleware.JWTAuthMiddleware(store))
+			r.GET("/protected", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id"), "jti": c.GetString("jti")})
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestJWTAuthMiddleware_Scopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scopedToken, _ := utils.GenerateJWT(1, models.RoleUser, []string{models.ScopeUsersRead, models.ScopeOrdersRead})
+	legacyToken, _ := utils.GenerateJWT(1, models.RoleUser, nil)
+
+	tests := []struct {
+		name     string
+		token    string
+		expected []string
+	}{
+		{name: "scoped token", token: scopedToken, expected: []string{models.ScopeUsersRead, models.ScopeOrdersRead}},
+		{name: "token without scope claim", token: legacyToken, expected: models.AllScopes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(mockRevocationStore)
+			store.On("IsRevoked", mock.Anything, mock.AnythingOfType("string"), uint(1), mock.Anything, mock.Anything).Return(false, nil)
+
+			var principal services.Principal
+			r := gin.New()
+			r.Use(middleware.JWTAuthMiddleware(store))
+			r.GET("/protected", func(c *gin.Context) {
+				principal, _ = services.PrincipalFromContext(c.Request.Context())
+				c.JSON(http.StatusOK, gin.H{"scopes": c.GetStringSlice("scopes")})
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var resp struct {
+				Scopes []string `json:"scopes"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.expected, resp.Scopes)
+			assert.Equal(t, tt.expected, principal.Scopes)
+		})
+	}
+}
+
+func TestJWTAuthMiddleware_ClientToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token, _ := utils.GenerateClientJWT("uoc_0123456789abcdef", []string{models.ScopeOrdersRead})
+	store := new(mockRevocationStore)
+	store.On("IsRevoked", mock.Anything, mock.AnythingOfType("string"), uint(0), mock.Anything, mock.Anything).Return(false, nil)
+
+	var principal services.Principal
+	r := gin.New()
+	r.Use(middleware.JWTAuthMiddleware(store))
+	r.GET("/protected", func(c *gin.Context) {
+		principal, _ = services.PrincipalFromContext(c.Request.Context())
+		_, hasUserID := c.Get("user_id")
+		c.JSON(http.StatusOK, gin.H{"client_id": c.GetString("client_id"), "has_user_id": hasUserID})
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"client_id":"uoc_0123456789abcdef","has_user_id":false}`, w.Body.String())
+	assert.True(t, principal.IsClient())
+	assert.Equal(t, []string{models.ScopeOrdersRead}, principal.Scopes)
+}
+
+func TestJWTAuthMiddleware_SessionToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token, jti, err := utils.GenerateSessionJWT(1, models.RoleUser, nil, 7)
+	require.NoError(t, err)
+	store := new(mockRevocationStore)
+	store.On("IsRevoked", mock.Anything, jti, uint(1), uint(7), mock.Anything).Return(false, nil)
+
+	var principal services.Principal
+	r := gin.New()
+	r.Use(middleware.JWTAuthMiddleware(store))
+	r.GET("/protected", func(c *gin.Context) {
+		principal, _ = services.PrincipalFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"session_id": c.GetUint("session_id")})
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"session_id":7}`, w.Body.String())
+	assert.Equal(t, uint(7), principal.SessionID)
+}
+
+func TestJWTAuthMiddleware_RevokedSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token, jti, err := utils.GenerateSessionJWT(1, models.RoleUser, nil, 7)
+	require.NoError(t, err)
+	store := new(mockRevocationStore)
+	store.On("IsRevoked", mock.Anything, jti, uint(1), uint(7), mock.Anything).Return(true, nil)
+
+	r := gin.New()
+	r.Use(middleware.JWTAuthMiddleware(store))
+	r.GET("/protected", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"Token has been revoked"}`, w.Body.String())
+}
+
+func TestJWTAuthMiddleware_ImpersonationToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token, jti, err := utils.GenerateImpersonationJWT(1, models.RoleUser, nil, 9, 15*time.Minute)
+	require.NoError(t, err)
+	store := new(mockRevocationStore)
+	store.On("IsRevoked", mock.Anything, jti, uint(1), mock.Anything, mock.Anything).Return(false, nil)
+
+	var principal services.Principal
+	r := gin.New()
+	r.Use(middleware.JWTAuthMiddleware(store))
+	r.GET("/protected", func(c *gin.Context) {
+		principal, _ = services.PrincipalFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id"), "actor_id": c.GetUint("actor_id")})
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":1,"actor_id":9}`, w.Body.String())
+	assert.True(t, principal.IsImpersonated())
+	assert.Equal(t, uint(9), principal.ActorID)
+}
+
+func TestJWTAuthMiddleware_ErrorClasses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configureStrictJWTForTest(t)
+
+	tests := []struct {
+		name     string
+		method   jwt.SigningMethod
+		modify   func(claims jwt.MapClaims)
+		expected string
+	}{
+		{name: "expired", modify: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }, expected: "Token is expired"},
+		{name: "not valid yet", modify: func(claims jwt.MapClaims) { claims["nbf"] = time.Now().Add(time.Hour).Unix() }, expected: "Token is not valid yet"},
+		{name: "issuer", modify: func(claims jwt.MapClaims) { claims["iss"] = "other" }, expected: "Invalid token issuer"},
+		{name: "audience", modify: func(claims jwt.MapClaims) { claims["aud"] = "other" }, expected: "Invalid token audience"},
+		{name: "signature", method: jwt.SigningMethodHS512, modify: func(claims jwt.MapClaims) {}, expected: "Invalid token signature"},
+		{name: "claims", modify: func(claims jwt.MapClaims) { delete(claims, "exp") }, expected: "Invalid token claims"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == nil {
+				method = jwt.SigningMethodHS256
+			}
+			claims := validTestClaims()
+			tt.modify(claims)
+
+			r := gin.New()
+			r.Use(middleware.JWTAuthMiddleware(new(mockRevocationStore)))
+			r.GET("/protected", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestJWT(t, method, claims))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.JSONEq(t, `{"error":"`+tt.expected+`"}`, w.Body.String())
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		r := gin.New()
+		r.Use(middleware.JWTAuthMiddleware(new(mockRevocationStore)))
+		r.GET("/protected", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer not-a-token")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.JSONEq(t, `{"error":"Malformed token"}`, w.Body.String())
+	})
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validToken, _ := utils.GenerateJWT(1, models.RoleUser, nil)
+
+	tests := []struct {
+		name         string
+		header       string
+		mockSetup    func(keys *mockAPIKeyService, store *mockRevocationStore)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "valid API key",
+			header: "ApiKey uoa_0a1b2c3d_secret",
+			mockSetup: func(keys *mockAPIKeyService, store *mockRevocationStore) {
+				keys.On("Authenticate", mock.Anything, "uoa_0a1b2c3d_secret").Return(services.Principal{UserID: 1, Role: models.RoleUser, APIKeyID: 5}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"user_id":1,"role":"user","api_key_id":5}`,
+		},
+		{
+			name:   "invalid API key",
+			header: "ApiKey uoa_0a1b2c3d_wrong",
+			mockSetup: func(keys *mockAPIKeyService, store *mockRevocationStore) {
+				keys.On("Authenticate", mock.Anything, "uoa_0a1b2c3d_wrong").Return(services.Principal{}, services.ErrInvalidAPIKey)
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"error":"Invalid, expired or revoked API key"}`,
+		},
+		{
+			name:   "API key lookup error",
+			header: "ApiKey uoa_0a1b2c3d_secret",
+			mockSetup: func(keys *mockAPIKeyService, store *mockRevocationStore) {
+				keys.On("Authenticate", mock.Anything, "uoa_0a1b2c3d_secret").Return(services.Principal{}, errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:   "bearer token still accepted",
+			header: "Bearer " + validToken,
+			mockSetup: func(keys *mockAPIKeyService, store *mockRevocationStore) {
+				store.On("IsRevoked", mock.Anything, mock.AnythingOfType("string"), uint(1), mock.Anything, mock.Anything).Return(false, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"user_id":1,"role":"user","api_key_id":0}`,
+		},
+		{
+			name:         "missing header",
+			mockSetup:    func(keys *mockAPIKeyService, store *mockRevocationStore) {},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := new(mockAPIKeyService)
+			store := new(mockRevocationStore)
+			tt.mockSetup(keys, store)
+
+			r := gin.New()
+			r.Use(middleware.AuthMiddleware(store, keys))
+			r.GET("/protected", func(c *gin.Context) {
+				principal, _ := services.PrincipalFromContext(c.Request.Context())
+				c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id"), "role": c.GetString("role"), "api_key_id": principal.APIKeyID})
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+			keys.AssertExpectations(t)
+		})
+	}
+}
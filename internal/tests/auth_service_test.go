@@ -4,60 +4,727 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/services"
 	"github.com/iwtcode/user-order-api/internal/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+type mockRefreshTokenRepo struct {
+	mock.Mock
+}
+
+func (m *mockRefreshTokenRepo) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+func (m *mockRefreshTokenRepo) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	args := m.Called(ctx, hash)
+	token, _ := args.Get(0).(*models.RefreshToken)
+	return token, args.Error(1)
+}
+func (m *mockRefreshTokenRepo) RevokeRefreshToken(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+func (m *mockRefreshTokenRepo) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+func (m *mockRefreshTokenRepo) RevokeUserRefreshTokens(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+type mockRevocationStore struct {
+	mock.Mock
+}
+
+func (m *mockRevocationStore) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, userID, expiresAt)
+	return args.Error(0)
+}
+func (m *mockRevocationStore) RevokeAllForUser(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+func (m *mockRevocationStore) RevokeSession(ctx context.Context, sessionID, userID uint) error {
+	args := m.Called(ctx, sessionID, userID)
+	return args.Error(0)
+}
+func (m *mockRevocationStore) IsRevoked(ctx context.Context, jti string, userID, sessionID uint, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, jti, userID, sessionID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestAuthService_Login_Success(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewAuthService(repo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
 	ctx := context.Background()
 
 	password := "12345678"
 	hash, _ := utils.HashPassword(password)
-	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{Email: "a@b.com", PasswordHash: hash}, nil)
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com", PasswordHash: hash}, nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
 
-	token, err := svc.Login(ctx, "a@b.com", password)
+	result, err := svc.Login(ctx, "a@b.com", password, services.ClientInfo{}, nil)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, token)
+	require.NotNil(t, result.Tokens)
+	assert.Nil(t, result.MFA)
+	tokens := result.Tokens
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	stored := refreshRepo.Calls[0].Arguments.Get(1).(*models.RefreshToken)
+	assert.Equal(t, uint(1), stored.UserID)
+	assert.Equal(t, utils.HashToken(tokens.RefreshToken), stored.TokenHash)
+	assert.NotEmpty(t, stored.FamilyID)
+	// Без запроса областей действия токен получает все
+	assert.Equal(t, models.AllScopes, tokens.Scopes)
+	assert.Equal(t, "users:read users:write orders:read orders:write", stored.Scopes)
+}
+
+func TestAuthService_Login_CreatesSession(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	sessionRepo := newSessionRepoMock()
+	svc := services.NewAuthService(repo, refreshRepo, sessionRepo, new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	hash, _ := utils.HashPassword("12345678")
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com", PasswordHash: hash}, nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	client := services.ClientInfo{IP: "10.0.0.1", UserAgent: "Mozilla/5.0"}
+	result, err := svc.Login(ctx, "a@b.com", "12345678", client, nil)
+	require.NoError(t, err)
+	require.NotNil(t, result.Tokens)
+
+	created := sessionRepo.Calls[0].Arguments.Get(1).(*models.Session)
+	stored := refreshRepo.Calls[0].Arguments.Get(1).(*models.RefreshToken)
+	assert.Equal(t, uint(1), created.UserID)
+	assert.Equal(t, "Mozilla/5.0", created.UserAgent)
+	assert.Equal(t, "10.0.0.1", created.IPAddress)
+	assert.Equal(t, stored.FamilyID, created.FamilyID)
+	// Access-токен привязан к сессии claim sid
+	claims, err := utils.ParseJWT(result.Tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), claims["sid"])
+	assert.Equal(t, stored.ExpiresAt, created.ExpiresAt)
+}
+
+func TestAuthService_Login_RequestedScopes(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	hash, _ := utils.HashPassword("12345678")
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com", PasswordHash: hash}, nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	result, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{}, []string{models.ScopeOrdersRead, models.ScopeUsersRead, models.ScopeOrdersRead})
+	require.NoError(t, err)
+	// Повторы убираются, список сортируется
+	assert.Equal(t, []string{models.ScopeOrdersRead, models.ScopeUsersRead}, result.Tokens.Scopes)
+	claims, err := utils.ParseJWT(result.Tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "orders:read users:read", claims["scope"])
+	stored := refreshRepo.Calls[0].Arguments.Get(1).(*models.RefreshToken)
+	assert.Equal(t, "orders:read users:read", stored.Scopes)
+}
+
+func TestAuthService_Login_InvalidScope(t *testing.T) {
+	repo := new(mockUserRepo)
+	throttle := newPermissiveLoginThrottle()
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), throttle, services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	result, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{IP: "10.0.0.1"}, []string{"admin:all"})
+	assert.ErrorIs(t, err, services.ErrInvalidScope)
+	assert.Nil(t, result)
+	// Пароль не проверяется, попытка не учитывается
+	repo.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+	throttle.AssertNotCalled(t, "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewAuthService(repo)
+	throttle := newPermissiveLoginThrottle()
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), throttle, services.NewAuthorizer(), services.AuthSettings{})
 	ctx := context.Background()
 
 	hash, _ := utils.HashPassword("otherpass")
 	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{Email: "a@b.com", PasswordHash: hash}, nil)
 
-	token, err := svc.Login(ctx, "a@b.com", "wrongpass")
+	result, err := svc.Login(ctx, "a@b.com", "wrongpass", services.ClientInfo{IP: "10.0.0.1"}, nil)
 	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
-	assert.Empty(t, token)
+	assert.Nil(t, result)
+	throttle.AssertCalled(t, "RecordFailure", ctx, "a@b.com", "10.0.0.1")
+	throttle.AssertNotCalled(t, "RecordSuccess", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_RehashesOutdatedPassword(t *testing.T) {
+	configurePasswordHashingForTest(t, utils.PasswordHashConfig{Algorithm: utils.PasswordHashBcrypt, BcryptCost: bcrypt.MinCost})
+	oldHash, _ := utils.HashPassword("12345678")
+	configurePasswordHashingForTest(t, testArgon2Config)
+
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com", PasswordHash: oldHash}, nil)
+	repo.On("UpdatePasswordHash", ctx, uint(1), mock.AnythingOfType("string")).Return(nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	_, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{}, nil)
+	require.NoError(t, err)
+	newHash := repo.Calls[1].Arguments.Get(2).(string)
+	assert.Regexp(t, `^\$argon2id\$`, newHash)
+	assert.True(t, utils.CheckPasswordHash("12345678", newHash))
+}
+
+func TestAuthService_Login_CurrentHashNotRehashed(t *testing.T) {
+	configurePasswordHashingForTest(t, testArgon2Config)
+	hash, _ := utils.HashPassword("12345678")
+
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com", PasswordHash: hash}, nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	_, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{}, nil)
+	require.NoError(t, err)
+	repo.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_Locked(t *testing.T) {
+	repo := new(mockUserRepo)
+	throttle := new(mockLoginThrottle)
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), throttle, services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	throttle.On("Check", ctx, "a@b.com", "10.0.0.1").Return(&services.LoginLockedError{RetryAfter: time.Minute, Reason: services.ErrAccountLocked})
+
+	result, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{IP: "10.0.0.1"}, nil)
+	assert.ErrorIs(t, err, services.ErrAccountLocked)
+	assert.Nil(t, result)
+	// Во время блокировки пароль не проверяется даже верный
+	repo.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+	throttle.AssertNotCalled(t, "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_LoginMFA_InvalidCodeCountsForIP(t *testing.T) {
+	throttle := newPermissiveLoginThrottle()
+	mfa := new(mockMFAService)
+	svc := services.NewAuthService(new(mockUserRepo), new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), mfa, throttle, services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	mfa.On("CompleteChallenge", ctx, "mfa-token", "000000").Return(uint(0), services.ErrInvalidMFACode)
+
+	_, err := svc.LoginMFA(ctx, "mfa-token", "000000", services.ClientInfo{IP: "10.0.0.1"}, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+	throttle.AssertCalled(t, "RecordFailure", ctx, "", "10.0.0.1")
 }
 
 func TestAuthService_Login_UserNotFound(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewAuthService(repo)
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
 	ctx := context.Background()
 
 	repo.On("GetUserByEmail", ctx, "notfound@b.com").Return(nil, nil)
 
-	token, err := svc.Login(ctx, "notfound@b.com", "any")
+	result, err := svc.Login(ctx, "notfound@b.com", "any", services.ClientInfo{}, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	assert.Nil(t, result)
+}
+
+func TestAuthService_Login_UnknownEmailDoesSameWorkAsWrongPassword(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+	hasher := useCountingPasswordHasher(t)
+
+	hash, _ := utils.HashPassword("12345678")
+	repo.On("GetUserByEmail", ctx, "known@b.com").Return(&models.User{ID: 1, Email: "known@b.com", PasswordHash: hash}, nil)
+	repo.On("GetUserByEmail", ctx, "unknown@b.com").Return(nil, nil)
+	hasher.take()
+
+	_, err := svc.Login(ctx, "known@b.com", "wrongpass", services.ClientInfo{}, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	wrongPassword := hasher.take()
+	_, err = svc.Login(ctx, "unknown@b.com", "wrongpass", services.ClientInfo{}, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	unknownEmail := hasher.take()
+
+	// Оба пути проверяют пароль по хешу ровно один раз
+	assert.Equal(t, passwordHasherCalls{verifies: 1}, wrongPassword)
+	assert.Equal(t, wrongPassword, unknownEmail)
+}
+
+func TestAuthService_Login_EmailNotVerified(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{RequireVerifiedEmail: true})
+	ctx := context.Background()
+
+	hash, _ := utils.HashPassword("12345678")
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com", PasswordHash: hash}, nil)
+
+	result, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{}, nil)
+	assert.ErrorIs(t, err, services.ErrEmailNotVerified)
+	assert.Nil(t, result)
+	refreshRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+
+	// Неверный пароль не раскрывает, подтверждён ли email
+	result, err = svc.Login(ctx, "a@b.com", "wrongpass", services.ClientInfo{}, nil)
 	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
-	assert.Empty(t, token)
+	assert.Nil(t, result)
+}
+
+func TestAuthService_Login_MFARequired(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	mfa := new(mockMFAService)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), mfa, newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	hash, _ := utils.HashPassword("12345678")
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com", PasswordHash: hash}, nil)
+	mfa.On("IsEnabled", ctx, uint(1)).Return(true, nil)
+	mfa.On("StartChallenge", ctx, uint(1)).Return(&services.MFAChallenge{Token: "mfa-token", ExpiresIn: 300}, nil)
+
+	result, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, result.Tokens)
+	assert.Equal(t, "mfa-token", result.MFA.Token)
+	// Пока не введён код, токены не выдаются
+	refreshRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_LoginMFA(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	mfa := new(mockMFAService)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), mfa, newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	mfa.On("CompleteChallenge", ctx, "mfa-token", "123456").Return(uint(1), nil)
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	tokens, err := svc.LoginMFA(ctx, "mfa-token", "123456", services.ClientInfo{}, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+}
+
+func TestAuthService_LoginMFA_InvalidCode(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	mfa := new(mockMFAService)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), new(mockRevocationStore), mfa, newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	mfa.On("CompleteChallenge", ctx, "mfa-token", "000000").Return(uint(0), services.ErrInvalidMFACode)
+
+	tokens, err := svc.LoginMFA(ctx, "mfa-token", "000000", services.ClientInfo{}, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+	assert.Nil(t, tokens)
+	refreshRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
 }
 
 func TestAuthService_Login_RepoError(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewAuthService(repo)
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
 	ctx := context.Background()
 
 	repo.On("GetUserByEmail", ctx, "a@b.com").Return(nil, errors.New("db error"))
 
-	token, err := svc.Login(ctx, "a@b.com", "12345678")
+	result, err := svc.Login(ctx, "a@b.com", "12345678", services.ClientInfo{}, nil)
 	assert.Error(t, err)
-	assert.Empty(t, token)
+	assert.Nil(t, result)
+}
+
+func TestAuthService_Refresh_Success(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour)}
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("old-token")).Return(stored, nil)
+	refreshRepo.On("RevokeRefreshToken", ctx, uint(5)).Return(nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1}, nil)
+
+	tokens, err := svc.Refresh(ctx, "old-token", services.ClientInfo{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEqual(t, "old-token", tokens.RefreshToken)
+	rotated := refreshRepo.Calls[2].Arguments.Get(1).(*models.RefreshToken)
+	assert.Equal(t, "fam", rotated.FamilyID)
+	refreshRepo.AssertNotCalled(t, "RevokeTokenFamily", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Refresh_TouchesSession(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	sessionRepo := new(mockSessionRepo)
+	svc := services.NewAuthService(repo, refreshRepo, sessionRepo, new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	lastSeen := time.Now().Add(-time.Hour).UTC()
+	session := &models.Session{ID: 7, UserID: 1, FamilyID: "fam", UserAgent: "Mozilla/5.0", IPAddress: "10.0.0.1", LastSeenAt: lastSeen}
+	stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour)}
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("old-token")).Return(stored, nil)
+	refreshRepo.On("RevokeRefreshToken", ctx, uint(5)).Return(nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1}, nil)
+	sessionRepo.On("GetSessionByFamilyID", ctx, "fam").Return(session, nil)
+	sessionRepo.On("UpdateSession", ctx, session).Return(nil)
+
+	tokens, err := svc.Refresh(ctx, "old-token", services.ClientInfo{IP: "10.0.0.2", UserAgent: "Mozilla/5.0"})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", session.IPAddress)
+	assert.True(t, session.LastSeenAt.After(lastSeen))
+	claims, err := utils.ParseJWT(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, float64(7), claims["sid"])
+	sessionRepo.AssertNotCalled(t, "CreateSession", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Refresh_RevokedSession(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	sessionRepo := new(mockSessionRepo)
+	svc := services.NewAuthService(repo, refreshRepo, sessionRepo, new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	revokedAt := time.Now().Add(-time.Minute)
+	stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour)}
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("old-token")).Return(stored, nil)
+	refreshRepo.On("RevokeRefreshToken", ctx, uint(5)).Return(nil)
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1}, nil)
+	sessionRepo.On("GetSessionByFamilyID", ctx, "fam").Return(&models.Session{ID: 7, UserID: 1, FamilyID: "fam", RevokedAt: &revokedAt}, nil)
+
+	tokens, err := svc.Refresh(ctx, "old-token", services.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	assert.Nil(t, tokens)
+	refreshRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Refresh_PreservesScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   string
+		expected []string
+	}{
+		{name: "scoped login", stored: "orders:read", expected: []string{models.ScopeOrdersRead}},
+		{name: "token issued before scopes", stored: "", expected: models.AllScopes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockUserRepo)
+			refreshRepo := new(mockRefreshTokenRepo)
+			svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+			ctx := context.Background()
+
+			stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", Scopes: tt.stored, ExpiresAt: time.Now().Add(time.Hour)}
+			refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("old-token")).Return(stored, nil)
+			refreshRepo.On("RevokeRefreshToken", ctx, uint(5)).Return(nil)
+			refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+			repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1}, nil)
+
+			tokens, err := svc.Refresh(ctx, "old-token", services.ClientInfo{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tokens.Scopes)
+			rotated := refreshRepo.Calls[2].Arguments.Get(1).(*models.RefreshToken)
+			assert.Equal(t, models.FormatScopes(tt.expected), rotated.Scopes)
+		})
+	}
+}
+
+func TestAuthService_Refresh_ReuseRevokesFamily(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	revokedAt := time.Now().Add(-time.Minute)
+	stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("old-token")).Return(stored, nil)
+	refreshRepo.On("RevokeTokenFamily", ctx, "fam").Return(nil)
+
+	tokens, err := svc.Refresh(ctx, "old-token", services.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+	assert.Nil(t, tokens)
+	refreshRepo.AssertCalled(t, "RevokeTokenFamily", ctx, "fam")
+}
+
+func TestAuthService_Refresh_ConcurrentReuse(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour)}
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("old-token")).Return(stored, nil)
+	refreshRepo.On("RevokeRefreshToken", ctx, uint(5)).Return(gorm.ErrRecordNotFound)
+	refreshRepo.On("RevokeTokenFamily", ctx, "fam").Return(nil)
+
+	tokens, err := svc.Refresh(ctx, "old-token", services.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+	assert.Nil(t, tokens)
+}
+
+func TestAuthService_Refresh_Expired(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	stored := &models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(-time.Hour)}
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("old-token")).Return(stored, nil)
+
+	tokens, err := svc.Refresh(ctx, "old-token", services.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	assert.Nil(t, tokens)
+}
+
+func TestAuthService_Refresh_Unknown(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("unknown")).Return(nil, nil)
+
+	tokens, err := svc.Refresh(ctx, "unknown", services.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	assert.Nil(t, tokens)
+}
+
+func TestAuthService_Logout_WithRefreshToken(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	revocations := new(mockRevocationStore)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), revocations, newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	exp := time.Now().Add(time.Minute)
+	revocations.On("RevokeToken", ctx, "jti-1", uint(1), exp).Return(nil)
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("refresh")).Return(&models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam"}, nil)
+	refreshRepo.On("RevokeTokenFamily", ctx, "fam").Return(nil)
+
+	err := svc.Logout(ctx, 1, "jti-1", exp, "refresh")
+	assert.NoError(t, err)
+	revocations.AssertExpectations(t)
+	refreshRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Logout_EndsCurrentSession(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	revocations := new(mockRevocationStore)
+	sessionRepo := new(mockSessionRepo)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, sessionRepo, revocations, newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, SessionID: 7})
+
+	exp := time.Now().Add(time.Minute)
+	revocations.On("RevokeToken", ctx, "jti-1", uint(1), exp).Return(nil)
+	sessionRepo.On("GetSessionByID", ctx, uint(7)).Return(&models.Session{ID: 7, UserID: 1, FamilyID: "fam"}, nil)
+	sessionRepo.On("GetSessionByFamilyID", ctx, "fam").Return(&models.Session{ID: 7, UserID: 1, FamilyID: "fam"}, nil)
+	sessionRepo.On("RevokeSessionByFamilyID", ctx, "fam").Return(nil)
+	refreshRepo.On("RevokeTokenFamily", ctx, "fam").Return(nil)
+	revocations.On("RevokeSession", ctx, uint(7), uint(1)).Return(nil)
+
+	err := svc.Logout(ctx, 1, "jti-1", exp, "")
+	assert.NoError(t, err)
+	sessionRepo.AssertExpectations(t)
+	refreshRepo.AssertExpectations(t)
+	revocations.AssertExpectations(t)
+}
+
+// Access-токен, выданный сессии до обновления токенов, перестаёт приниматься, как только сессия завершена
+func TestAuthService_EndedSessionRevokesEarlierAccessTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(t *testing.T, svc services.AuthService, current *services.TokenPair)
+	}{
+		{
+			name: "logout",
+			end: func(t *testing.T, svc services.AuthService, current *services.TokenPair) {
+				claims, err := utils.ParseJWT(current.AccessToken)
+				require.NoError(t, err)
+				ctx := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, SessionID: 7})
+				require.NoError(t, svc.Logout(ctx, 1, claims["jti"].(string), time.Now().Add(time.Minute), ""))
+			},
+		},
+		{
+			name: "refresh token reuse",
+			end: func(t *testing.T, svc services.AuthService, _ *services.TokenPair) {
+				_, err := svc.Refresh(context.Background(), "first-refresh", services.ClientInfo{})
+				require.ErrorIs(t, err, services.ErrRefreshTokenReused)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockUserRepo)
+			refreshRepo := new(mockRefreshTokenRepo)
+			sessionRepo := new(mockSessionRepo)
+			revocationRepo := new(mockRevocationRepo)
+			store := services.NewRevocationStore(revocationRepo, time.Hour)
+			svc := services.NewAuthService(repo, refreshRepo, sessionRepo, store, newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+			ctx := context.Background()
+
+			session := &models.Session{ID: 7, UserID: 1, FamilyID: "fam"}
+			sessionRepo.On("GetSessionByID", mock.Anything, uint(7)).Return(session, nil)
+			sessionRepo.On("GetSessionByFamilyID", mock.Anything, "fam").Return(session, nil)
+			sessionRepo.On("UpdateSession", mock.Anything, session).Return(nil)
+			sessionRepo.On("RevokeSessionByFamilyID", mock.Anything, "fam").Return(nil)
+			revokedAt := time.Now()
+			refreshRepo.On("GetRefreshTokenByHash", mock.Anything, utils.HashToken("first-refresh")).Return(&models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour)}, nil).Once()
+			refreshRepo.On("GetRefreshTokenByHash", mock.Anything, utils.HashToken("first-refresh")).Return(&models.RefreshToken{ID: 5, UserID: 1, FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, nil)
+			refreshRepo.On("RevokeRefreshToken", mock.Anything, uint(5)).Return(nil)
+			refreshRepo.On("CreateRefreshToken", mock.Anything, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+			refreshRepo.On("RevokeTokenFamily", mock.Anything, "fam").Return(nil)
+			repo.On("GetUserByID", mock.Anything, uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+			revocationRepo.On("DeleteExpired", mock.Anything, mock.Anything).Return(nil)
+			revocationRepo.On("ListActiveRevokedTokens", mock.Anything, mock.Anything).Return(nil, nil)
+			revocationRepo.On("ListActiveUserRevocations", mock.Anything, mock.Anything).Return(nil, nil)
+			revocationRepo.On("ListActiveRevokedSessions", mock.Anything, mock.Anything).Return(nil, nil)
+			revocationRepo.On("RevokeToken", mock.Anything, mock.Anything).Return(nil)
+			revocationRepo.On("RevokeSession", mock.Anything, mock.Anything).Return(nil)
+
+			// Токен выдан сессии до обновления токенов
+			earlier, _, err := utils.GenerateSessionJWT(1, models.RoleUser, nil, 7)
+			require.NoError(t, err)
+			claims, err := utils.ParseJWT(earlier)
+			require.NoError(t, err)
+			jti := claims["jti"].(string)
+			issuedAt := time.Unix(int64(claims["iat"].(float64)), 0)
+			revoked, err := store.IsRevoked(ctx, jti, 1, 7, issuedAt)
+			require.NoError(t, err)
+			require.False(t, revoked)
+
+			current, err := svc.Refresh(ctx, "first-refresh", services.ClientInfo{})
+			require.NoError(t, err)
+			tt.end(t, svc, current)
+
+			revoked, err = store.IsRevoked(ctx, jti, 1, 7, issuedAt)
+			require.NoError(t, err)
+			assert.True(t, revoked)
+		})
+	}
+}
+
+func TestAuthService_Logout_ForeignRefreshTokenIgnored(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	revocations := new(mockRevocationStore)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), revocations, newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	exp := time.Now().Add(time.Minute)
+	revocations.On("RevokeToken", ctx, "jti-1", uint(1), exp).Return(nil)
+	refreshRepo.On("GetRefreshTokenByHash", ctx, utils.HashToken("refresh")).Return(&models.RefreshToken{ID: 5, UserID: 2, FamilyID: "fam"}, nil)
+
+	err := svc.Logout(ctx, 1, "jti-1", exp, "refresh")
+	assert.NoError(t, err)
+	refreshRepo.AssertNotCalled(t, "RevokeTokenFamily", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_LogoutAll(t *testing.T) {
+	refreshRepo := new(mockRefreshTokenRepo)
+	revocations := new(mockRevocationStore)
+	svc := services.NewAuthService(new(mockUserRepo), refreshRepo, newSessionRepoMock(), revocations, newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	refreshRepo.On("RevokeUserRefreshTokens", ctx, uint(1)).Return(nil)
+	revocations.On("RevokeAllForUser", ctx, uint(1)).Return(nil)
+
+	err := svc.LogoutAll(ctx, 1)
+	assert.NoError(t, err)
+	refreshRepo.AssertExpectations(t)
+	revocations.AssertExpectations(t)
+}
+
+func TestAuthService_RevokeOtherSessions(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	revocations := new(mockRevocationStore)
+	svc := services.NewAuthService(repo, refreshRepo, newSessionRepoMock(), revocations, newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := context.Background()
+
+	refreshRepo.On("RevokeUserRefreshTokens", ctx, uint(1)).Return(nil)
+	revocations.On("RevokeAllForUser", ctx, uint(1)).Return(nil)
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	tokens, err := svc.RevokeOtherSessions(ctx, 1)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	// Новый refresh-токен сохраняется после отзыва всех прежних
+	refreshRepo.AssertNumberOfCalls(t, "RevokeUserRefreshTokens", 1)
+	stored := refreshRepo.Calls[1].Arguments.Get(1).(*models.RefreshToken)
+	assert.Equal(t, utils.HashToken(tokens.RefreshToken), stored.TokenHash)
+}
+
+func TestAuthService_RevokeOtherSessions_KeepsCurrentSession(t *testing.T) {
+	repo := new(mockUserRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	revocations := new(mockRevocationStore)
+	sessionRepo := new(mockSessionRepo)
+	svc := services.NewAuthService(repo, refreshRepo, sessionRepo, revocations, newDisabledMFAService(), newPermissiveLoginThrottle(), services.NewAuthorizer(), services.AuthSettings{})
+	ctx := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, SessionID: 7})
+
+	current := &models.Session{ID: 7, UserID: 1, FamilyID: "fam", UserAgent: "Mozilla/5.0"}
+	sessionRepo.On("GetSessionByID", ctx, uint(7)).Return(current, nil)
+	sessionRepo.On("RevokeUserSessions", ctx, uint(1), uint(7)).Return(nil)
+	sessionRepo.On("UpdateSession", ctx, current).Return(nil)
+	refreshRepo.On("RevokeUserRefreshTokens", ctx, uint(1)).Return(nil)
+	refreshRepo.On("CreateRefreshToken", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+	revocations.On("RevokeAllForUser", ctx, uint(1)).Return(nil)
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+
+	tokens, err := svc.RevokeOtherSessions(ctx, 1)
+	require.NoError(t, err)
+	sessionRepo.AssertExpectations(t)
+	sessionRepo.AssertNotCalled(t, "CreateSession", mock.Anything, mock.Anything)
+	// Текущая сессия получает новое семейство refresh-токенов
+	stored := refreshRepo.Calls[1].Arguments.Get(1).(*models.RefreshToken)
+	assert.NotEqual(t, "fam", stored.FamilyID)
+	assert.Equal(t, stored.FamilyID, current.FamilyID)
+	claims, err := utils.ParseJWT(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, float64(7), claims["sid"])
+}
+
+func TestAuthService_UnlockAccount(t *testing.T) {
+	repo := new(mockUserRepo)
+	throttle := new(mockLoginThrottle)
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), throttle, services.NewAuthorizer(), services.AuthSettings{})
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Email: "a@b.com"}, nil)
+	throttle.On("Unlock", ctx, "a@b.com").Return(nil)
+
+	err := svc.UnlockAccount(ctx, 1)
+	assert.NoError(t, err)
+	throttle.AssertExpectations(t)
+}
+
+func TestAuthService_UnlockAccount_NotAdmin(t *testing.T) {
+	repo := new(mockUserRepo)
+	throttle := new(mockLoginThrottle)
+	svc := services.NewAuthService(repo, new(mockRefreshTokenRepo), newSessionRepoMock(), new(mockRevocationStore), newDisabledMFAService(), throttle, services.NewAuthorizer(), services.AuthSettings{})
+
+	err := svc.UnlockAccount(contextWithUser(1, models.RoleUser), 1)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	throttle.AssertNotCalled(t, "Unlock", mock.Anything, mock.Anything)
 }
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func contextWithUser(userID uint, role string) context.Context {
+	return services.ContextWithPrincipal(context.Background(), services.Principal{UserID: userID, Role: role})
+}
+
+func TestAuthorizer(t *testing.T) {
+	authz := services.NewAuthorizer()
+	user := contextWithUser(1, models.RoleUser)
+	admin := contextWithUser(9, models.RoleAdmin)
+	viaAPIKey := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, APIKeyID: 5})
+	client := services.ContextWithPrincipal(context.Background(), services.Principal{ClientID: "uoc_0123456789abcdef"})
+	adminViaAPIKey := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 9, Role: models.RoleAdmin, APIKeyID: 6})
+	impersonated := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, ActorID: 9})
+
+	tests := []struct {
+		name    string
+		check   func() error
+		allowed bool
+	}{
+		{name: "user manages self", check: func() error { return authz.CanManageUser(user, 1) }, allowed: true},
+		{name: "user manages other", check: func() error { return authz.CanManageUser(user, 2) }, allowed: false},
+		{name: "admin manages other", check: func() error { return authz.CanManageUser(admin, 2) }, allowed: true},
+		{name: "user views own orders", check: func() error { return authz.CanViewOrders(user, 1) }, allowed: true},
+		{name: "user views other orders", check: func() error { return authz.CanViewOrders(user, 2) }, allowed: false},
+		{name: "admin views other orders", check: func() error { return authz.CanViewOrders(admin, 2) }, allowed: true},
+		{name: "user creates own order", check: func() error { return authz.CanCreateOrder(user, 1) }, allowed: true},
+		{name: "admin creates order for other", check: func() error { return authz.CanCreateOrder(admin, 2) }, allowed: false},
+		{name: "user changes own password", check: func() error { return authz.CanChangePassword(user, 1) }, allowed: true},
+		{name: "admin changes other password", check: func() error { return authz.CanChangePassword(admin, 2) }, allowed: false},
+		{name: "user manages own mfa", check: func() error { return authz.CanManageMFA(user, 1) }, allowed: true},
+		{name: "admin manages other mfa", check: func() error { return authz.CanManageMFA(admin, 2) }, allowed: false},
+		{name: "admin unlocks account", check: func() error { return authz.CanUnlockAccount(admin, 2) }, allowed: true},
+		{name: "user unlocks own account", check: func() error { return authz.CanUnlockAccount(user, 1) }, allowed: false},
+		{name: "user creates own API key", check: func() error { return authz.CanCreateAPIKey(user, 1) }, allowed: true},
+		{name: "admin creates API key for other", check: func() error { return authz.CanCreateAPIKey(admin, 2) }, allowed: false},
+		{name: "admin revokes other API keys", check: func() error { return authz.CanManageAPIKeys(admin, 2) }, allowed: true},
+		{name: "user manages other API keys", check: func() error { return authz.CanManageAPIKeys(user, 2) }, allowed: false},
+		{name: "API key views own orders", check: func() error { return authz.CanViewOrders(viaAPIKey, 1) }, allowed: true},
+		{name: "API key manages own API keys", check: func() error { return authz.CanManageAPIKeys(viaAPIKey, 1) }, allowed: true},
+		{name: "API key creates API key", check: func() error { return authz.CanCreateAPIKey(viaAPIKey, 1) }, allowed: false},
+		{name: "API key changes password", check: func() error { return authz.CanChangePassword(viaAPIKey, 1) }, allowed: false},
+		{name: "API key manages mfa", check: func() error { return authz.CanManageMFA(viaAPIKey, 1) }, allowed: false},
+		{name: "client views any orders", check: func() error { return authz.CanViewOrders(client, 2) }, allowed: true},
+		{name: "client creates order for user", check: func() error { return authz.CanCreateOrder(client, 2) }, allowed: true},
+		{name: "client manages user", check: func() error { return authz.CanManageUser(client, 2) }, allowed: false},
+		{name: "client manages admin", check: func() error { return authz.CanManageUser(client, 9) }, allowed: false},
+		{name: "client manages API keys", check: func() error { return authz.CanManageAPIKeys(client, 2) }, allowed: false},
+		{name: "client changes password", check: func() error { return authz.CanChangePassword(client, 2) }, allowed: false},
+		{name: "client creates API key", check: func() error { return authz.CanCreateAPIKey(client, 2) }, allowed: false},
+		{name: "client unlocks account", check: func() error { return authz.CanUnlockAccount(client, 2) }, allowed: false},
+		{name: "admin manages OAuth clients", check: func() error { return authz.CanManageOAuthClients(admin) }, allowed: true},
+		{name: "user manages OAuth clients", check: func() error { return authz.CanManageOAuthClients(user) }, allowed: false},
+		{name: "admin API key manages OAuth clients", check: func() error { return authz.CanManageOAuthClients(adminViaAPIKey) }, allowed: false},
+		{name: "client manages OAuth clients", check: func() error { return authz.CanManageOAuthClients(client) }, allowed: false},
+		{name: "user manages own sessions", check: func() error { return authz.CanManageSessions(user, 1) }, allowed: true},
+		{name: "user manages other sessions", check: func() error { return authz.CanManageSessions(user, 2) }, allowed: false},
+		{name: "admin manages other sessions", check: func() error { return authz.CanManageSessions(admin, 2) }, allowed: true},
+		{name: "client manages sessions", check: func() error { return authz.CanManageSessions(client, 2) }, allowed: false},
+		{name: "admin impersonates user", check: func() error { return authz.CanImpersonate(admin, 2) }, allowed: true},
+		{name: "admin impersonates self", check: func() error { return authz.CanImpersonate(admin, 9) }, allowed: false},
+		{name: "user impersonates other", check: func() error { return authz.CanImpersonate(user, 2) }, allowed: false},
+		{name: "admin API key impersonates user", check: func() error { return authz.CanImpersonate(adminViaAPIKey, 2) }, allowed: false},
+		{name: "client impersonates user", check: func() error { return authz.CanImpersonate(client, 2) }, allowed: false},
+		{name: "impersonated views orders", check: func() error { return authz.CanViewOrders(impersonated, 1) }, allowed: true},
+		{name: "impersonated manages own sessions", check: func() error { return authz.CanManageSessions(impersonated, 1) }, allowed: true},
+		{name: "impersonated changes password", check: func() error { return authz.CanChangePassword(impersonated, 1) }, allowed: false},
+		{name: "impersonated manages mfa", check: func() error { return authz.CanManageMFA(impersonated, 1) }, allowed: false},
+		{name: "impersonated creates API key", check: func() error { return authz.CanCreateAPIKey(impersonated, 1) }, allowed: false},
+		{name: "admin restores user", check: func() error { return authz.CanRestoreUser(admin, 2) }, allowed: true},
+		{name: "user restores own account", check: func() error { return authz.CanRestoreUser(user, 1) }, allowed: false},
+		{name: "no identity", check: func() error { return authz.CanViewOrders(context.Background(), 1) }, allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check()
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, services.ErrForbidden)
+			}
+		})
+	}
+}
+
+func TestAuthorizer_ImpersonationRestricted(t *testing.T) {
+	authz := services.NewAuthorizer()
+	impersonated := services.ContextWithPrincipal(context.Background(), services.Principal{UserID: 1, Role: models.RoleUser, ActorID: 9})
+
+	err := authz.CanChangePassword(impersonated, 1)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	assert.ErrorIs(t, err, services.ErrImpersonationRestricted)
+}
@@ -0,0 +1,271 @@
+package test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/config"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Случайный секрет длиной 44 символа (32 байта в base64)
+const strongJWTSecret = "q3J8vZt1Lw9xY0pR6sKc2Hn4Fg7Dm5Ba8Ue1Ti0Oy3W="
+
+// Устанавливает переменные окружения конфигурации на время теста
+// Пустое значение означает, что переменная не задана
+func setConfigEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	keys := []string{"GIN_MODE", "JWT_ALGORITHM", "JWT_SECRET", "JWT_PRIVATE_KEY_FILE", "JWT_EXPIRATION", "REFRESH_TOKEN_EXPIRATION", "JWT_ISSUER", "JWT_AUDIENCE", "MAIL_DRIVER", "MAIL_FILE", "SMTP_HOST", "REQUIRE_VERIFIED_EMAIL", "MFA_TOKEN_TTL", "IMPERSONATION_TOKEN_TTL", "TOKEN_REVOCATION_SYNC_INTERVAL", "DELETED_USER_RETENTION_DAYS", "USER_PURGE_INTERVAL", "USER_DELETE_ORDER_POLICY", "LOGIN_MAX_FAILURES", "LOGIN_LOCKOUT", "LOGIN_MAX_LOCKOUT", "SIGNUP_PRIVACY_MODE", "PASSWORD_HASH_ALGORITHM", "BCRYPT_COST", "ARGON2_MEMORY", "ARGON2_PARALLELISM", "PASSWORD_MIN_LENGTH", "PASSWORD_MAX_BYTES", "PASSWORD_REQUIRE_CLASSES", "PASSWORD_BREACHED_LIST_FILE"}
+	for _, key := range keys {
+		t.Setenv(key, env[key])
+		if env[key] == "" {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestLoadConfig_DebugAllowsDefaults(t *testing.T) {
+	setConfigEnv(t, map[string]string{})
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, cfg.JWT.AccessTokenTTL)
+	assert.Equal(t, 720*time.Hour, cfg.JWT.RefreshTokenTTL)
+	assert.Zero(t, cfg.DeletedUserRetention())
+}
+
+func TestLoadConfig_ReleaseRejectsInsecureDefaults(t *testing.T) {
+	setConfigEnv(t, map[string]string{"GIN_MODE": "release"})
+
+	cfg, err := config.LoadConfig()
+	assert.Nil(t, cfg)
+	require.Error(t, err)
+	// Все ошибки сообщаются разом
+	assert.Contains(t, err.Error(), "JWT_SECRET is not set")
+	assert.Contains(t, err.Error(), "JWT_ISSUER is not set")
+	assert.Contains(t, err.Error(), "JWT_AUDIENCE is not set")
+	assert.Contains(t, err.Error(), "MAIL_DRIVER=console")
+}
+
+func TestLoadConfig_ReleaseSecure(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"GIN_MODE":     "release",
+		"JWT_SECRET":   strongJWTSecret,
+		"JWT_ISSUER":   "user-order-api",
+		"JWT_AUDIENCE": "user-order-api, billing",
+		"MAIL_DRIVER":  "smtp",
+		"SMTP_HOST":    "smtp.example.com",
+	})
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "user-order-api", cfg.JWT.Issuer)
+	assert.Equal(t, []string{"user-order-api", "billing"}, cfg.JWT.Audience)
+}
+
+func TestLoadConfig_WeakSecretInRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		expected string
+	}{
+		{name: "short", secret: "short-secret", expected: "at least 32 characters"},
+		{name: "low entropy", secret: "abababababababababababababababababab", expected: "too predictable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigEnv(t, map[string]string{
+				"GIN_MODE":     "release",
+				"JWT_SECRET":   tt.secret,
+				"JWT_ISSUER":   "user-order-api",
+				"JWT_AUDIENCE": "user-order-api",
+			})
+
+			_, err := config.LoadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestLoadConfig_ReportsAllErrors(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"JWT_ALGORITHM":                  "none",
+		"JWT_EXPIRATION":                 "soon",
+		"REFRESH_TOKEN_EXPIRATION":       "-1h",
+		"MFA_TOKEN_TTL":                  "0s",
+		"IMPERSONATION_TOKEN_TTL":        "-15m",
+		"TOKEN_REVOCATION_SYNC_INTERVAL": "often",
+		"DELETED_USER_RETENTION_DAYS":    "-1",
+		"USER_DELETE_ORDER_POLICY":       "orphan",
+	})
+
+	_, err := config.LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `JWT_ALGORITHM "none" is not supported`)
+	assert.Contains(t, err.Error(), "JWT_EXPIRATION has invalid duration")
+	assert.Contains(t, err.Error(), "REFRESH_TOKEN_EXPIRATION must be positive")
+	assert.Contains(t, err.Error(), "MFA_TOKEN_TTL must be positive")
+	assert.Contains(t, err.Error(), "IMPERSONATION_TOKEN_TTL must be positive")
+	assert.Contains(t, err.Error(), "TOKEN_REVOCATION_SYNC_INTERVAL has invalid duration")
+	assert.Contains(t, err.Error(), "DELETED_USER_RETENTION_DAYS must not be negative")
+	assert.Contains(t, err.Error(), `USER_DELETE_ORDER_POLICY "orphan" is not supported`)
+}
+
+// Без окончательного удаления пользователей политика заказов cascade или anonymize ни на что не влияет
+func TestLoadConfig_OrderPolicyRequiresRetention(t *testing.T) {
+	for _, policy := range []string{"cascade", "anonymize"} {
+		t.Run(policy, func(t *testing.T) {
+			setConfigEnv(t, map[string]string{"USER_DELETE_ORDER_POLICY": policy})
+
+			_, err := config.LoadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), `USER_DELETE_ORDER_POLICY "`+policy+`" requires DELETED_USER_RETENTION_DAYS to be positive`)
+
+			setConfigEnv(t, map[string]string{"USER_DELETE_ORDER_POLICY": policy, "DELETED_USER_RETENTION_DAYS": "30"})
+			cfg, err := config.LoadConfig()
+			require.NoError(t, err)
+			assert.Equal(t, policy, cfg.UserDeleteOrderPolicy)
+		})
+	}
+}
+
+func TestLoadConfig_ImpersonationTTLLongerThanAccessToken(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"JWT_EXPIRATION":          "15m",
+		"IMPERSONATION_TOKEN_TTL": "1h",
+	})
+
+	_, err := config.LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "IMPERSONATION_TOKEN_TTL must not be longer than JWT_EXPIRATION")
+}
+
+func TestLoadConfig_RevocationSyncIntervalMustBePositive(t *testing.T) {
+	setConfigEnv(t, map[string]string{"TOKEN_REVOCATION_SYNC_INTERVAL": "0s"})
+
+	_, err := config.LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TOKEN_REVOCATION_SYNC_INTERVAL must be positive")
+}
+
+func TestLoadConfig_AsymmetricRequiresKeyFile(t *testing.T) {
+	setConfigEnv(t, map[string]string{"JWT_ALGORITHM": "EdDSA"})
+
+	_, err := config.LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_PRIVATE_KEY_FILE is required")
+}
+
+func TestLoadConfig_MailDriver(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{name: "unknown driver", env: map[string]string{"MAIL_DRIVER": "pigeon"}, expected: `MAIL_DRIVER "pigeon" is not supported`},
+		{name: "file without path", env: map[string]string{"MAIL_DRIVER": "file"}, expected: "MAIL_FILE is required"},
+		{name: "smtp without host", env: map[string]string{"MAIL_DRIVER": "smtp"}, expected: "SMTP_HOST is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigEnv(t, tt.env)
+
+			_, err := config.LoadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestLoadConfig_RequireVerifiedEmail(t *testing.T) {
+	setConfigEnv(t, map[string]string{"REQUIRE_VERIFIED_EMAIL": "login, orders"})
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.RequiresVerifiedEmail(config.VerifiedEmailForLogin))
+	assert.True(t, cfg.RequiresVerifiedEmail(config.VerifiedEmailForOrders))
+
+	setConfigEnv(t, map[string]string{"REQUIRE_VERIFIED_EMAIL": "checkout"})
+	_, err = config.LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `REQUIRE_VERIFIED_EMAIL contains unsupported action "checkout"`)
+}
+
+func TestLoadConfig_LoginProtection(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"LOGIN_MAX_FAILURES":  "many",
+		"LOGIN_LOCKOUT":       "10m",
+		"LOGIN_MAX_LOCKOUT":   "5m",
+		"SIGNUP_PRIVACY_MODE": "maybe",
+	})
+
+	_, err := config.LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `LOGIN_MAX_FAILURES has invalid number "many"`)
+	assert.Contains(t, err.Error(), "LOGIN_MAX_LOCKOUT must not be shorter than LOGIN_LOCKOUT")
+	assert.Contains(t, err.Error(), `SIGNUP_PRIVACY_MODE has invalid boolean "maybe"`)
+}
+
+func TestLoadConfig_PasswordHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{name: "unknown algorithm", env: map[string]string{"PASSWORD_HASH_ALGORITHM": "md5"}, expected: `PASSWORD_HASH_ALGORITHM "md5" is not supported`},
+		{name: "bcrypt cost out of range", env: map[string]string{"BCRYPT_COST": "40"}, expected: "BCRYPT_COST must be between 4 and 31"},
+		{name: "argon2 parallelism overflow", env: map[string]string{"PASSWORD_HASH_ALGORITHM": "argon2id", "ARGON2_PARALLELISM": "300"}, expected: "ARGON2_PARALLELISM must be between 1 and 255"},
+		{name: "argon2 memory too small", env: map[string]string{"PASSWORD_HASH_ALGORITHM": "argon2id", "ARGON2_MEMORY": "8", "ARGON2_PARALLELISM": "2"}, expected: "ARGON2_MEMORY must be at least 8 KiB per ARGON2_PARALLELISM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigEnv(t, tt.env)
+
+			_, err := config.LoadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+
+	setConfigEnv(t, map[string]string{"PASSWORD_HASH_ALGORITHM": "argon2id"})
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, utils.PasswordHashConfig{Algorithm: "argon2id", BcryptCost: 10, Argon2Memory: 65536, Argon2Iterations: 3, Argon2Parallelism: 2}, cfg.PasswordHash)
+}
+
+func TestLoadConfig_PasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{name: "zero min length", env: map[string]string{"PASSWORD_MIN_LENGTH": "0"}, expected: "PASSWORD_MIN_LENGTH must be positive"},
+		{name: "max below min", env: map[string]string{"PASSWORD_MIN_LENGTH": "12", "PASSWORD_MAX_BYTES": "10"}, expected: "PASSWORD_MAX_BYTES must not be less than PASSWORD_MIN_LENGTH"},
+		{name: "max above bcrypt limit", env: map[string]string{"PASSWORD_MAX_BYTES": "128"}, expected: "PASSWORD_MAX_BYTES must not exceed 72 with PASSWORD_HASH_ALGORITHM=bcrypt"},
+		{name: "unknown class", env: map[string]string{"PASSWORD_REQUIRE_CLASSES": "upper,emoji"}, expected: `PASSWORD_REQUIRE_CLASSES contains unsupported class "emoji"`},
+		{name: "missing breached list", env: map[string]string{"PASSWORD_BREACHED_LIST_FILE": "/nonexistent/breached.txt"}, expected: "PASSWORD_BREACHED_LIST_FILE is not readable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigEnv(t, tt.env)
+
+			_, err := config.LoadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+
+	// С Argon2id длинные пароли допустимы
+	setConfigEnv(t, map[string]string{"PASSWORD_HASH_ALGORITHM": "argon2id", "PASSWORD_MAX_BYTES": "128", "PASSWORD_REQUIRE_CLASSES": "upper,digit"})
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 8, cfg.PasswordMinLength)
+	assert.Equal(t, 128, cfg.PasswordMaxBytes)
+	assert.True(t, cfg.RequiresPasswordClass(config.PasswordClassUpper))
+	assert.False(t, cfg.RequiresPasswordClass(config.PasswordClassSymbol))
+	assert.True(t, cfg.PasswordRejectPersonalInfo)
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEmailVerificationHandler_VerifyEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		query        string
+		mockSetup    func(m *mockEmailVerificationService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name:  "success",
+			query: "?token=verify-token",
+			mockSetup: func(m *mockEmailVerificationService) {
+				m.On("VerifyEmail", mock.Anything, "verify-token").Return(nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"message": "Email verified"},
+		},
+		{
+			name:  "invalid token",
+			query: "?token=verify-token",
+			mockSetup: func(m *mockEmailVerificationService) {
+				m.On("VerifyEmail", mock.Anything, "verify-token").Return(services.ErrInvalidVerificationToken)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{"error": "Invalid or expired email verification token"},
+		},
+		{
+			name:         "missing token",
+			query:        "",
+			mockSetup:    func(m *mockEmailVerificationService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:  "service error",
+			query: "?token=verify-token",
+			mockSetup: func(m *mockEmailVerificationService) {
+				m.On("VerifyEmail", mock.Anything, "verify-token").Return(errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockEmailVerificationService)
+			tt.mockSetup(svc)
+			h := handlers.NewEmailVerificationHandler(svc)
+			router := gin.New()
+			router.GET("/auth/verify-email", h.VerifyEmail)
+
+			req, _ := http.NewRequest(http.MethodGet, "/auth/verify-email"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != nil {
+				var resp map[string]interface{}
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.Equal(t, tt.expectedBody, resp)
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEmailVerificationHandler_ResendVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(m *mockEmailVerificationService)
+		expectedCode int
+	}{
+		{
+			name: "success",
+			body: `{"email":"a@b.com"}`,
+			mockSetup: func(m *mockEmailVerificationService) {
+				m.On("ResendVerification", mock.Anything, "a@b.com").Return(nil)
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:         "invalid email",
+			body:         `{"email":"not-an-email"}`,
+			mockSetup:    func(m *mockEmailVerificationService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "service error",
+			body: `{"email":"a@b.com"}`,
+			mockSetup: func(m *mockEmailVerificationService) {
+				m.On("ResendVerification", mock.Anything, "a@b.com").Return(errors.New("smtp down"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockEmailVerificationService)
+			tt.mockSetup(svc)
+			h := handlers.NewEmailVerificationHandler(svc)
+			router := gin.New()
+			router.POST("/auth/verify-email/resend", h.ResendVerification)
+
+			req, _ := http.NewRequest(http.MethodPost, "/auth/verify-email/resend", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			svc.AssertExpectations(t)
+		})
+	}
+}
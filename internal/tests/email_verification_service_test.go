@@ -0,0 +1,150 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/mail"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockEmailVerificationService struct {
+	mock.Mock
+}
+
+func (m *mockEmailVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+func (m *mockEmailVerificationService) ResendVerification(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+func (m *mockEmailVerificationService) VerifyEmail(ctx context.Context, token string) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+var testVerificationSettings = services.EmailVerificationSettings{TokenTTL: 24 * time.Hour, VerifyURL: "https://api.example.com/auth/verify-email"}
+
+func TestEmailVerificationService_SendVerification(t *testing.T) {
+	actionRepo := new(mockActionTokenRepo)
+	mailer := new(mockMailer)
+	svc := services.NewEmailVerificationService(new(mockUserRepo), actionRepo, mailer, testVerificationSettings)
+	ctx := context.Background()
+
+	actionRepo.On("InvalidateUserActionTokens", ctx, uint(1), models.ActionEmailVerification).Return(nil)
+	actionRepo.On("CreateActionToken", ctx, mock.AnythingOfType("*models.ActionToken")).Return(nil)
+	mailer.On("Send", ctx, mock.AnythingOfType("mail.Message")).Return(nil)
+
+	err := svc.SendVerification(ctx, &models.User{ID: 1, Name: "Test", Email: "a@b.com"})
+	require.NoError(t, err)
+
+	stored := actionRepo.Calls[1].Arguments.Get(1).(*models.ActionToken)
+	msg := mailer.Calls[0].Arguments.Get(1).(mail.Message)
+	assert.Equal(t, "a@b.com", msg.To)
+	link := regexp.MustCompile(`https://api\.example\.com/auth/verify-email\?token=\S+`).FindString(msg.Body)
+	require.NotEmpty(t, link)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, utils.HashToken(parsed.Query().Get("token")), stored.TokenHash)
+	assert.Equal(t, models.ActionEmailVerification, stored.Purpose)
+	assert.WithinDuration(t, time.Now().UTC().Add(24*time.Hour), stored.ExpiresAt, time.Minute)
+}
+
+func TestEmailVerificationService_ResendVerification_Skipped(t *testing.T) {
+	verifiedAt := time.Now()
+	tests := []struct {
+		name string
+		user *models.User
+	}{
+		{name: "unknown email", user: nil},
+		{name: "already verified", user: &models.User{ID: 1, Email: "a@b.com", EmailVerifiedAt: &verifiedAt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(mockUserRepo)
+			mailer := new(mockMailer)
+			svc := services.NewEmailVerificationService(userRepo, new(mockActionTokenRepo), mailer, testVerificationSettings)
+			ctx := context.Background()
+
+			userRepo.On("GetUserByEmail", ctx, "a@b.com").Return(tt.user, nil)
+
+			err := svc.ResendVerification(ctx, "a@b.com")
+			assert.NoError(t, err)
+			mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestEmailVerificationService_ResendVerification_RepoError(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	svc := services.NewEmailVerificationService(userRepo, new(mockActionTokenRepo), new(mockMailer), testVerificationSettings)
+	ctx := context.Background()
+
+	userRepo.On("GetUserByEmail", ctx, "a@b.com").Return(nil, errors.New("db error"))
+
+	err := svc.ResendVerification(ctx, "a@b.com")
+	assert.Error(t, err)
+}
+
+func TestEmailVerificationService_VerifyEmail(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	actionRepo := new(mockActionTokenRepo)
+	svc := services.NewEmailVerificationService(userRepo, actionRepo, new(mockMailer), testVerificationSettings)
+	ctx := context.Background()
+
+	stored := &models.ActionToken{ID: 4, UserID: 1, Purpose: models.ActionEmailVerification, ExpiresAt: time.Now().Add(time.Hour)}
+	actionRepo.On("GetActionTokenByHash", ctx, models.ActionEmailVerification, utils.HashToken("verify-token")).Return(stored, nil)
+	actionRepo.On("ConsumeActionToken", ctx, uint(4)).Return(nil)
+	userRepo.On("MarkEmailVerified", ctx, uint(1)).Return(nil)
+
+	err := svc.VerifyEmail(ctx, "verify-token")
+	assert.NoError(t, err)
+	userRepo.AssertExpectations(t)
+}
+
+func TestEmailVerificationService_VerifyEmail_InvalidToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    *models.ActionToken
+		consumeFn func(m *mockActionTokenRepo)
+	}{
+		{name: "unknown", stored: nil},
+		{name: "expired", stored: &models.ActionToken{ID: 4, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}},
+		{
+			name:   "already used",
+			stored: &models.ActionToken{ID: 4, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)},
+			consumeFn: func(m *mockActionTokenRepo) {
+				m.On("ConsumeActionToken", mock.Anything, uint(4)).Return(gorm.ErrRecordNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(mockUserRepo)
+			actionRepo := new(mockActionTokenRepo)
+			svc := services.NewEmailVerificationService(userRepo, actionRepo, new(mockMailer), testVerificationSettings)
+			ctx := context.Background()
+
+			actionRepo.On("GetActionTokenByHash", ctx, models.ActionEmailVerification, utils.HashToken("verify-token")).Return(tt.stored, nil)
+			if tt.consumeFn != nil {
+				tt.consumeFn(actionRepo)
+			}
+
+			err := svc.VerifyEmail(ctx, "verify-token")
+			assert.ErrorIs(t, err, services.ErrInvalidVerificationToken)
+			userRepo.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockImpersonationRepo struct {
+	mock.Mock
+}
+
+func (m *mockImpersonationRepo) CreateImpersonation(ctx context.Context, impersonation *models.Impersonation) error {
+	args := m.Called(ctx, impersonation)
+	return args.Error(0)
+}
+
+func newImpersonationService(userRepo *mockUserRepo, impersonationRepo *mockImpersonationRepo) services.ImpersonationService {
+	return services.NewImpersonationService(userRepo, impersonationRepo, services.NewAuthorizer(), services.ImpersonationSettings{TokenTTL: 15 * time.Minute})
+}
+
+func TestImpersonationService_Impersonate(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	impersonationRepo := new(mockImpersonationRepo)
+	svc := newImpersonationService(userRepo, impersonationRepo)
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	userRepo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2, Role: models.RoleUser}, nil)
+	var record *models.Impersonation
+	impersonationRepo.On("CreateImpersonation", ctx, mock.AnythingOfType("*models.Impersonation")).Run(func(args mock.Arguments) {
+		record = args.Get(1).(*models.Impersonation)
+	}).Return(nil)
+
+	token, err := svc.Impersonate(ctx, 2, "support ticket #42", services.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(900), token.ExpiresIn)
+
+	claims, err := utils.ParseJWT(token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), claims["user_id"])
+	assert.Equal(t, models.RoleUser, claims["role"])
+	actorID, ok := utils.ClaimActorID(claims)
+	assert.True(t, ok)
+	assert.Equal(t, uint(9), actorID)
+
+	require.NotNil(t, record)
+	assert.Equal(t, uint(9), record.AdminID)
+	assert.Equal(t, uint(2), record.UserID)
+	assert.Equal(t, "support ticket #42", record.Reason)
+	assert.Equal(t, claims["jti"], record.TokenID)
+	assert.Equal(t, "10.0.0.1", record.IPAddress)
+}
+
+func TestImpersonationService_Impersonate_AdminTarget(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	impersonationRepo := new(mockImpersonationRepo)
+	svc := newImpersonationService(userRepo, impersonationRepo)
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	userRepo.On("GetUserByID", ctx, uint(3)).Return(&models.User{ID: 3, Role: models.RoleAdmin}, nil)
+
+	_, err := svc.Impersonate(ctx, 3, "check settings", services.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrImpersonationNotAllowed)
+	impersonationRepo.AssertNotCalled(t, "CreateImpersonation", mock.Anything, mock.Anything)
+}
+
+func TestImpersonationService_Impersonate_Forbidden(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	impersonationRepo := new(mockImpersonationRepo)
+	svc := newImpersonationService(userRepo, impersonationRepo)
+
+	_, err := svc.Impersonate(contextWithUser(1, models.RoleUser), 2, "curiosity", services.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	userRepo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+}
+
+func TestImpersonationService_Impersonate_AuditFailure(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	impersonationRepo := new(mockImpersonationRepo)
+	svc := newImpersonationService(userRepo, impersonationRepo)
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	userRepo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2, Role: models.RoleUser}, nil)
+	impersonationRepo.On("CreateImpersonation", ctx, mock.Anything).Return(errors.New("db error"))
+
+	token, err := svc.Impersonate(ctx, 2, "support ticket #42", services.ClientInfo{})
+	assert.Error(t, err)
+	assert.Nil(t, token)
+}
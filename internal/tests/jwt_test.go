@@ -0,0 +1,345 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Записывает закрытый ключ в PEM-файл (PKCS#8) во временный каталог теста
+func writePrivateKeyPEM(t *testing.T, name string, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return path
+}
+
+// Записывает публичный ключ в PEM-файл (PKIX) во временный каталог теста
+func writePublicKeyPEM(t *testing.T, name string, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644))
+	return path
+}
+
+// Настраивает ключи JWT на время теста и возвращает настройки по умолчанию после него
+func configureJWTForTest(t *testing.T, cfg utils.JWTConfig) {
+	t.Helper()
+	require.NoError(t, utils.ConfigureJWT(cfg))
+	t.Cleanup(func() { _ = utils.ConfigureJWT(utils.JWTConfig{}) })
+}
+
+func tokenHeader(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	require.NoError(t, err)
+	return parsed.Header
+}
+
+func TestJWT_AsymmetricAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		algorithm string
+		key       interface{}
+	}{
+		{algorithm: utils.JWTAlgRS256, key: rsaKey},
+		{algorithm: utils.JWTAlgES256, key: ecKey},
+		{algorithm: utils.JWTAlgEdDSA, key: edKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			configureJWTForTest(t, utils.JWTConfig{
+				Algorithm:      tt.algorithm,
+				PrivateKeyFile: writePrivateKeyPEM(t, "signing.pem", tt.key),
+			})
+
+			token, err := utils.GenerateJWT(1, models.RoleUser, nil)
+			require.NoError(t, err)
+			header := tokenHeader(t, token)
+			assert.Equal(t, tt.algorithm, header["alg"])
+			assert.NotEmpty(t, header["kid"])
+
+			claims, err := utils.ParseJWT(token)
+			require.NoError(t, err)
+			assert.Equal(t, float64(1), claims["user_id"])
+
+			jwks := utils.PublicJWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, header["kid"], jwks.Keys[0].Kid)
+			assert.Equal(t, tt.algorithm, jwks.Keys[0].Alg)
+		})
+	}
+}
+
+func TestJWT_ExplicitKeyID(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm:      utils.JWTAlgEdDSA,
+		KeyID:          "2026-10",
+		PrivateKeyFile: writePrivateKeyPEM(t, "signing.pem", edKey),
+	})
+
+	token, err := utils.GenerateJWT(1, models.RoleUser, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10", tokenHeader(t, token)["kid"])
+}
+
+func TestJWT_TokenWithoutKeyIDVerifiedWithSigningKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm:      utils.JWTAlgEdDSA,
+		KeyID:          "2026-10",
+		PrivateKeyFile: writePrivateKeyPEM(t, "signing.pem", edKey),
+	})
+
+	// Токен подписан тем же ключом, но без заголовка kid
+	legacy := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Minute).Unix(), "iat": time.Now().Unix()})
+	legacyString, err := legacy.SignedString(edKey)
+	require.NoError(t, err)
+	_, err = utils.ParseJWT(legacyString)
+	assert.NoError(t, err)
+
+	// Чужой ключ без kid не принимается
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	forgedString, err := legacy.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = utils.ParseJWT(forgedString)
+	assert.Error(t, err)
+}
+
+func TestJWT_KeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	// Токен выпущен старым ключом
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm:      utils.JWTAlgRS256,
+		KeyID:          "old",
+		PrivateKeyFile: writePrivateKeyPEM(t, "old.pem", oldKey),
+	})
+	oldToken, err := utils.GenerateJWT(1, models.RoleUser, nil)
+	require.NoError(t, err)
+
+	// Подпись переключена на новый ключ, старый оставлен только для проверки
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm:            utils.JWTAlgEdDSA,
+		KeyID:                "new",
+		PrivateKeyFile:       writePrivateKeyPEM(t, "new.pem", newKey),
+		VerificationKeyFiles: []string{"old=" + writePublicKeyPEM(t, "old.pub.pem", &oldKey.PublicKey)},
+	})
+	newToken, err := utils.GenerateJWT(1, models.RoleUser, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "new", tokenHeader(t, newToken)["kid"])
+
+	_, err = utils.ParseJWT(oldToken)
+	assert.NoError(t, err)
+	_, err = utils.ParseJWT(newToken)
+	assert.NoError(t, err)
+
+	kids := []string{}
+	for _, key := range utils.PublicJWKS().Keys {
+		kids = append(kids, key.Kid)
+		assert.Empty(t, key.Y)
+	}
+	assert.ElementsMatch(t, []string{"old", "new"}, kids)
+
+	// После вывода старого ключа из набора его токены больше не принимаются
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm:      utils.JWTAlgEdDSA,
+		KeyID:          "new",
+		PrivateKeyFile: writePrivateKeyPEM(t, "new.pem", newKey),
+	})
+	_, err = utils.ParseJWT(oldToken)
+	assert.Error(t, err)
+}
+
+func TestJWT_RejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm:      utils.JWTAlgRS256,
+		KeyID:          "rsa",
+		PrivateKeyFile: writePrivateKeyPEM(t, "signing.pem", rsaKey),
+	})
+
+	// Токен с тем же kid, но подписанный HS256 публичным ключом как секретом
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 1})
+	forged.Header["kid"] = "rsa"
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	forgedString, err := forged.SignedString(der)
+	require.NoError(t, err)
+
+	_, err = utils.ParseJWT(forgedString)
+	assert.Error(t, err)
+}
+
+func TestJWT_HS256PublishesNoKeys(t *testing.T) {
+	configureJWTForTest(t, utils.JWTConfig{Algorithm: utils.JWTAlgHS256, Secret: "test-secret"})
+	assert.Empty(t, utils.PublicJWKS().Keys)
+}
+
+func TestConfigureJWT_InvalidConfig(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cfg  utils.JWTConfig
+	}{
+		{name: "unknown algorithm", cfg: utils.JWTConfig{Algorithm: "none"}},
+		{name: "missing private key", cfg: utils.JWTConfig{Algorithm: utils.JWTAlgRS256}},
+		{name: "missing key file", cfg: utils.JWTConfig{Algorithm: utils.JWTAlgRS256, PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{name: "key type mismatch", cfg: utils.JWTConfig{Algorithm: utils.JWTAlgRS256, PrivateKeyFile: writePrivateKeyPEM(t, "ec.pem", ecKey)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, utils.ConfigureJWT(tt.cfg))
+		})
+	}
+}
+
+// Подписывает произвольные claims секретом тестовой конфигурации
+func signTestJWT(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(testJWTSecret))
+	require.NoError(t, err)
+	return token
+}
+
+const testJWTSecret = "test-secret"
+
+// Настройки с издателем, аудиторией и допуском в одну минуту
+func configureStrictJWTForTest(t *testing.T) {
+	configureJWTForTest(t, utils.JWTConfig{
+		Algorithm: utils.JWTAlgHS256,
+		Secret:    testJWTSecret,
+		Issuer:    "user-order-api",
+		Audience:  []string{"user-order-api", "billing"},
+		Leeway:    time.Minute,
+	})
+}
+
+// Claims корректного токена, которые тесты точечно портят
+func validTestClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":     "1",
+		"user_id": 1,
+		"iss":     "user-order-api",
+		"aud":     []string{"billing"},
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"exp":     now.Add(time.Minute).Unix(),
+	}
+}
+
+func TestGenerateJWT_StandardClaims(t *testing.T) {
+	configureStrictJWTForTest(t)
+
+	token, err := utils.GenerateJWT(7, models.RoleUser, nil)
+	require.NoError(t, err)
+	claims, err := utils.ParseJWT(token)
+	require.NoError(t, err)
+	assert.Equal(t, "7", claims["sub"])
+	assert.Equal(t, "user-order-api", claims["iss"])
+	assert.ElementsMatch(t, []interface{}{"user-order-api", "billing"}, claims["aud"])
+	assert.Equal(t, claims["iat"], claims["nbf"])
+}
+
+func TestGenerateImpersonationJWT_ActClaim(t *testing.T) {
+	token, _, err := utils.GenerateImpersonationJWT(7, models.RoleUser, nil, 9, 2*time.Minute)
+	require.NoError(t, err)
+	claims, err := utils.ParseJWT(token)
+	require.NoError(t, err)
+	assert.Equal(t, "7", claims["sub"])
+	assert.Equal(t, map[string]interface{}{"sub": "9", "user_id": float64(9)}, claims["act"])
+	actorID, ok := utils.ClaimActorID(claims)
+	assert.True(t, ok)
+	assert.Equal(t, uint(9), actorID)
+	issuedAt, _ := utils.ClaimTime(claims, "iat")
+	expiresAt, _ := utils.ClaimTime(claims, "exp")
+	assert.WithinDuration(t, issuedAt.Add(2*time.Minute), expiresAt, time.Second)
+}
+
+func TestParseJWT_Validation(t *testing.T) {
+	configureStrictJWTForTest(t)
+
+	tests := []struct {
+		name     string
+		method   jwt.SigningMethod
+		modify   func(claims jwt.MapClaims)
+		expected error
+	}{
+		{name: "valid", modify: func(claims jwt.MapClaims) {}},
+		{name: "wrong issuer", modify: func(claims jwt.MapClaims) { claims["iss"] = "other" }, expected: utils.ErrJWTInvalidIssuer},
+		{name: "missing issuer", modify: func(claims jwt.MapClaims) { delete(claims, "iss") }, expected: utils.ErrJWTInvalidClaims},
+		{name: "wrong audience", modify: func(claims jwt.MapClaims) { claims["aud"] = "other" }, expected: utils.ErrJWTInvalidAudience},
+		{name: "missing audience", modify: func(claims jwt.MapClaims) { delete(claims, "aud") }, expected: utils.ErrJWTInvalidAudience},
+		{name: "expired", modify: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-2 * time.Minute).Unix() }, expected: utils.ErrJWTExpired},
+		{name: "expired within leeway", modify: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-30 * time.Second).Unix() }},
+		{name: "missing expiration", modify: func(claims jwt.MapClaims) { delete(claims, "exp") }, expected: utils.ErrJWTInvalidClaims},
+		{name: "not valid yet", modify: func(claims jwt.MapClaims) { claims["nbf"] = time.Now().Add(2 * time.Minute).Unix() }, expected: utils.ErrJWTNotValidYet},
+		{name: "nbf within leeway", modify: func(claims jwt.MapClaims) { claims["nbf"] = time.Now().Add(30 * time.Second).Unix() }},
+		{name: "issued in the future", modify: func(claims jwt.MapClaims) { claims["iat"] = time.Now().Add(2 * time.Minute).Unix() }, expected: utils.ErrJWTNotValidYet},
+		{name: "algorithm not allowed", method: jwt.SigningMethodHS384, modify: func(claims jwt.MapClaims) {}, expected: utils.ErrJWTSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == nil {
+				method = jwt.SigningMethodHS256
+			}
+			claims := validTestClaims()
+			tt.modify(claims)
+
+			_, err := utils.ParseJWT(signTestJWT(t, method, claims))
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseJWT_Malformed(t *testing.T) {
+	_, err := utils.ParseJWT("not-a-token")
+	assert.ErrorIs(t, err, utils.ErrJWTMalformed)
+}
+
+func TestConfigureJWT_AllowedAlgorithms(t *testing.T) {
+	err := utils.ConfigureJWT(utils.JWTConfig{Algorithm: utils.JWTAlgHS256, AllowedAlgorithms: []string{utils.JWTAlgRS256}})
+	assert.Error(t, err)
+	err = utils.ConfigureJWT(utils.JWTConfig{Algorithm: utils.JWTAlgHS256, AllowedAlgorithms: []string{"none"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name     string
+		sort     string
+		expected []models.SortField
+		ok       bool
+	}{
+		{name: "empty", sort: "", expected: nil, ok: true},
+		{name: "asc and desc", sort: "name,-age", expected: []models.SortField{{Field: "name"}, {Field: "age", Desc: true}}, ok: true},
+		{name: "spaces", sort: " email , -id ", expected: []models.SortField{{Field: "email"}, {Field: "id", Desc: true}}, ok: true},
+		{name: "unknown field", sort: "name,password_hash", ok: false},
+		{name: "duplicate field", sort: "age,-age", ok: false},
+		{name: "empty item", sort: "name,", ok: false},
+		{name: "bare minus", sort: "-", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, ok := models.ParseSort(tt.sort, models.UserSortFields)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, fields)
+		})
+	}
+}
+
+func TestListQuery_Offset(t *testing.T) {
+	assert.Equal(t, 0, models.ListQuery{Page: 1, Limit: 10}.Offset())
+	assert.Equal(t, 20, models.ListQuery{Page: 3, Limit: 10}.Offset())
+}
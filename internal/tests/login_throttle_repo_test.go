@@ -0,0 +1,56 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottleRepository_RecordLoginFailure(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewLoginThrottleRepository(db)
+	now := time.Now().UTC()
+	windowStart := now.Add(-15 * time.Minute)
+	mock.ExpectQuery(`INSERT INTO login_throttles .* ON CONFLICT \(scope, subject\) DO UPDATE .* RETURNING`).
+		WithArgs(models.LoginThrottleAccount, "a@b.com", now, windowStart).
+		WillReturnRows(sqlmock.NewRows([]string{"scope", "subject", "failures", "last_failure_at", "locked_until"}).
+			AddRow(models.LoginThrottleAccount, "a@b.com", 4, now, nil))
+	throttle, err := repo.RecordLoginFailure(context.Background(), models.LoginThrottleAccount, "a@b.com", now, windowStart)
+	require.NoError(t, err)
+	assert.Equal(t, 4, throttle.Failures)
+	assert.Nil(t, throttle.LockedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginThrottleRepository_GetLoginThrottle_NotFound(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewLoginThrottleRepository(db)
+	mock.ExpectQuery(`SELECT \* FROM "login_throttles" WHERE scope = \$1 AND subject = \$2`).
+		WithArgs(models.LoginThrottleIP, "10.0.0.1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"scope", "subject", "failures"}))
+	throttle, err := repo.GetLoginThrottle(context.Background(), models.LoginThrottleIP, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, throttle)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginThrottleRepository_DeleteLoginThrottle(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewLoginThrottleRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "login_throttles" WHERE scope = \$1 AND subject = \$2`).
+		WithArgs(models.LoginThrottleAccount, "a@b.com").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.DeleteLoginThrottle(context.Background(), models.LoginThrottleAccount, "a@b.com")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
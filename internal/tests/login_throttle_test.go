@@ -0,0 +1,163 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLoginThrottleRepo struct {
+	mock.Mock
+}
+
+func (m *mockLoginThrottleRepo) GetLoginThrottle(ctx context.Context, scope, subject string) (*models.LoginThrottle, error) {
+	args := m.Called(ctx, scope, subject)
+	throttle, _ := args.Get(0).(*models.LoginThrottle)
+	return throttle, args.Error(1)
+}
+func (m *mockLoginThrottleRepo) RecordLoginFailure(ctx context.Context, scope, subject string, now, windowStart time.Time) (*models.LoginThrottle, error) {
+	args := m.Called(ctx, scope, subject, now, windowStart)
+	throttle, _ := args.Get(0).(*models.LoginThrottle)
+	return throttle, args.Error(1)
+}
+func (m *mockLoginThrottleRepo) LockLogin(ctx context.Context, scope, subject string, until time.Time) error {
+	args := m.Called(ctx, scope, subject, until)
+	return args.Error(0)
+}
+func (m *mockLoginThrottleRepo) DeleteLoginThrottle(ctx context.Context, scope, subject string) error {
+	args := m.Called(ctx, scope, subject)
+	return args.Error(0)
+}
+
+type mockLoginThrottle struct {
+	mock.Mock
+}
+
+func (m *mockLoginThrottle) Check(ctx context.Context, email, ip string) error {
+	args := m.Called(ctx, email, ip)
+	return args.Error(0)
+}
+func (m *mockLoginThrottle) RecordFailure(ctx context.Context, email, ip string) error {
+	args := m.Called(ctx, email, ip)
+	return args.Error(0)
+}
+func (m *mockLoginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+func (m *mockLoginThrottle) Unlock(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+// Защита от перебора, которая ничего не блокирует
+func newPermissiveLoginThrottle() *mockLoginThrottle {
+	m := new(mockLoginThrottle)
+	m.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	m.On("RecordFailure", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	m.On("RecordSuccess", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return m
+}
+
+var testLoginThrottleSettings = services.LoginThrottleSettings{
+	AccountMaxFailures: 3,
+	IPMaxFailures:      10,
+	Lockout:            time.Minute,
+	MaxLockout:         5 * time.Minute,
+	FailureWindow:      15 * time.Minute,
+}
+
+func TestLoginThrottle_Check(t *testing.T) {
+	future := time.Now().Add(90 * time.Second)
+	past := time.Now().Add(-time.Second)
+	tests := []struct {
+		name     string
+		ip       *models.LoginThrottle
+		account  *models.LoginThrottle
+		expected error
+	}{
+		{name: "no failures"},
+		{name: "failures below threshold", account: &models.LoginThrottle{Failures: 2}},
+		{name: "lock expired", account: &models.LoginThrottle{Failures: 3, LockedUntil: &past}},
+		{name: "account locked", account: &models.LoginThrottle{Failures: 3, LockedUntil: &future}, expected: services.ErrAccountLocked},
+		{name: "ip locked", ip: &models.LoginThrottle{Failures: 10, LockedUntil: &future}, expected: services.ErrTooManyLoginAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockLoginThrottleRepo)
+			throttle := services.NewLoginThrottle(repo, testLoginThrottleSettings)
+			ctx := context.Background()
+			repo.On("GetLoginThrottle", ctx, models.LoginThrottleIP, "10.0.0.1").Return(tt.ip, nil)
+			repo.On("GetLoginThrottle", ctx, models.LoginThrottleAccount, "a@b.com").Return(tt.account, nil).Maybe()
+
+			err := throttle.Check(ctx, " A@B.com", "10.0.0.1")
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.expected)
+			var locked *services.LoginLockedError
+			require.True(t, errors.As(err, &locked))
+			assert.InDelta(t, 90, locked.RetryAfter.Seconds(), 2)
+		})
+	}
+}
+
+func TestLoginThrottle_RecordFailure_Backoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		lockout  time.Duration
+	}{
+		{name: "below threshold", failures: 2},
+		{name: "threshold reached", failures: 3, lockout: time.Minute},
+		{name: "one more failure doubles", failures: 4, lockout: 2 * time.Minute},
+		{name: "two more failures", failures: 5, lockout: 4 * time.Minute},
+		{name: "capped", failures: 40, lockout: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockLoginThrottleRepo)
+			throttle := services.NewLoginThrottle(repo, testLoginThrottleSettings)
+			ctx := context.Background()
+			repo.On("RecordLoginFailure", ctx, models.LoginThrottleAccount, "a@b.com", mock.Anything, mock.Anything).
+				Return(&models.LoginThrottle{Failures: tt.failures}, nil)
+			repo.On("RecordLoginFailure", ctx, models.LoginThrottleIP, "10.0.0.1", mock.Anything, mock.Anything).
+				Return(&models.LoginThrottle{Failures: 1}, nil)
+			repo.On("LockLogin", ctx, models.LoginThrottleAccount, "a@b.com", mock.Anything).Return(nil)
+
+			err := throttle.RecordFailure(ctx, "a@b.com", "10.0.0.1")
+			require.NoError(t, err)
+			// Окно учёта отсчитывается назад от момента неудачи
+			call := repo.Calls[0]
+			now, windowStart := call.Arguments.Get(3).(time.Time), call.Arguments.Get(4).(time.Time)
+			assert.Equal(t, 15*time.Minute, now.Sub(windowStart))
+			if tt.lockout == 0 {
+				repo.AssertNotCalled(t, "LockLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			lockCall := repo.Calls[1]
+			assert.Equal(t, "LockLogin", lockCall.Method)
+			assert.Equal(t, tt.lockout, lockCall.Arguments.Get(3).(time.Time).Sub(now))
+		})
+	}
+}
+
+func TestLoginThrottle_RecordSuccess(t *testing.T) {
+	repo := new(mockLoginThrottleRepo)
+	throttle := services.NewLoginThrottle(repo, testLoginThrottleSettings)
+	ctx := context.Background()
+	repo.On("DeleteLoginThrottle", ctx, models.LoginThrottleAccount, "a@b.com").Return(nil)
+
+	err := throttle.RecordSuccess(ctx, "A@b.com")
+	assert.NoError(t, err)
+	// Счётчик IP-адреса успешным входом не сбрасывается
+	repo.AssertNumberOfCalls(t, "DeleteLoginThrottle", 1)
+}
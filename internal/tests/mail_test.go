@@ -0,0 +1,45 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iwtcode/user-order-api/internal/mail"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterMailer_Send(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := mail.NewWriterMailer(&buf, "no-reply@example.com")
+
+	err := mailer.Send(context.Background(), mail.Message{To: "a@b.com", Subject: "Hello", Body: "Body text"})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "From: no-reply@example.com")
+	assert.Contains(t, buf.String(), "To: a@b.com")
+	assert.Contains(t, buf.String(), "Subject: Hello")
+	assert.Contains(t, buf.String(), "Body text")
+}
+
+func TestFileMailer_AppendsMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	mailer, err := mail.NewMailer(mail.Config{Driver: mail.DriverFile, FilePath: path, From: "no-reply@example.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, mailer.Send(context.Background(), mail.Message{To: "a@b.com", Subject: "First", Body: "one"}))
+	require.NoError(t, mailer.Send(context.Background(), mail.Message{To: "c@d.com", Subject: "Second", Body: "two"}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Subject: First")
+	assert.Contains(t, string(data), "Subject: Second")
+}
+
+func TestNewMailer_UnsupportedDriver(t *testing.T) {
+	mailer, err := mail.NewMailer(mail.Config{Driver: "pigeon"})
+	assert.Error(t, err)
+	assert.Nil(t, mailer)
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMFAHandler_EnrollTOTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		id           string
+		mockSetup    func(m *mockMFAService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name: "success",
+			id:   "1",
+			mockSetup: func(m *mockMFAService) {
+				m.On("EnrollTOTP", mock.Anything, uint(1)).Return(&services.TOTPEnrollment{Secret: "SECRET", ProvisioningURI: "otpauth://totp/x"}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"secret": "SECRET", "provisioning_uri": "otpauth://totp/x"},
+		},
+		{
+			name: "already enabled",
+			id:   "1",
+			mockSetup: func(m *mockMFAService) {
+				m.On("EnrollTOTP", mock.Anything, uint(1)).Return(nil, services.ErrMFAAlreadyEnabled)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: map[string]interface{}{"error": "Two-factor authentication is already enabled"},
+		},
+		{
+			name: "forbidden",
+			id:   "2",
+			mockSetup: func(m *mockMFAService) {
+				m.On("EnrollTOTP", mock.Anything, uint(2)).Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "invalid id",
+			id:           "abc",
+			mockSetup:    func(m *mockMFAService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockMFAService)
+			tt.mockSetup(svc)
+			h := handlers.NewMFAHandler(svc)
+			router := gin.New()
+			router.POST("/users/:id/mfa/totp", h.EnrollTOTP)
+
+			req, _ := http.NewRequest(http.MethodPost, "/users/"+tt.id+"/mfa/totp", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != nil {
+				var resp map[string]interface{}
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.Equal(t, tt.expectedBody, resp)
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMFAHandler_ConfirmTOTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		body         interface{}
+		mockSetup    func(m *mockMFAService)
+		expectedCode int
+	}{
+		{
+			name: "success",
+			body: map[string]string{"code": "123456"},
+			mockSetup: func(m *mockMFAService) {
+				m.On("ConfirmTOTP", mock.Anything, uint(1), "123456").Return([]string{"abcde-23456"}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "invalid code",
+			body: map[string]string{"code": "000000"},
+			mockSetup: func(m *mockMFAService) {
+				m.On("ConfirmTOTP", mock.Anything, uint(1), "000000").Return(nil, services.ErrInvalidMFACode)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "not enrolled",
+			body: map[string]string{"code": "123456"},
+			mockSetup: func(m *mockMFAService) {
+				m.On("ConfirmTOTP", mock.Anything, uint(1), "123456").Return(nil, services.ErrMFANotEnrolled)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "missing code",
+			body:         map[string]string{},
+			mockSetup:    func(m *mockMFAService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "service error",
+			body: map[string]string{"code": "123456"},
+			mockSetup: func(m *mockMFAService) {
+				m.On("ConfirmTOTP", mock.Anything, uint(1), "123456").Return(nil, errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockMFAService)
+			tt.mockSetup(svc)
+			h := handlers.NewMFAHandler(svc)
+			router := gin.New()
+			router.POST("/users/:id/mfa/totp/confirm", h.ConfirmTOTP)
+
+			body, _ := json.Marshal(tt.body)
+			req, _ := http.NewRequest(http.MethodPost, "/users/1/mfa/totp/confirm", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				var resp handlers.RecoveryCodesResponse
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.Equal(t, []string{"abcde-23456"}, resp.RecoveryCodes)
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMFAHandler_DisableTOTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := new(mockMFAService)
+	svc.On("DisableTOTP", mock.Anything, uint(1), "abcde-23456").Return(nil)
+	h := handlers.NewMFAHandler(svc)
+	router := gin.New()
+	router.DELETE("/users/:id/mfa/totp", h.DisableTOTP)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/users/1/mfa/totp", bytes.NewBufferString(`{"code":"abcde-23456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	svc.AssertExpectations(t)
+}
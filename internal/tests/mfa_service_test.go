@@ -0,0 +1,272 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockMFARepo struct {
+	mock.Mock
+}
+
+func (m *mockMFARepo) GetTOTPFactor(ctx context.Context, userID uint) (*models.TOTPFactor, error) {
+	args := m.Called(ctx, userID)
+	factor, _ := args.Get(0).(*models.TOTPFactor)
+	return factor, args.Error(1)
+}
+func (m *mockMFARepo) SaveTOTPFactor(ctx context.Context, factor *models.TOTPFactor) error {
+	args := m.Called(ctx, factor)
+	return args.Error(0)
+}
+func (m *mockMFARepo) EnableTOTPFactor(ctx context.Context, userID uint, step int64, codeHashes []string) error {
+	args := m.Called(ctx, userID, step, codeHashes)
+	return args.Error(0)
+}
+func (m *mockMFARepo) UseTOTPStep(ctx context.Context, userID uint, step int64) error {
+	args := m.Called(ctx, userID, step)
+	return args.Error(0)
+}
+func (m *mockMFARepo) DeleteTOTPFactor(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+func (m *mockMFARepo) ConsumeRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Error(0)
+}
+
+type mockMFAService struct {
+	mock.Mock
+}
+
+func (m *mockMFAService) EnrollTOTP(ctx context.Context, userID uint) (*services.TOTPEnrollment, error) {
+	args := m.Called(ctx, userID)
+	enrollment, _ := args.Get(0).(*services.TOTPEnrollment)
+	return enrollment, args.Error(1)
+}
+func (m *mockMFAService) ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+func (m *mockMFAService) DisableTOTP(ctx context.Context, userID uint, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+func (m *mockMFAService) IsEnabled(ctx context.Context, userID uint) (bool, error) {
+	args := m.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockMFAService) StartChallenge(ctx context.Context, userID uint) (*services.MFAChallenge, error) {
+	args := m.Called(ctx, userID)
+	challenge, _ := args.Get(0).(*services.MFAChallenge)
+	return challenge, args.Error(1)
+}
+func (m *mockMFAService) CompleteChallenge(ctx context.Context, token, code string) (uint, error) {
+	args := m.Called(ctx, token, code)
+	userID, _ := args.Get(0).(uint)
+	return userID, args.Error(1)
+}
+
+// Сервис MFA, у которого двухфакторная аутентификация не включена ни у кого
+func newDisabledMFAService() *mockMFAService {
+	m := new(mockMFAService)
+	m.On("IsEnabled", mock.Anything, mock.Anything).Return(false, nil)
+	return m
+}
+
+var testMFASettings = services.MFASettings{Issuer: "user-order-api", PendingTokenTTL: 5 * time.Minute}
+
+// Возвращает подтверждённый фактор и действующий код для него
+func confirmedTestFactor(t *testing.T, userID uint) (*models.TOTPFactor, string, int64) {
+	t.Helper()
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+	step := utils.TOTPStep(time.Now())
+	code, err := utils.TOTPCode(secret, step)
+	require.NoError(t, err)
+	confirmedAt := time.Now().Add(-time.Hour)
+	return &models.TOTPFactor{UserID: userID, Secret: secret, ConfirmedAt: &confirmedAt}, code, step
+}
+
+func TestMFAService_EnrollTOTP(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	mfaRepo := new(mockMFARepo)
+	svc := services.NewMFAService(userRepo, mfaRepo, new(mockActionTokenRepo), services.NewAuthorizer(), testMFASettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Email: "a@b.com"}, nil)
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(nil, nil)
+	mfaRepo.On("SaveTOTPFactor", ctx, mock.AnythingOfType("*models.TOTPFactor")).Return(nil)
+
+	enrollment, err := svc.EnrollTOTP(ctx, 1)
+	require.NoError(t, err)
+	saved := mfaRepo.Calls[1].Arguments.Get(1).(*models.TOTPFactor)
+	assert.Equal(t, saved.Secret, enrollment.Secret)
+	assert.Nil(t, saved.ConfirmedAt)
+	assert.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/user-order-api:a@b.com?")
+	assert.Contains(t, enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+}
+
+func TestMFAService_EnrollTOTP_AlreadyEnabled(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	mfaRepo := new(mockMFARepo)
+	svc := services.NewMFAService(userRepo, mfaRepo, new(mockActionTokenRepo), services.NewAuthorizer(), testMFASettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	factor, _, _ := confirmedTestFactor(t, 1)
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1}, nil)
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(factor, nil)
+
+	_, err := svc.EnrollTOTP(ctx, 1)
+	assert.ErrorIs(t, err, services.ErrMFAAlreadyEnabled)
+	mfaRepo.AssertNotCalled(t, "SaveTOTPFactor", mock.Anything, mock.Anything)
+}
+
+func TestMFAService_EnrollTOTP_OtherUser(t *testing.T) {
+	mfaRepo := new(mockMFARepo)
+	svc := services.NewMFAService(new(mockUserRepo), mfaRepo, new(mockActionTokenRepo), services.NewAuthorizer(), testMFASettings)
+
+	_, err := svc.EnrollTOTP(contextWithUser(9, models.RoleAdmin), 1)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	mfaRepo.AssertNotCalled(t, "SaveTOTPFactor", mock.Anything, mock.Anything)
+}
+
+func TestMFAService_ConfirmTOTP(t *testing.T) {
+	mfaRepo := new(mockMFARepo)
+	svc := services.NewMFAService(new(mockUserRepo), mfaRepo, new(mockActionTokenRepo), services.NewAuthorizer(), testMFASettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	factor, code, step := confirmedTestFactor(t, 1)
+	factor.ConfirmedAt = nil
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(factor, nil)
+	mfaRepo.On("EnableTOTPFactor", ctx, uint(1), step, mock.Anything).Return(nil)
+
+	codes, err := svc.ConfirmTOTP(ctx, 1, code)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+	// В БД уходят только хеши кодов восстановления
+	hashes := mfaRepo.Calls[1].Arguments.Get(3).([]string)
+	require.Len(t, hashes, len(codes))
+	for i, recovery := range codes {
+		assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, recovery)
+		assert.NotContains(t, hashes, recovery)
+		assert.Equal(t, hashes[i], utils.HashToken(recovery[:5]+recovery[6:]))
+	}
+}
+
+func TestMFAService_ConfirmTOTP_InvalidCode(t *testing.T) {
+	mfaRepo := new(mockMFARepo)
+	svc := services.NewMFAService(new(mockUserRepo), mfaRepo, new(mockActionTokenRepo), services.NewAuthorizer(), testMFASettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(&models.TOTPFactor{UserID: 1, Secret: rfcTOTPSecret}, nil)
+
+	_, err := svc.ConfirmTOTP(ctx, 1, "000000")
+	assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+	mfaRepo.AssertNotCalled(t, "EnableTOTPFactor", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMFAService_DisableTOTP_RecoveryCode(t *testing.T) {
+	mfaRepo := new(mockMFARepo)
+	svc := services.NewMFAService(new(mockUserRepo), mfaRepo, new(mockActionTokenRepo), services.NewAuthorizer(), testMFASettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	factor, _, _ := confirmedTestFactor(t, 1)
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(factor, nil)
+	mfaRepo.On("ConsumeRecoveryCode", ctx, uint(1), utils.HashToken("abcde23456")).Return(nil)
+	mfaRepo.On("DeleteTOTPFactor", ctx, uint(1)).Return(nil)
+
+	err := svc.DisableTOTP(ctx, 1, "ABCDE-23456")
+	assert.NoError(t, err)
+	mfaRepo.AssertExpectations(t)
+}
+
+func TestMFAService_CompleteChallenge(t *testing.T) {
+	mfaRepo := new(mockMFARepo)
+	actionRepo := new(mockActionTokenRepo)
+	svc := services.NewMFAService(new(mockUserRepo), mfaRepo, actionRepo, services.NewAuthorizer(), testMFASettings)
+	ctx := context.Background()
+
+	factor, code, step := confirmedTestFactor(t, 1)
+	stored := &models.ActionToken{ID: 7, UserID: 1, Purpose: models.ActionMFALogin, ExpiresAt: time.Now().Add(time.Minute)}
+	actionRepo.On("GetActionTokenByHash", ctx, models.ActionMFALogin, utils.HashToken("mfa-token")).Return(stored, nil)
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(factor, nil)
+	mfaRepo.On("UseTOTPStep", ctx, uint(1), step).Return(nil)
+	actionRepo.On("ConsumeActionToken", ctx, uint(7)).Return(nil)
+
+	userID, err := svc.CompleteChallenge(ctx, "mfa-token", code)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), userID)
+}
+
+func TestMFAService_CompleteChallenge_InvalidCode(t *testing.T) {
+	mfaRepo := new(mockMFARepo)
+	actionRepo := new(mockActionTokenRepo)
+	svc := services.NewMFAService(new(mockUserRepo), mfaRepo, actionRepo, services.NewAuthorizer(), testMFASettings)
+	ctx := context.Background()
+
+	factor, _, _ := confirmedTestFactor(t, 1)
+	stored := &models.ActionToken{ID: 7, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}
+	actionRepo.On("GetActionTokenByHash", ctx, models.ActionMFALogin, utils.HashToken("mfa-token")).Return(stored, nil)
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(factor, nil)
+	mfaRepo.On("ConsumeRecoveryCode", ctx, uint(1), mock.AnythingOfType("string")).Return(gorm.ErrRecordNotFound)
+	actionRepo.On("RecordActionTokenFailure", ctx, uint(7), 5).Return(nil)
+
+	_, err := svc.CompleteChallenge(ctx, "mfa-token", "wrong-code")
+	assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+	actionRepo.AssertCalled(t, "RecordActionTokenFailure", ctx, uint(7), 5)
+	actionRepo.AssertNotCalled(t, "ConsumeActionToken", mock.Anything, mock.Anything)
+}
+
+func TestMFAService_CompleteChallenge_ReplayedCode(t *testing.T) {
+	mfaRepo := new(mockMFARepo)
+	actionRepo := new(mockActionTokenRepo)
+	svc := services.NewMFAService(new(mockUserRepo), mfaRepo, actionRepo, services.NewAuthorizer(), testMFASettings)
+	ctx := context.Background()
+
+	factor, code, step := confirmedTestFactor(t, 1)
+	stored := &models.ActionToken{ID: 7, UserID: 1, ExpiresAt: time.Now().Add(time.Minute)}
+	actionRepo.On("GetActionTokenByHash", ctx, models.ActionMFALogin, utils.HashToken("mfa-token")).Return(stored, nil)
+	mfaRepo.On("GetTOTPFactor", ctx, uint(1)).Return(factor, nil)
+	mfaRepo.On("UseTOTPStep", ctx, uint(1), step).Return(gorm.ErrRecordNotFound)
+	actionRepo.On("RecordActionTokenFailure", ctx, uint(7), 5).Return(nil)
+
+	_, err := svc.CompleteChallenge(ctx, "mfa-token", code)
+	assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+}
+
+func TestMFAService_CompleteChallenge_InvalidToken(t *testing.T) {
+	usedAt := time.Now()
+	tests := []struct {
+		name   string
+		stored *models.ActionToken
+	}{
+		{name: "unknown", stored: nil},
+		{name: "expired", stored: &models.ActionToken{ID: 7, UserID: 1, ExpiresAt: time.Now().Add(-time.Second)}},
+		{name: "used or out of attempts", stored: &models.ActionToken{ID: 7, UserID: 1, ExpiresAt: time.Now().Add(time.Minute), UsedAt: &usedAt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfaRepo := new(mockMFARepo)
+			actionRepo := new(mockActionTokenRepo)
+			svc := services.NewMFAService(new(mockUserRepo), mfaRepo, actionRepo, services.NewAuthorizer(), testMFASettings)
+			ctx := context.Background()
+
+			actionRepo.On("GetActionTokenByHash", ctx, models.ActionMFALogin, utils.HashToken("mfa-token")).Return(tt.stored, nil)
+
+			_, err := svc.CompleteChallenge(ctx, "mfa-token", "123456")
+			assert.ErrorIs(t, err, services.ErrInvalidMFAToken)
+			mfaRepo.AssertNotCalled(t, "GetTOTPFactor", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestOAuthClientRepository_RevokeOAuthClient(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewOAuthClientRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "oauth_clients" SET "revoked_at"=\$1 WHERE id = \$2 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.RevokeOAuthClient(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOAuthClientRepository_RevokeOAuthClient_AlreadyRevoked(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewOAuthClientRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "oauth_clients" SET "revoked_at"=\$1 WHERE id = \$2 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err := repo.RevokeOAuthClient(context.Background(), 3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOAuthClientRepository_GetOAuthClientByClientID_NotFound(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewOAuthClientRepository(db)
+	mock.ExpectQuery(`SELECT \* FROM "oauth_clients" WHERE client_id = \$1`).
+		WithArgs("uoc_0123456789abcdef", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "client_id", "secret_hash"}))
+	client, err := repo.GetOAuthClientByClientID(context.Background(), "uoc_0123456789abcdef")
+	assert.NoError(t, err)
+	assert.Nil(t, client)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,214 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockOAuthService struct {
+	mock.Mock
+}
+
+func (m *mockOAuthService) RegisterClient(ctx context.Context, req *models.CreateOAuthClientRequest) (*services.RegisteredOAuthClient, error) {
+	args := m.Called(ctx, req)
+	registered, _ := args.Get(0).(*services.RegisteredOAuthClient)
+	return registered, args.Error(1)
+}
+func (m *mockOAuthService) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	args := m.Called(ctx)
+	clients, _ := args.Get(0).([]models.OAuthClient)
+	return clients, args.Error(1)
+}
+func (m *mockOAuthService) RevokeClient(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+func (m *mockOAuthService) IssueClientToken(ctx context.Context, clientID, clientSecret string, scopes []string) (*services.ClientToken, error) {
+	args := m.Called(ctx, clientID, clientSecret, scopes)
+	token, _ := args.Get(0).(*services.ClientToken)
+	return token, args.Error(1)
+}
+
+func TestOAuthHandler_Token(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	issued := &services.ClientToken{AccessToken: "jwt", ExpiresIn: 900, Scopes: []string{models.ScopeOrdersRead}}
+	tests := []struct {
+		name         string
+		form         url.Values
+		basicID      string
+		basicSecret  string
+		mockSetup    func(m *mockOAuthService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:        "basic authentication",
+			form:        url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}},
+			basicID:     "uoc_0123456789abcdef",
+			basicSecret: "secret",
+			mockSetup: func(m *mockOAuthService) {
+				m.On("IssueClientToken", mock.Anything, "uoc_0123456789abcdef", "secret", []string{"orders:read"}).Return(issued, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"access_token":"jwt","token_type":"Bearer","expires_in":900,"scope":"orders:read"}`,
+		},
+		{
+			name: "credentials in form",
+			form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"uoc_0123456789abcdef"}, "client_secret": {"secret"}},
+			mockSetup: func(m *mockOAuthService) {
+				m.On("IssueClientToken", mock.Anything, "uoc_0123456789abcdef", "secret", []string{}).Return(issued, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "unsupported grant",
+			form:         url.Values{"grant_type": {"password"}, "client_id": {"uoc_0123456789abcdef"}, "client_secret": {"secret"}},
+			mockSetup:    func(m *mockOAuthService) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"unsupported_grant_type","error_description":"Only client_credentials grant is supported"}`,
+		},
+		{
+			name:         "missing credentials",
+			form:         url.Values{"grant_type": {"client_credentials"}},
+			mockSetup:    func(m *mockOAuthService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "credentials passed twice",
+			form:         url.Values{"grant_type": {"client_credentials"}, "client_id": {"uoc_0123456789abcdef"}, "client_secret": {"secret"}},
+			basicID:      "uoc_0123456789abcdef",
+			basicSecret:  "secret",
+			mockSetup:    func(m *mockOAuthService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:        "invalid client",
+			form:        url.Values{"grant_type": {"client_credentials"}},
+			basicID:     "uoc_0123456789abcdef",
+			basicSecret: "wrong",
+			mockSetup: func(m *mockOAuthService) {
+				m.On("IssueClientToken", mock.Anything, "uoc_0123456789abcdef", "wrong", mock.Anything).Return(nil, services.ErrInvalidClient)
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"error":"invalid_client","error_description":"Invalid client credentials"}`,
+		},
+		{
+			name:        "scope not registered",
+			form:        url.Values{"grant_type": {"client_credentials"}, "scope": {"users:write"}},
+			basicID:     "uoc_0123456789abcdef",
+			basicSecret: "secret",
+			mockSetup: func(m *mockOAuthService) {
+				m.On("IssueClientToken", mock.Anything, "uoc_0123456789abcdef", "secret", mock.Anything).Return(nil, fmt.Errorf("%w: %q", services.ErrScopeNotGranted, "users:write"))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:        "service error",
+			form:        url.Values{"grant_type": {"client_credentials"}},
+			basicID:     "uoc_0123456789abcdef",
+			basicSecret: "secret",
+			mockSetup: func(m *mockOAuthService) {
+				m.On("IssueClientToken", mock.Anything, "uoc_0123456789abcdef", "secret", mock.Anything).Return(nil, errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"server_error","error_description":"Failed to issue token"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockOAuthService)
+			tt.mockSetup(svc)
+			h := handlers.NewOAuthHandler(svc)
+			router := gin.New()
+			router.POST("/oauth/token", h.Token)
+
+			req, _ := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.basicID != "" {
+				req.SetBasicAuth(tt.basicID, tt.basicSecret)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOAuthHandler_CreateOAuthClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(m *mockOAuthService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "success",
+			body: `{"name":"billing","scopes":["orders:read"]}`,
+			mockSetup: func(m *mockOAuthService) {
+				m.On("RegisterClient", mock.Anything, &models.CreateOAuthClientRequest{Name: "billing", Scopes: []string{"orders:read"}}).
+					Return(&services.RegisteredOAuthClient{Secret: "secret", Client: &models.OAuthClient{ID: 2, ClientID: "uoc_0123456789abcdef", Name: "billing", Scopes: "orders:read", CreatedAt: createdAt}}, nil)
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":2,"client_id":"uoc_0123456789abcdef","name":"billing","scopes":["orders:read"],"last_used_at":null,"created_at":"2026-01-02T03:04:05Z","client_secret":"secret"}`,
+		},
+		{
+			name:         "no scopes",
+			body:         `{"name":"billing","scopes":[]}`,
+			mockSetup:    func(m *mockOAuthService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "not admin",
+			body: `{"name":"billing","scopes":["orders:read"]}`,
+			mockSetup: func(m *mockOAuthService) {
+				m.On("RegisterClient", mock.Anything, mock.Anything).Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":"Access denied: administrator role required"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockOAuthService)
+			tt.mockSetup(svc)
+			h := handlers.NewOAuthHandler(svc)
+			router := gin.New()
+			router.POST("/admin/oauth-clients", h.CreateOAuthClient)
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/oauth-clients", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockOAuthClientRepo struct {
+	mock.Mock
+}
+
+func (m *mockOAuthClientRepo) CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error {
+	args := m.Called(ctx, client)
+	return args.Error(0)
+}
+func (m *mockOAuthClientRepo) GetOAuthClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	args := m.Called(ctx, clientID)
+	client, _ := args.Get(0).(*models.OAuthClient)
+	return client, args.Error(1)
+}
+func (m *mockOAuthClientRepo) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	args := m.Called(ctx)
+	clients, _ := args.Get(0).([]models.OAuthClient)
+	return clients, args.Error(1)
+}
+func (m *mockOAuthClientRepo) RevokeOAuthClient(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+func (m *mockOAuthClientRepo) TouchOAuthClient(ctx context.Context, id uint, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func TestOAuthService_RegisterClient(t *testing.T) {
+	repo := new(mockOAuthClientRepo)
+	svc := services.NewOAuthService(repo, services.NewAuthorizer())
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("CreateOAuthClient", ctx, mock.AnythingOfType("*models.OAuthClient")).Return(nil)
+
+	registered, err := svc.RegisterClient(ctx, &models.CreateOAuthClientRequest{
+		Name:   "billing",
+		Scopes: []string{models.ScopeOrdersRead, models.ScopeUsersRead, models.ScopeOrdersRead},
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `^uoc_[0-9a-f]{16}$`, registered.Client.ClientID)
+	assert.Regexp(t, `^[A-Za-z0-9_-]{43}$`, registered.Secret)
+	// В БД попадает только хеш секрета
+	assert.Equal(t, utils.HashToken(registered.Secret), registered.Client.SecretHash)
+	assert.Equal(t, "orders:read users:read", registered.Client.Scopes)
+}
+
+func TestOAuthService_RegisterClient_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		req      *models.CreateOAuthClientRequest
+		expected error
+	}{
+		{name: "not admin", ctx: contextWithUser(1, models.RoleUser), req: &models.CreateOAuthClientRequest{Name: "billing", Scopes: []string{models.ScopeOrdersRead}}, expected: services.ErrForbidden},
+		{name: "unknown scope", ctx: contextWithUser(9, models.RoleAdmin), req: &models.CreateOAuthClientRequest{Name: "billing", Scopes: []string{"admin:all"}}, expected: services.ErrInvalidScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockOAuthClientRepo)
+			svc := services.NewOAuthService(repo, services.NewAuthorizer())
+
+			registered, err := svc.RegisterClient(tt.ctx, tt.req)
+			assert.ErrorIs(t, err, tt.expected)
+			assert.Nil(t, registered)
+			repo.AssertNotCalled(t, "CreateOAuthClient", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOAuthService_RevokeClient_NotFound(t *testing.T) {
+	repo := new(mockOAuthClientRepo)
+	svc := services.NewOAuthService(repo, services.NewAuthorizer())
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("RevokeOAuthClient", ctx, uint(3)).Return(gorm.ErrRecordNotFound)
+
+	err := svc.RevokeClient(ctx, 3)
+	assert.ErrorIs(t, err, services.ErrOAuthClientNotFound)
+}
+
+func TestOAuthService_IssueClientToken(t *testing.T) {
+	revoked := time.Now().Add(-time.Hour)
+	active := &models.OAuthClient{ID: 4, ClientID: "uoc_0123456789abcdef", SecretHash: utils.HashToken("secret"), Scopes: "orders:read orders:write"}
+	tests := []struct {
+		name     string
+		secret   string
+		scopes   []string
+		stored   *models.OAuthClient
+		expected error
+		granted  []string
+	}{
+		{name: "all registered scopes", secret: "secret", stored: active, granted: []string{models.ScopeOrdersRead, models.ScopeOrdersWrite}},
+		{name: "subset of scopes", secret: "secret", scopes: []string{models.ScopeOrdersRead}, stored: active, granted: []string{models.ScopeOrdersRead}},
+		{name: "scope not registered", secret: "secret", scopes: []string{models.ScopeUsersWrite}, stored: active, expected: services.ErrScopeNotGranted},
+		{name: "unknown scope", secret: "secret", scopes: []string{"admin:all"}, stored: active, expected: services.ErrInvalidScope},
+		{name: "wrong secret", secret: "guess", stored: active, expected: services.ErrInvalidClient},
+		{name: "unknown client", secret: "secret", expected: services.ErrInvalidClient},
+		{
+			name:     "revoked client",
+			secret:   "secret",
+			stored:   &models.OAuthClient{ID: 4, ClientID: "uoc_0123456789abcdef", SecretHash: utils.HashToken("secret"), Scopes: "orders:read", RevokedAt: &revoked},
+			expected: services.ErrInvalidClient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockOAuthClientRepo)
+			svc := services.NewOAuthService(repo, services.NewAuthorizer())
+			ctx := context.Background()
+
+			repo.On("GetOAuthClientByClientID", ctx, "uoc_0123456789abcdef").Return(tt.stored, nil).Maybe()
+			repo.On("TouchOAuthClient", ctx, uint(4), mock.AnythingOfType("time.Time")).Return(nil).Maybe()
+
+			token, err := svc.IssueClientToken(ctx, "uoc_0123456789abcdef", tt.secret, tt.scopes)
+			if tt.expected != nil {
+				assert.ErrorIs(t, err, tt.expected)
+				assert.Nil(t, token)
+				repo.AssertNotCalled(t, "TouchOAuthClient", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.granted, token.Scopes)
+			// Субъект токена — клиент: в нём нет user_id и роли
+			claims, err := utils.ParseJWT(token.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, "uoc_0123456789abcdef", claims["sub"])
+			assert.Equal(t, "uoc_0123456789abcdef", claims["client_id"])
+			assert.Equal(t, models.FormatScopes(tt.granted), claims["scope"])
+			assert.NotContains(t, claims, "user_id")
+			assert.NotContains(t, claims, "role")
+		})
+	}
+}
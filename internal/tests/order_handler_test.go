@@ -68,11 +68,13 @@ func TestOrderHandler_CreateOrder(t *testing.T) {
 			expectedBody: map[string]interface{}{"user_id": float64(1), "product": "Book", "quantity": float64(2), "price": 10.5},
 		},
 		{
-			name:         "forbidden access",
-			userIDPath:   "2",
-			jwtUserID:    1,
-			requestBody:  gin.H{"product": "Book", "quantity": 2, "price": 10.5},
-			mockSetup:    func(m *mockOrderService) {},
+			name:        "forbidden access",
+			userIDPath:  "2",
+			jwtUserID:   1,
+			requestBody: gin.H{"product": "Book", "quantity": 2, "price": 10.5},
+			mockSetup: func(m *mockOrderService) {
+				m.On("CreateOrder", mock.Anything, uint(2), &models.OrderCreateRequest{Product: "Book", Quantity: 2, Price: 10.5}).Return(nil, services.ErrForbidden)
+			},
 			expectedCode: http.StatusForbidden,
 			expectedBody: map[string]interface{}{"error": "Access denied: you can only operate with your own orders"},
 		},
@@ -170,10 +172,12 @@ func TestOrderHandler_GetOrdersByUserID(t *testing.T) {
 			expectedLen:  1,
 		},
 		{
-			name:         "forbidden access",
-			userIDPath:   "2",
-			jwtUserID:    1,
-			mockSetup:    func(m *mockOrderService) {},
+			name:       "forbidden access",
+			userIDPath: "2",
+			jwtUserID:  1,
+			mockSetup: func(m *mockOrderService) {
+				m.On("ListOrdersByUserID", mock.Anything, uint(2)).Return(nil, services.ErrForbidden)
+			},
 			expectedCode: http.StatusForbidden,
 			expectedBody: map[string]interface{}{"error": "Access denied: you can only view your own orders"},
 		},
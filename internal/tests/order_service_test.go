@@ -33,8 +33,8 @@ func (m *mockUserRepo) GetUserByID(ctx context.Context, id uint) (*models.User,
 func TestOrderService_CreateOrder(t *testing.T) {
 	orderRepo := new(mockOrderRepo)
 	userRepo := new(mockUserRepo)
-	svc := services.NewOrderService(orderRepo, userRepo)
-	ctx := context.Background()
+	svc := services.NewOrderService(orderRepo, userRepo, services.NewAuthorizer(), services.OrderSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
 
 	user := &models.User{Email: "a@b.com"}
 	orderReq := &models.OrderCreateRequest{Product: "Book", Quantity: 2, Price: 10.5}
@@ -51,11 +51,25 @@ func TestOrderService_CreateOrder(t *testing.T) {
 	assert.Equal(t, orderReq.Price, result.Order.Price)
 }
 
+func TestOrderService_CreateOrder_EmailNotVerified(t *testing.T) {
+	orderRepo := new(mockOrderRepo)
+	userRepo := new(mockUserRepo)
+	svc := services.NewOrderService(orderRepo, userRepo, services.NewAuthorizer(), services.OrderSettings{RequireVerifiedEmail: true})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Email: "a@b.com"}, nil)
+
+	result := <-svc.CreateOrder(ctx, 1, &models.OrderCreateRequest{Product: "Book", Quantity: 1, Price: 1})
+	assert.ErrorIs(t, result.Err, services.ErrEmailNotVerified)
+	assert.Nil(t, result.Order)
+	orderRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+}
+
 func TestOrderService_CreateOrder_UserNotFound(t *testing.T) {
 	orderRepo := new(mockOrderRepo)
 	userRepo := new(mockUserRepo)
-	svc := services.NewOrderService(orderRepo, userRepo)
-	ctx := context.Background()
+	svc := services.NewOrderService(orderRepo, userRepo, services.NewAuthorizer(), services.OrderSettings{})
+	ctx := contextWithUser(2, models.RoleUser)
 
 	orderReq := &models.OrderCreateRequest{Product: "Book", Quantity: 2, Price: 10.5}
 	userRepo.On("GetUserByID", ctx, uint(2)).Return(nil, nil)
@@ -69,8 +83,8 @@ func TestOrderService_CreateOrder_UserNotFound(t *testing.T) {
 func TestOrderService_ListOrdersByUserID(t *testing.T) {
 	orderRepo := new(mockOrderRepo)
 	userRepo := new(mockUserRepo)
-	svc := services.NewOrderService(orderRepo, userRepo)
-	ctx := context.Background()
+	svc := services.NewOrderService(orderRepo, userRepo, services.NewAuthorizer(), services.OrderSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
 
 	user := &models.User{Email: "a@b.com"}
 	orders := []models.Order{{Product: "Book"}, {Product: "Pen"}}
@@ -86,8 +100,8 @@ func TestOrderService_ListOrdersByUserID(t *testing.T) {
 func TestOrderService_ListOrdersByUserID_UserNotFound(t *testing.T) {
 	orderRepo := new(mockOrderRepo)
 	userRepo := new(mockUserRepo)
-	svc := services.NewOrderService(orderRepo, userRepo)
-	ctx := context.Background()
+	svc := services.NewOrderService(orderRepo, userRepo, services.NewAuthorizer(), services.OrderSettings{})
+	ctx := contextWithUser(1, models.RoleAdmin)
 
 	userRepo.On("GetUserByID", ctx, uint(2)).Return(nil, nil)
 
@@ -95,3 +109,29 @@ func TestOrderService_ListOrdersByUserID_UserNotFound(t *testing.T) {
 	assert.ErrorIs(t, err, services.ErrOrderUserNotFound)
 	assert.Nil(t, result)
 }
+
+func TestOrderService_CreateOrder_Forbidden(t *testing.T) {
+	orderRepo := new(mockOrderRepo)
+	userRepo := new(mockUserRepo)
+	svc := services.NewOrderService(orderRepo, userRepo, services.NewAuthorizer(), services.OrderSettings{})
+	ctx := contextWithUser(1, models.RoleAdmin)
+
+	orderReq := &models.OrderCreateRequest{Product: "Book", Quantity: 2, Price: 10.5}
+
+	result := <-svc.CreateOrder(ctx, 2, orderReq)
+	assert.ErrorIs(t, result.Err, services.ErrForbidden)
+	assert.Nil(t, result.Order)
+	orderRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+}
+
+func TestOrderService_ListOrdersByUserID_Forbidden(t *testing.T) {
+	orderRepo := new(mockOrderRepo)
+	userRepo := new(mockUserRepo)
+	svc := services.NewOrderService(orderRepo, userRepo, services.NewAuthorizer(), services.OrderSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	result, err := svc.ListOrdersByUserID(ctx, 2)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	assert.Nil(t, result)
+	userRepo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+}
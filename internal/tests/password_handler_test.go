@@ -0,0 +1,258 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockPasswordService struct {
+	mock.Mock
+}
+
+func (m *mockPasswordService) ForgotPassword(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+func (m *mockPasswordService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	args := m.Called(ctx, token, newPassword)
+	return args.Error(0)
+}
+func (m *mockPasswordService) ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) (*services.TokenPair, error) {
+	args := m.Called(ctx, userID, currentPassword, newPassword)
+	tokens, _ := args.Get(0).(*services.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func TestPasswordHandler_ForgotPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(m *mockPasswordService)
+		expectedCode int
+	}{
+		{
+			name: "success",
+			body: `{"email":"a@b.com"}`,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ForgotPassword", mock.Anything, "a@b.com").Return(nil)
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:         "invalid email",
+			body:         `{"email":"not-an-email"}`,
+			mockSetup:    func(m *mockPasswordService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "invalid json",
+			body:         `{"email":`,
+			mockSetup:    func(m *mockPasswordService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "service error",
+			body: `{"email":"a@b.com"}`,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ForgotPassword", mock.Anything, "a@b.com").Return(errors.New("db down"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockPasswordService)
+			tt.mockSetup(svc)
+			h := handlers.NewPasswordHandler(svc)
+			router := gin.New()
+			router.POST("/auth/password/forgot", h.ForgotPassword)
+
+			req, _ := http.NewRequest(http.MethodPost, "/auth/password/forgot", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPasswordHandler_ResetPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(m *mockPasswordService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name: "success",
+			body: `{"token":"reset-token","new_password":"newpassword"}`,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ResetPassword", mock.Anything, "reset-token", "newpassword").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "invalid token",
+			body: `{"token":"reset-token","new_password":"newpassword"}`,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ResetPassword", mock.Anything, "reset-token", "newpassword").Return(services.ErrInvalidResetToken)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{"error": "Invalid or expired password reset token"},
+		},
+		{
+			name: "weak password",
+			body: `{"token":"reset-token","new_password":"short"}`,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ResetPassword", mock.Anything, "reset-token", "short").Return(&services.PasswordPolicyError{Violations: []services.PasswordViolation{
+					{Rule: services.PasswordRuleMinLength, Message: "Password must be at least 8 characters long"},
+				}})
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: map[string]interface{}{
+				"error":      "Password does not meet the policy",
+				"violations": []interface{}{map[string]interface{}{"rule": "min_length", "message": "Password must be at least 8 characters long"}},
+			},
+		},
+		{
+			name:         "missing password",
+			body:         `{"token":"reset-token"}`,
+			mockSetup:    func(m *mockPasswordService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "service error",
+			body: `{"token":"reset-token","new_password":"newpassword"}`,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ResetPassword", mock.Anything, "reset-token", "newpassword").Return(errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockPasswordService)
+			tt.mockSetup(svc)
+			h := handlers.NewPasswordHandler(svc)
+			router := gin.New()
+			router.POST("/auth/password/reset", h.ResetPassword)
+
+			req, _ := http.NewRequest(http.MethodPost, "/auth/password/reset", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != nil {
+				var resp map[string]interface{}
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.Equal(t, tt.expectedBody, resp)
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPasswordHandler_ChangePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"current_password":"oldpassword","new_password":"newpassword"}`
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		mockSetup    func(m *mockPasswordService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name: "success",
+			path: "/users/1/password",
+			body: body,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ChangePassword", mock.Anything, uint(1), "oldpassword", "newpassword").
+					Return(&services.TokenPair{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 900, Scopes: models.AllScopes}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"token": "access", "refresh_token": "refresh", "token_type": "Bearer", "expires_in": float64(900), "scope": "users:read users:write orders:read orders:write"},
+		},
+		{
+			name: "incorrect current password",
+			path: "/users/1/password",
+			body: body,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ChangePassword", mock.Anything, uint(1), "oldpassword", "newpassword").Return(nil, services.ErrIncorrectPassword)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{"error": "Current password is incorrect"},
+		},
+		{
+			name: "other user",
+			path: "/users/2/password",
+			body: body,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ChangePassword", mock.Anything, uint(2), "oldpassword", "newpassword").Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "weak password",
+			path: "/users/1/password",
+			body: `{"current_password":"oldpassword","new_password":"short"}`,
+			mockSetup: func(m *mockPasswordService) {
+				m.On("ChangePassword", mock.Anything, uint(1), "oldpassword", "short").Return(nil, &services.PasswordPolicyError{Violations: []services.PasswordViolation{
+					{Rule: services.PasswordRuleMinLength, Message: "Password must be at least 8 characters long"},
+				}})
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "invalid id",
+			path:         "/users/abc/password",
+			body:         body,
+			mockSetup:    func(m *mockPasswordService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockPasswordService)
+			tt.mockSetup(svc)
+			h := handlers.NewPasswordHandler(svc)
+			router := gin.New()
+			router.PUT("/users/:id/password", h.ChangePassword)
+
+			req, _ := http.NewRequest(http.MethodPut, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != nil {
+				var resp map[string]interface{}
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.Equal(t, tt.expectedBody, resp)
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Политика без ограничений для тестов, не проверяющих пароль
+func newPermissivePasswordPolicy() services.PasswordPolicy {
+	policy, _ := services.NewPasswordPolicy(services.PasswordPolicySettings{})
+	return policy
+}
+
+// Политика с настройками по умолчанию: не короче 8 символов, не длиннее 72 байт, без email и имени
+func newTestPasswordPolicy(t *testing.T) services.PasswordPolicy {
+	t.Helper()
+	policy, err := services.NewPasswordPolicy(services.PasswordPolicySettings{MinLength: 8, MaxBytes: 72, RejectPersonalInfo: true})
+	require.NoError(t, err)
+	return policy
+}
+
+// Собирает имена нарушенных правил
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyErr *services.PasswordPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.ErrorIs(t, err, services.ErrWeakPassword)
+	rules := make([]string, 0, len(policyErr.Violations))
+	for _, v := range policyErr.Violations {
+		assert.NotEmpty(t, v.Message)
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	breachedFile := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(breachedFile, []byte("# top passwords\nPassword1!\n\nqwerty123\n"), 0o600))
+
+	policy, err := services.NewPasswordPolicy(services.PasswordPolicySettings{
+		MinLength:             10,
+		MaxBytes:              72,
+		RequireUppercase:      true,
+		RequireLowercase:      true,
+		RequireDigit:          true,
+		RequireSymbol:         true,
+		RejectPersonalInfo:    true,
+		BreachedPasswordsFile: breachedFile,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		expected []string
+	}{
+		{name: "strong", password: "Tr0ub4dor&3x", expected: nil},
+		{name: "too short", password: "Ab1!", expected: []string{services.PasswordRuleMinLength}},
+		{name: "length counts characters", password: "Пароль1!ок", expected: nil},
+		{name: "too long in bytes", password: "Aa1!" + strings.Repeat("x", 69), expected: []string{services.PasswordRuleMaxLength}},
+		{name: "missing classes", password: "abcdefghijkl", expected: []string{services.PasswordRuleUppercase, services.PasswordRuleDigit, services.PasswordRuleSymbol}},
+		{name: "contains name", password: "Bobrov#2024x", expected: []string{services.PasswordRulePersonalInfo}},
+		{name: "contains email local part", password: "X!9alice.smithx", expected: []string{services.PasswordRulePersonalInfo}},
+		{name: "breached ignoring case", password: "PASSWORD1!", expected: []string{services.PasswordRuleLowercase, services.PasswordRuleBreached}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "alice.smith@example.com", "Bobrov Al")
+			assert.Equal(t, tt.expected, violatedRules(t, err))
+		})
+	}
+}
+
+func TestPasswordPolicy_ShortNameFragmentsIgnored(t *testing.T) {
+	policy := newTestPasswordPolicy(t)
+
+	// Двухбуквенные имя и локальная часть email встречаются в паролях случайно
+	assert.NoError(t, policy.Validate("jolly-good-day", "jo@b.com", "Jo"))
+}
+
+func TestPasswordPolicy_MissingBreachedFile(t *testing.T) {
+	_, err := services.NewPasswordPolicy(services.PasswordPolicySettings{BreachedPasswordsFile: filepath.Join(t.TempDir(), "missing.txt")})
+	assert.ErrorContains(t, err, "failed to open breached passwords file")
+}
@@ -0,0 +1,279 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/mail"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockActionTokenRepo struct {
+	mock.Mock
+}
+
+func (m *mockActionTokenRepo) CreateActionToken(ctx context.Context, token *models.ActionToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+func (m *mockActionTokenRepo) GetActionTokenByHash(ctx context.Context, purpose, hash string) (*models.ActionToken, error) {
+	args := m.Called(ctx, purpose, hash)
+	token, _ := args.Get(0).(*models.ActionToken)
+	return token, args.Error(1)
+}
+func (m *mockActionTokenRepo) ConsumeActionToken(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+func (m *mockActionTokenRepo) InvalidateUserActionTokens(ctx context.Context, userID uint, purpose string) error {
+	args := m.Called(ctx, userID, purpose)
+	return args.Error(0)
+}
+func (m *mockActionTokenRepo) RecordActionTokenFailure(ctx context.Context, id uint, maxAttempts int) error {
+	args := m.Called(ctx, id, maxAttempts)
+	return args.Error(0)
+}
+
+type mockMailer struct {
+	mock.Mock
+}
+
+func (m *mockMailer) Send(ctx context.Context, msg mail.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+var testResetSettings = services.PasswordResetSettings{TokenTTL: time.Hour, ResetURL: "https://app.example.com/reset-password"}
+
+func TestPasswordService_ForgotPassword(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	actionRepo := new(mockActionTokenRepo)
+	mailer := new(mockMailer)
+	svc := services.NewPasswordService(userRepo, actionRepo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockAuthService), mailer, testResetSettings)
+	ctx := context.Background()
+
+	sent := make(chan mail.Message, 1)
+	userRepo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Name: "Test", Email: "a@b.com"}, nil)
+	actionRepo.On("InvalidateUserActionTokens", mock.Anything, uint(1), models.ActionPasswordReset).Return(nil)
+	actionRepo.On("CreateActionToken", mock.Anything, mock.AnythingOfType("*models.ActionToken")).Return(nil)
+	mailer.On("Send", mock.Anything, mock.AnythingOfType("mail.Message")).Return(nil).
+		Run(func(args mock.Arguments) { sent <- args.Get(1).(mail.Message) })
+
+	err := svc.ForgotPassword(ctx, "a@b.com")
+	require.NoError(t, err)
+
+	// Ссылка из письма содержит токен, хеш которого сохранён в БД
+	var msg mail.Message
+	select {
+	case msg = <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("reset email was not sent")
+	}
+	stored := actionRepo.Calls[1].Arguments.Get(1).(*models.ActionToken)
+	assert.Equal(t, "a@b.com", msg.To)
+	link := regexp.MustCompile(`https://app\.example\.com/reset-password\?token=\S+`).FindString(msg.Body)
+	require.NotEmpty(t, link)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, utils.HashToken(parsed.Query().Get("token")), stored.TokenHash)
+	assert.Equal(t, models.ActionPasswordReset, stored.Purpose)
+	assert.WithinDuration(t, time.Now().UTC().Add(time.Hour), stored.ExpiresAt, time.Minute)
+}
+
+func TestPasswordService_ForgotPassword_UnknownEmail(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	mailer := new(mockMailer)
+	svc := services.NewPasswordService(userRepo, new(mockActionTokenRepo), services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockAuthService), mailer, testResetSettings)
+	ctx := context.Background()
+
+	userRepo.On("GetUserByEmail", ctx, "unknown@b.com").Return(nil, nil)
+
+	err := svc.ForgotPassword(ctx, "unknown@b.com")
+	assert.NoError(t, err)
+	mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+}
+
+// Сбой отправки не меняет ответ: иначе по нему можно было бы узнать, что адрес зарегистрирован
+func TestPasswordService_ForgotPassword_MailError(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	actionRepo := new(mockActionTokenRepo)
+	mailer := new(mockMailer)
+	svc := services.NewPasswordService(userRepo, actionRepo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockAuthService), mailer, testResetSettings)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempted := make(chan error, 1)
+	userRepo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 1, Email: "a@b.com"}, nil)
+	actionRepo.On("InvalidateUserActionTokens", mock.Anything, uint(1), models.ActionPasswordReset).Return(nil)
+	actionRepo.On("CreateActionToken", mock.Anything, mock.AnythingOfType("*models.ActionToken")).Return(nil)
+	mailer.On("Send", mock.Anything, mock.AnythingOfType("mail.Message")).Return(errors.New("smtp down")).
+		Run(func(args mock.Arguments) { attempted <- args.Get(0).(context.Context).Err() })
+
+	err := svc.ForgotPassword(ctx, "a@b.com")
+	// Запрос завершён, но фоновая отправка не должна быть отменена вместе с ним
+	cancel()
+	assert.NoError(t, err)
+	select {
+	case ctxErr := <-attempted:
+		assert.NoError(t, ctxErr)
+	case <-time.After(time.Second):
+		t.Fatal("reset email was not attempted")
+	}
+}
+
+func TestPasswordService_ResetPassword(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	actionRepo := new(mockActionTokenRepo)
+	auth := new(mockAuthService)
+	svc := services.NewPasswordService(userRepo, actionRepo, services.NewAuthorizer(), newPermissivePasswordPolicy(), auth, new(mockMailer), testResetSettings)
+	ctx := context.Background()
+
+	stored := &models.ActionToken{ID: 3, UserID: 1, Purpose: models.ActionPasswordReset, ExpiresAt: time.Now().Add(time.Hour)}
+	actionRepo.On("GetActionTokenByHash", ctx, models.ActionPasswordReset, utils.HashToken("reset-token")).Return(stored, nil)
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "Test", Email: "a@b.com"}, nil)
+	actionRepo.On("ConsumeActionToken", ctx, uint(3)).Return(nil)
+	userRepo.On("UpdatePasswordHash", ctx, uint(1), mock.AnythingOfType("string")).Return(nil)
+	actionRepo.On("InvalidateUserActionTokens", ctx, uint(1), models.ActionPasswordReset).Return(nil)
+	auth.On("LogoutAll", ctx, uint(1)).Return(nil)
+
+	err := svc.ResetPassword(ctx, "reset-token", "newpassword")
+	require.NoError(t, err)
+	hash := userRepo.Calls[1].Arguments.String(2)
+	assert.True(t, utils.CheckPasswordHash("newpassword", hash))
+	auth.AssertExpectations(t)
+}
+
+func TestPasswordService_ResetPassword_InvalidToken(t *testing.T) {
+	usedAt := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name   string
+		stored *models.ActionToken
+	}{
+		{name: "unknown", stored: nil},
+		{name: "used", stored: &models.ActionToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}},
+		{name: "expired", stored: &models.ActionToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(mockUserRepo)
+			actionRepo := new(mockActionTokenRepo)
+			svc := services.NewPasswordService(userRepo, actionRepo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockAuthService), new(mockMailer), testResetSettings)
+			ctx := context.Background()
+
+			actionRepo.On("GetActionTokenByHash", ctx, models.ActionPasswordReset, utils.HashToken("reset-token")).Return(tt.stored, nil)
+
+			err := svc.ResetPassword(ctx, "reset-token", "newpassword")
+			assert.ErrorIs(t, err, services.ErrInvalidResetToken)
+			userRepo.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestPasswordService_ResetPassword_ConcurrentUse(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	actionRepo := new(mockActionTokenRepo)
+	svc := services.NewPasswordService(userRepo, actionRepo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockAuthService), new(mockMailer), testResetSettings)
+	ctx := context.Background()
+
+	stored := &models.ActionToken{ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	actionRepo.On("GetActionTokenByHash", ctx, models.ActionPasswordReset, utils.HashToken("reset-token")).Return(stored, nil)
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "Test", Email: "a@b.com"}, nil)
+	actionRepo.On("ConsumeActionToken", ctx, uint(3)).Return(gorm.ErrRecordNotFound)
+
+	err := svc.ResetPassword(ctx, "reset-token", "newpassword")
+	assert.ErrorIs(t, err, services.ErrInvalidResetToken)
+	userRepo.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPasswordService_ResetPassword_WeakPassword(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	actionRepo := new(mockActionTokenRepo)
+	svc := services.NewPasswordService(userRepo, actionRepo, services.NewAuthorizer(), newTestPasswordPolicy(t), new(mockAuthService), new(mockMailer), testResetSettings)
+	ctx := context.Background()
+
+	stored := &models.ActionToken{ID: 3, UserID: 1, Purpose: models.ActionPasswordReset, ExpiresAt: time.Now().Add(time.Hour)}
+	actionRepo.On("GetActionTokenByHash", ctx, models.ActionPasswordReset, utils.HashToken("reset-token")).Return(stored, nil)
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "Alice", Email: "alice@b.com"}, nil)
+
+	err := svc.ResetPassword(ctx, "reset-token", "alice2024")
+	var policyErr *services.PasswordPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, services.PasswordRulePersonalInfo, policyErr.Violations[0].Rule)
+	// Токен не использован, запрос можно повторить с другим паролем
+	actionRepo.AssertNotCalled(t, "ConsumeActionToken", mock.Anything, mock.Anything)
+	userRepo.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPasswordService_ChangePassword(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	actionRepo := new(mockActionTokenRepo)
+	auth := new(mockAuthService)
+	svc := services.NewPasswordService(userRepo, actionRepo, services.NewAuthorizer(), newPermissivePasswordPolicy(), auth, new(mockMailer), testResetSettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	hash, _ := utils.HashPassword("oldpassword")
+	tokens := &services.TokenPair{AccessToken: "access", RefreshToken: "refresh"}
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, PasswordHash: hash}, nil)
+	userRepo.On("UpdatePasswordHash", ctx, uint(1), mock.AnythingOfType("string")).Return(nil)
+	actionRepo.On("InvalidateUserActionTokens", ctx, uint(1), models.ActionPasswordReset).Return(nil)
+	auth.On("RevokeOtherSessions", ctx, uint(1)).Return(tokens, nil)
+
+	result, err := svc.ChangePassword(ctx, 1, "oldpassword", "newpassword")
+	require.NoError(t, err)
+	assert.Equal(t, tokens, result)
+	newHash := userRepo.Calls[1].Arguments.String(2)
+	assert.True(t, utils.CheckPasswordHash("newpassword", newHash))
+	auth.AssertExpectations(t)
+}
+
+func TestPasswordService_ChangePassword_IncorrectPassword(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	auth := new(mockAuthService)
+	svc := services.NewPasswordService(userRepo, new(mockActionTokenRepo), services.NewAuthorizer(), newPermissivePasswordPolicy(), auth, new(mockMailer), testResetSettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	hash, _ := utils.HashPassword("oldpassword")
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, PasswordHash: hash}, nil)
+
+	result, err := svc.ChangePassword(ctx, 1, "wrongpassword", "newpassword")
+	assert.ErrorIs(t, err, services.ErrIncorrectPassword)
+	assert.Nil(t, result)
+	userRepo.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+	auth.AssertNotCalled(t, "RevokeOtherSessions", mock.Anything, mock.Anything)
+}
+
+func TestPasswordService_ChangePassword_WeakPassword(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	auth := new(mockAuthService)
+	svc := services.NewPasswordService(userRepo, new(mockActionTokenRepo), services.NewAuthorizer(), newTestPasswordPolicy(t), auth, new(mockMailer), testResetSettings)
+	ctx := contextWithUser(1, models.RoleUser)
+
+	hash, _ := utils.HashPassword("oldpassword")
+	userRepo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "Test", Email: "a@b.com", PasswordHash: hash}, nil)
+
+	result, err := svc.ChangePassword(ctx, 1, "oldpassword", "short")
+	assert.ErrorIs(t, err, services.ErrWeakPassword)
+	assert.Nil(t, result)
+	userRepo.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything, mock.Anything)
+	auth.AssertNotCalled(t, "RevokeOtherSessions", mock.Anything, mock.Anything)
+}
+
+func TestPasswordService_ChangePassword_OtherUser(t *testing.T) {
+	userRepo := new(mockUserRepo)
+	svc := services.NewPasswordService(userRepo, new(mockActionTokenRepo), services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockAuthService), new(mockMailer), testResetSettings)
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	result, err := svc.ChangePassword(ctx, 1, "oldpassword", "newpassword")
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	assert.Nil(t, result)
+	userRepo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Настраивает хеширование паролей на время теста и восстанавливает настройки по умолчанию
+func configurePasswordHashingForTest(t *testing.T, cfg utils.PasswordHashConfig) {
+	t.Helper()
+	require.NoError(t, utils.ConfigurePasswordHashing(cfg))
+	t.Cleanup(func() { _ = utils.ConfigurePasswordHashing(utils.PasswordHashConfig{}) })
+}
+
+// Число обращений к алгоритму хеширования паролей
+type passwordHasherCalls struct {
+	hashes   int
+	verifies int
+}
+
+// Алгоритм хеширования, считающий вызовы: по ним тесты сравнивают работу разных путей выполнения
+type countingPasswordHasher struct {
+	utils.PasswordHasher
+	calls passwordHasherCalls
+}
+
+func (h *countingPasswordHasher) Hash(password string) (string, error) {
+	h.calls.hashes++
+	return h.PasswordHasher.Hash(password)
+}
+
+func (h *countingPasswordHasher) Verify(password, hash string) bool {
+	h.calls.verifies++
+	return h.PasswordHasher.Verify(password, hash)
+}
+
+// Возвращает вызовы с прошлого обращения и обнуляет счётчики
+func (h *countingPasswordHasher) take() passwordHasherCalls {
+	calls := h.calls
+	h.calls = passwordHasherCalls{}
+	return calls
+}
+
+// Подключает на время теста считающий вызовы bcrypt с минимальной стоимостью
+func useCountingPasswordHasher(t *testing.T) *countingPasswordHasher {
+	t.Helper()
+	bcryptHasher, err := utils.NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	hasher := &countingPasswordHasher{PasswordHasher: bcryptHasher}
+	require.NoError(t, utils.UsePasswordHasher(hasher))
+	t.Cleanup(func() { _ = utils.ConfigurePasswordHashing(utils.PasswordHashConfig{}) })
+	// Хеш-заглушка вычисляется при подключении алгоритма и в счёт не входит
+	hasher.take()
+	return hasher
+}
+
+// Быстрые параметры Argon2id для тестов
+var testArgon2Config = utils.PasswordHashConfig{
+	Algorithm:         utils.PasswordHashArgon2id,
+	Argon2Memory:      64,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+}
+
+func TestPasswordHasher_Bcrypt(t *testing.T) {
+	hasher, err := utils.NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+
+	hash, err := hasher.Hash("12345678")
+	require.NoError(t, err)
+	assert.Regexp(t, `^\$2a\$04\$`, hash)
+	assert.True(t, hasher.Supports(hash))
+	assert.True(t, hasher.Verify("12345678", hash))
+	assert.False(t, hasher.Verify("wrongpass", hash))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	stronger, err := utils.NewBcryptHasher(bcrypt.MinCost + 1)
+	require.NoError(t, err)
+	assert.True(t, stronger.NeedsRehash(hash))
+
+	_, err = utils.NewBcryptHasher(bcrypt.MaxCost + 1)
+	assert.Error(t, err)
+}
+
+func TestPasswordHasher_Argon2id(t *testing.T) {
+	hasher, err := utils.NewPasswordHasher(testArgon2Config)
+	require.NoError(t, err)
+
+	hash, err := hasher.Hash("12345678")
+	require.NoError(t, err)
+	// Формат PHC: алгоритм, версия, параметры, соль и ключ в base64 без выравнивания
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`, hash)
+	assert.True(t, hasher.Supports(hash))
+	assert.True(t, hasher.Verify("12345678", hash))
+	assert.False(t, hasher.Verify("wrongpass", hash))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	other, err := hasher.Hash("12345678")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salt must be random")
+
+	stronger, err := utils.NewArgon2idHasher(128, 1, 1)
+	require.NoError(t, err)
+	assert.True(t, stronger.NeedsRehash(hash))
+	// Проверка использует параметры из самого хеша
+	assert.True(t, stronger.Verify("12345678", hash))
+
+	assert.False(t, hasher.Verify("12345678", "$argon2id$v=19$m=64,t=1,p=1$bad"))
+	_, err = utils.NewArgon2idHasher(8, 1, 4)
+	assert.Error(t, err)
+}
+
+func TestPasswordHashing_MigrationBetweenAlgorithms(t *testing.T) {
+	configurePasswordHashingForTest(t, utils.PasswordHashConfig{Algorithm: utils.PasswordHashBcrypt, BcryptCost: bcrypt.MinCost})
+	bcryptHash, err := utils.HashPassword("12345678")
+	require.NoError(t, err)
+	assert.False(t, utils.PasswordNeedsRehash(bcryptHash))
+
+	configurePasswordHashingForTest(t, testArgon2Config)
+	argonHash, err := utils.HashPassword("12345678")
+	require.NoError(t, err)
+
+	// Хеши обоих алгоритмов принимаются, но bcrypt-хеш помечается к пересчёту
+	assert.True(t, utils.CheckPasswordHash("12345678", bcryptHash))
+	assert.True(t, utils.CheckPasswordHash("12345678", argonHash))
+	assert.True(t, utils.PasswordNeedsRehash(bcryptHash))
+	assert.False(t, utils.PasswordNeedsRehash(argonHash))
+	assert.False(t, utils.CheckPasswordHash("12345678", "plaintext"))
+	assert.False(t, utils.CheckDummyPasswordHash("12345678"))
+}
+
+func TestConfigurePasswordHashing_Invalid(t *testing.T) {
+	err := utils.ConfigurePasswordHashing(utils.PasswordHashConfig{Algorithm: "md5"})
+	assert.ErrorContains(t, err, `unsupported password hash algorithm "md5"`)
+}
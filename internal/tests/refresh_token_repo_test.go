@@ -0,0 +1,77 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestRefreshTokenRepository_CreateRefreshToken(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewRefreshTokenRepository(db)
+	token := &models.RefreshToken{UserID: 1, TokenHash: "hash", FamilyID: "fam", ExpiresAt: time.Now().Add(time.Hour)}
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "refresh_tokens"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	err := repo.CreateRefreshToken(context.Background(), token)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_GetRefreshTokenByHash_NotFound(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewRefreshTokenRepository(db)
+	mock.ExpectQuery(`SELECT \* FROM "refresh_tokens" WHERE token_hash = \$1`).WithArgs("hash", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token_hash", "family_id"}))
+	token, err := repo.GetRefreshTokenByHash(context.Background(), "hash")
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeRefreshToken(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewRefreshTokenRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE id = \$2 AND revoked_at IS NULL`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.RevokeRefreshToken(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeRefreshToken_AlreadyRevoked(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewRefreshTokenRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE id = \$2 AND revoked_at IS NULL`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err := repo.RevokeRefreshToken(context.Background(), 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeTokenFamily_Error(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewRefreshTokenRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE family_id = \$2 AND revoked_at IS NULL`).WillReturnError(errors.New("db error"))
+	mock.ExpectRollback()
+	err := repo.RevokeTokenFamily(context.Background(), "fam")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to revoke refresh token family")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
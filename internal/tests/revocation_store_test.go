@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRevocationRepo struct {
+	mock.Mock
+}
+
+func (m *mockRevocationRepo) RevokeToken(ctx context.Context, token *models.RevokedToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+func (m *mockRevocationRepo) RevokeUserTokens(ctx context.Context, revocation *models.UserTokenRevocation) error {
+	args := m.Called(ctx, revocation)
+	return args.Error(0)
+}
+func (m *mockRevocationRepo) ListActiveRevokedTokens(ctx context.Context, now time.Time) ([]models.RevokedToken, error) {
+	args := m.Called(ctx, now)
+	tokens, _ := args.Get(0).([]models.RevokedToken)
+	return tokens, args.Error(1)
+}
+func (m *mockRevocationRepo) ListActiveUserRevocations(ctx context.Context, now time.Time) ([]models.UserTokenRevocation, error) {
+	args := m.Called(ctx, now)
+	revocations, _ := args.Get(0).([]models.UserTokenRevocation)
+	return revocations, args.Error(1)
+}
+func (m *mockRevocationRepo) RevokeSession(ctx context.Context, revocation *models.RevokedSession) error {
+	args := m.Called(ctx, revocation)
+	return args.Error(0)
+}
+func (m *mockRevocationRepo) ListActiveRevokedSessions(ctx context.Context, now time.Time) ([]models.RevokedSession, error) {
+	args := m.Called(ctx, now)
+	sessions, _ := args.Get(0).([]models.RevokedSession)
+	return sessions, args.Error(1)
+}
+func (m *mockRevocationRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	args := m.Called(ctx, now)
+	return args.Error(0)
+}
+
+func TestRevocationStore_LoadsFromDatabase(t *testing.T) {
+	repo := new(mockRevocationRepo)
+	store := services.NewRevocationStore(repo, time.Minute)
+	ctx := context.Background()
+
+	revokedBefore := time.Now().Add(-time.Minute)
+	repo.On("DeleteExpired", ctx, mock.Anything).Return(nil)
+	repo.On("ListActiveRevokedTokens", ctx, mock.Anything).Return([]models.RevokedToken{{JTI: "revoked", UserID: 1}}, nil)
+	repo.On("ListActiveUserRevocations", ctx, mock.Anything).Return([]models.UserTokenRevocation{{UserID: 2, RevokedBefore: revokedBefore}}, nil)
+	repo.On("ListActiveRevokedSessions", ctx, mock.Anything).Return([]models.RevokedSession{{SessionID: 7, UserID: 3}}, nil)
+
+	revoked, err := store.IsRevoked(ctx, "revoked", 1, 0, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "other", 1, 0, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "old", 2, 0, revokedBefore.Add(-time.Second))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "new", 2, 0, revokedBefore.Add(time.Second))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "session", 3, 7, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "other-session", 3, 8, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	// Кэш свежий — повторной загрузки из БД быть не должно
+	repo.AssertNumberOfCalls(t, "ListActiveRevokedTokens", 1)
+}
+
+func TestRevocationStore_RevokeTokenIsVisibleImmediately(t *testing.T) {
+	repo := new(mockRevocationRepo)
+	store := services.NewRevocationStore(repo, time.Minute)
+	ctx := context.Background()
+
+	repo.On("DeleteExpired", ctx, mock.Anything).Return(nil)
+	repo.On("ListActiveRevokedTokens", ctx, mock.Anything).Return([]models.RevokedToken{}, nil)
+	repo.On("ListActiveUserRevocations", ctx, mock.Anything).Return([]models.UserTokenRevocation{}, nil)
+	repo.On("ListActiveRevokedSessions", ctx, mock.Anything).Return([]models.RevokedSession{}, nil)
+	repo.On("RevokeToken", ctx, mock.AnythingOfType("*models.RevokedToken")).Return(nil)
+
+	revoked, err := store.IsRevoked(ctx, "jti-1", 1, 0, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	err = store.RevokeToken(ctx, "jti-1", 1, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	revoked, err = store.IsRevoked(ctx, "jti-1", 1, 0, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevocationStore_RevokeAllForUser(t *testing.T) {
+	repo := new(mockRevocationRepo)
+	store := services.NewRevocationStore(repo, time.Minute)
+	ctx := context.Background()
+
+	repo.On("DeleteExpired", ctx, mock.Anything).Return(nil)
+	repo.On("ListActiveRevokedTokens", ctx, mock.Anything).Return([]models.RevokedToken{}, nil)
+	repo.On("ListActiveUserRevocations", ctx, mock.Anything).Return([]models.UserTokenRevocation{}, nil)
+	repo.On("ListActiveRevokedSessions", ctx, mock.Anything).Return([]models.RevokedSession{}, nil)
+	repo.On("RevokeUserTokens", ctx, mock.AnythingOfType("*models.UserTokenRevocation")).Return(nil)
+
+	_, err := store.IsRevoked(ctx, "jti-1", 1, 0, time.Now())
+	assert.NoError(t, err)
+
+	issuedBefore := time.Now().Add(-time.Second)
+	err = store.RevokeAllForUser(ctx, 1)
+	assert.NoError(t, err)
+	issuedAfter := time.Now().Add(time.Second)
+
+	revoked, err := store.IsRevoked(ctx, "jti-1", 1, 0, issuedBefore)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "jti-2", 1, 0, issuedAfter)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestRevocationStore_RevokeSession(t *testing.T) {
+	repo := new(mockRevocationRepo)
+	store := services.NewRevocationStore(repo, time.Minute)
+	ctx := context.Background()
+
+	repo.On("DeleteExpired", ctx, mock.Anything).Return(nil)
+	repo.On("ListActiveRevokedTokens", ctx, mock.Anything).Return([]models.RevokedToken{}, nil)
+	repo.On("ListActiveUserRevocations", ctx, mock.Anything).Return([]models.UserTokenRevocation{}, nil)
+	repo.On("ListActiveRevokedSessions", ctx, mock.Anything).Return([]models.RevokedSession{}, nil)
+	repo.On("RevokeSession", ctx, mock.MatchedBy(func(rs *models.RevokedSession) bool {
+		return rs.SessionID == 5 && rs.UserID == 1 && rs.ExpiresAt.After(time.Now())
+	})).Return(nil)
+
+	_, err := store.IsRevoked(ctx, "jti-1", 1, 5, time.Now())
+	assert.NoError(t, err)
+
+	err = store.RevokeSession(ctx, 5, 1)
+	assert.NoError(t, err)
+
+	// Отзываются все токены сессии, а не только последний выданный
+	revoked, err := store.IsRevoked(ctx, "jti-1", 1, 5, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "jti-2", 1, 0, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,98 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/middleware"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func addIdentityToContext(userID uint, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		role         string
+		expectedCode int
+	}{
+		{name: "admin allowed", role: models.RoleAdmin, expectedCode: http.StatusOK},
+		{name: "user forbidden", role: models.RoleUser, expectedCode: http.StatusForbidden},
+		{name: "no role forbidden", role: "", expectedCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(addIdentityToContext(1, tt.role))
+			r.GET("/admin", middleware.RequireRole(models.RoleAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req, _ := http.NewRequest(http.MethodGet, "/admin", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		identity     gin.HandlerFunc
+		expectedCode int
+	}{
+		{name: "user token", identity: addIdentityToContext(1, models.RoleUser), expectedCode: http.StatusOK},
+		{
+			name:         "client token",
+			identity:     func(c *gin.Context) { c.Set("client_id", "uoc_0123456789abcdef"); c.Next() },
+			expectedCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(tt.identity)
+			r.POST("/auth/logout", middleware.RequireUser(), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req, _ := http.NewRequest(http.MethodPost, "/auth/logout", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestDenyImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		identity     gin.HandlerFunc
+		expectedCode int
+	}{
+		{name: "user token", identity: addIdentityToContext(1, models.RoleUser), expectedCode: http.StatusOK},
+		{
+			name:         "impersonation token",
+			identity:     func(c *gin.Context) { c.Set("user_id", uint(1)); c.Set("actor_id", uint(9)); c.Next() },
+			expectedCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(tt.identity)
+			r.PUT("/users/:id/password", middleware.DenyImpersonation(), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req, _ := http.NewRequest(http.MethodPut, "/users/1/password", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/middleware"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func addScopesToContext(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("scopes", scopes)
+		c.Next()
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		scopes       []string
+		expectedCode int
+	}{
+		{name: "all required scopes", scopes: []string{models.ScopeOrdersRead, models.ScopeOrdersWrite}, expectedCode: http.StatusOK},
+		{name: "all scopes", scopes: models.AllScopes, expectedCode: http.StatusOK},
+		{name: "read-only token", scopes: []string{models.ScopeOrdersRead}, expectedCode: http.StatusForbidden},
+		{name: "no scopes", scopes: nil, expectedCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(addScopesToContext(tt.scopes...))
+			r.POST("/orders", middleware.RequireScopes(models.ScopeOrdersWrite), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req, _ := http.NewRequest(http.MethodPost, "/orders", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusForbidden {
+				assert.Equal(t, `Bearer error="insufficient_scope", scope="orders:write"`, w.Header().Get("WWW-Authenticate"))
+				assert.JSONEq(t, `{"error":"Access denied: insufficient scope"}`, w.Body.String())
+			}
+		})
+	}
+}
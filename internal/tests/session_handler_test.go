@@ -0,0 +1,124 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iwtcode/user-order-api/internal/handlers"
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSessionService struct {
+	mock.Mock
+}
+
+func (m *mockSessionService) ListSessions(ctx context.Context, userID uint) ([]models.Session, error) {
+	args := m.Called(ctx, userID)
+	sessions, _ := args.Get(0).([]models.Session)
+	return sessions, args.Error(1)
+}
+func (m *mockSessionService) RevokeSession(ctx context.Context, userID, sessionID uint) error {
+	args := m.Called(ctx, userID, sessionID)
+	return args.Error(0)
+}
+
+func TestSessionHandler_ListSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	lastSeenAt := createdAt.Add(time.Hour)
+	expiresAt := createdAt.Add(7 * 24 * time.Hour)
+	svc := new(mockSessionService)
+	svc.On("ListSessions", mock.Anything, uint(1)).Return([]models.Session{
+		{ID: 7, UserID: 1, FamilyID: "fam-7", UserAgent: "Mozilla/5.0", IPAddress: "10.0.0.1", CreatedAt: createdAt, LastSeenAt: lastSeenAt, ExpiresAt: expiresAt},
+		{ID: 5, UserID: 1, FamilyID: "fam-5", UserAgent: "curl/8.0", IPAddress: "10.0.0.2", CreatedAt: createdAt, LastSeenAt: createdAt, ExpiresAt: expiresAt},
+	}, nil)
+	h := handlers.NewSessionHandler(svc)
+	router := gin.New()
+	router.GET("/users/:id/sessions", func(c *gin.Context) {
+		c.Set("session_id", uint(7))
+		h.ListSessions(c)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/1/sessions", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"sessions":[
+		{"id":7,"user_agent":"Mozilla/5.0","ip_address":"10.0.0.1","current":true,"created_at":"2026-01-02T03:04:05Z","last_seen_at":"2026-01-02T04:04:05Z","expires_at":"2026-01-09T03:04:05Z"},
+		{"id":5,"user_agent":"curl/8.0","ip_address":"10.0.0.2","current":false,"created_at":"2026-01-02T03:04:05Z","last_seen_at":"2026-01-02T03:04:05Z","expires_at":"2026-01-09T03:04:05Z"}
+	]}`, w.Body.String())
+}
+
+func TestSessionHandler_RevokeSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		path         string
+		mockSetup    func(m *mockSessionService)
+		expectedCode int
+	}{
+		{
+			name: "success",
+			path: "/users/1/sessions/7",
+			mockSetup: func(m *mockSessionService) {
+				m.On("RevokeSession", mock.Anything, uint(1), uint(7)).Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "not found",
+			path: "/users/1/sessions/7",
+			mockSetup: func(m *mockSessionService) {
+				m.On("RevokeSession", mock.Anything, uint(1), uint(7)).Return(services.ErrSessionNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "forbidden",
+			path: "/users/2/sessions/7",
+			mockSetup: func(m *mockSessionService) {
+				m.On("RevokeSession", mock.Anything, uint(2), uint(7)).Return(services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name: "service error",
+			path: "/users/1/sessions/7",
+			mockSetup: func(m *mockSessionService) {
+				m.On("RevokeSession", mock.Anything, uint(1), uint(7)).Return(errors.New("db error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "invalid session id",
+			path:         "/users/1/sessions/abc",
+			mockSetup:    func(m *mockSessionService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockSessionService)
+			tt.mockSetup(svc)
+			h := handlers.NewSessionHandler(svc)
+			router := gin.New()
+			router.DELETE("/users/:id/sessions/:sid", h.RevokeSession)
+
+			req, _ := http.NewRequest(http.MethodDelete, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			svc.AssertExpectations(t)
+		})
+	}
+}
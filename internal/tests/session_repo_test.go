@@ -0,0 +1,37 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSessionRepository_RevokeSession_NotOwned(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewSessionRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"=\$1 WHERE id = \$2 AND user_id = \$3 AND revoked_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 7, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err := repo.RevokeSession(context.Background(), 2, 7)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_RevokeUserSessions_KeepsCurrent(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewSessionRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "sessions" SET "revoked_at"=\$1 WHERE \(user_id = \$2 AND revoked_at IS NULL\) AND id <> \$3`).
+		WithArgs(sqlmock.AnyArg(), 1, 7).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	err := repo.RevokeUserSessions(context.Background(), 1, 7)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
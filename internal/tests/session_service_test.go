@@ -0,0 +1,136 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockSessionRepo struct {
+	mock.Mock
+}
+
+func (m *mockSessionRepo) CreateSession(ctx context.Context, session *models.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+func (m *mockSessionRepo) GetSessionByID(ctx context.Context, id uint) (*models.Session, error) {
+	args := m.Called(ctx, id)
+	session, _ := args.Get(0).(*models.Session)
+	return session, args.Error(1)
+}
+func (m *mockSessionRepo) GetSessionByFamilyID(ctx context.Context, familyID string) (*models.Session, error) {
+	args := m.Called(ctx, familyID)
+	session, _ := args.Get(0).(*models.Session)
+	return session, args.Error(1)
+}
+func (m *mockSessionRepo) ListUserSessions(ctx context.Context, userID uint, now time.Time) ([]models.Session, error) {
+	args := m.Called(ctx, userID, now)
+	sessions, _ := args.Get(0).([]models.Session)
+	return sessions, args.Error(1)
+}
+func (m *mockSessionRepo) UpdateSession(ctx context.Context, session *models.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+func (m *mockSessionRepo) RevokeSession(ctx context.Context, userID, id uint) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+func (m *mockSessionRepo) RevokeSessionByFamilyID(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+func (m *mockSessionRepo) RevokeUserSessions(ctx context.Context, userID, exceptID uint) error {
+	args := m.Called(ctx, userID, exceptID)
+	return args.Error(0)
+}
+
+// Репозиторий сессий, который принимает любые изменения; новая сессия получает ID 1
+func newSessionRepoMock() *mockSessionRepo {
+	m := new(mockSessionRepo)
+	m.On("CreateSession", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Session).ID = 1
+	}).Return(nil).Maybe()
+	m.On("GetSessionByFamilyID", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	m.On("UpdateSession", mock.Anything, mock.Anything).Return(nil).Maybe()
+	m.On("RevokeSessionByFamilyID", mock.Anything, mock.Anything).Return(nil).Maybe()
+	m.On("RevokeUserSessions", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return m
+}
+
+func TestSessionService_ListSessions(t *testing.T) {
+	sessionRepo := new(mockSessionRepo)
+	svc := services.NewSessionService(sessionRepo, new(mockRefreshTokenRepo), new(mockRevocationStore), services.NewAuthorizer())
+	ctx := contextWithUser(1, models.RoleUser)
+
+	sessions := []models.Session{{ID: 3, UserID: 1, UserAgent: "curl/8.0", IPAddress: "10.0.0.1"}}
+	sessionRepo.On("ListUserSessions", ctx, uint(1), mock.AnythingOfType("time.Time")).Return(sessions, nil)
+
+	result, err := svc.ListSessions(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, sessions, result)
+}
+
+func TestSessionService_ListSessions_Forbidden(t *testing.T) {
+	sessionRepo := new(mockSessionRepo)
+	svc := services.NewSessionService(sessionRepo, new(mockRefreshTokenRepo), new(mockRevocationStore), services.NewAuthorizer())
+
+	_, err := svc.ListSessions(contextWithUser(2, models.RoleUser), 1)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	sessionRepo.AssertNotCalled(t, "ListUserSessions", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSessionService_RevokeSession(t *testing.T) {
+	sessionRepo := new(mockSessionRepo)
+	refreshRepo := new(mockRefreshTokenRepo)
+	revocations := new(mockRevocationStore)
+	svc := services.NewSessionService(sessionRepo, refreshRepo, revocations, services.NewAuthorizer())
+	ctx := contextWithUser(1, models.RoleUser)
+
+	session := &models.Session{ID: 3, UserID: 1, FamilyID: "fam"}
+	sessionRepo.On("GetSessionByID", ctx, uint(3)).Return(session, nil)
+	sessionRepo.On("RevokeSession", ctx, uint(1), uint(3)).Return(nil)
+	refreshRepo.On("RevokeTokenFamily", ctx, "fam").Return(nil)
+	revocations.On("RevokeSession", ctx, uint(3), uint(1)).Return(nil)
+
+	err := svc.RevokeSession(ctx, 1, 3)
+	assert.NoError(t, err)
+	sessionRepo.AssertExpectations(t)
+	refreshRepo.AssertExpectations(t)
+	revocations.AssertExpectations(t)
+}
+
+func TestSessionService_RevokeSession_NotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		session *models.Session
+		revoke  error
+	}{
+		{name: "unknown session", session: nil},
+		{name: "session of another user", session: &models.Session{ID: 3, UserID: 2, FamilyID: "fam"}},
+		{name: "already revoked", session: &models.Session{ID: 3, UserID: 1, FamilyID: "fam"}, revoke: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionRepo := new(mockSessionRepo)
+			refreshRepo := new(mockRefreshTokenRepo)
+			svc := services.NewSessionService(sessionRepo, refreshRepo, new(mockRevocationStore), services.NewAuthorizer())
+			ctx := contextWithUser(1, models.RoleUser)
+
+			sessionRepo.On("GetSessionByID", ctx, uint(3)).Return(tt.session, nil)
+			sessionRepo.On("RevokeSession", ctx, uint(1), uint(3)).Return(tt.revoke).Maybe()
+
+			err := svc.RevokeSession(ctx, 1, 3)
+			assert.ErrorIs(t, err, services.ErrSessionNotFound)
+			refreshRepo.AssertNotCalled(t, "RevokeTokenFamily", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/user-order-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Секрет из RFC 6238 ("12345678901234567890") в base32
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// Тестовые векторы RFC 6238 для SHA1, последние 6 из 8 цифр
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+	for _, tt := range tests {
+		code, err := utils.TOTPCode(rfcTOTPSecret, utils.TOTPStep(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code, "time %d", tt.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := utils.TOTPStep(now)
+	previous, _ := utils.TOTPCode(rfcTOTPSecret, step-1)
+	stale, _ := utils.TOTPCode(rfcTOTPSecret, step-2)
+
+	got, ok := utils.ValidateTOTP(rfcTOTPSecret, "005924", now)
+	assert.True(t, ok)
+	assert.Equal(t, step, got)
+
+	got, ok = utils.ValidateTOTP(rfcTOTPSecret, previous, now)
+	assert.True(t, ok, "code of the previous step is accepted within skew")
+	assert.Equal(t, step-1, got)
+
+	_, ok = utils.ValidateTOTP(rfcTOTPSecret, stale, now)
+	assert.False(t, ok)
+	_, ok = utils.ValidateTOTP(rfcTOTPSecret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri, err := url.Parse(utils.TOTPProvisioningURI(secret, "user-order-api", "a@b.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/user-order-api:a@b.com", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "user-order-api", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+}
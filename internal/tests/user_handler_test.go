@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -21,13 +22,13 @@ type mockUserService struct {
 	mock.Mock
 }
 
-func (m *mockUserService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+func (m *mockUserService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*services.SignupResult, error) {
 	args := m.Called(ctx, req)
-	user, _ := args.Get(0).(*models.User)
-	return user, args.Error(1)
+	result, _ := args.Get(0).(*services.SignupResult)
+	return result, args.Error(1)
 }
-func (m *mockUserService) ListUsers(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int64, error) {
-	args := m.Called(ctx, page, limit, minAge, maxAge)
+func (m *mockUserService) ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error) {
+	args := m.Called(ctx, query)
 	users, _ := args.Get(0).([]models.User)
 	total, _ := args.Get(1).(int64)
 	return users, total, args.Error(2)
@@ -37,8 +38,13 @@ func (m *mockUserService) GetUserByID(ctx context.Context, userID uint) (*models
 	user, _ := args.Get(0).(*models.User)
 	return user, args.Error(1)
 }
-func (m *mockUserService) UpdateUser(ctx context.Context, userID uint, req *models.UpdateUserRequest) (*models.User, error) {
-	args := m.Called(ctx, userID, req)
+func (m *mockUserService) UpdateUser(ctx context.Context, userID uint, req *models.UpdateUserRequest, ifMatch []int) (*models.User, error) {
+	args := m.Called(ctx, userID, req, ifMatch)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+func (m *mockUserService) PatchUser(ctx context.Context, userID uint, req *models.PatchUserRequest, ifMatch []int) (*models.User, error) {
+	args := m.Called(ctx, userID, req, ifMatch)
 	user, _ := args.Get(0).(*models.User)
 	return user, args.Error(1)
 }
@@ -46,6 +52,11 @@ func (m *mockUserService) DeleteUser(ctx context.Context, userID uint) error {
 	args := m.Called(ctx, userID)
 	return args.Error(0)
 }
+func (m *mockUserService) RestoreUser(ctx context.Context, userID uint) (*models.User, error) {
+	args := m.Called(ctx, userID)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
 
 func TestUserHandler_CreateUser(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -60,7 +71,7 @@ func TestUserHandler_CreateUser(t *testing.T) {
 			name:        "success",
 			requestBody: gin.H{"email": "a@b.com", "password": "12345678", "name": "Test", "age": 20},
 			mockSetup: func(m *mockUserService) {
-				m.On("CreateUser", mock.Anything, &models.CreateUserRequest{Email: "a@b.com", Password: "12345678", Name: "Test", Age: 20}).Return(&models.User{Email: "a@b.com", Name: "Test", Age: 20}, nil)
+				m.On("CreateUser", mock.Anything, &models.CreateUserRequest{Email: "a@b.com", Password: "12345678", Name: "Test", Age: 20}).Return(&services.SignupResult{User: &models.User{Email: "a@b.com", Name: "Test", Age: 20}}, nil)
 			},
 			expectedCode: http.StatusCreated,
 			expectedBody: map[string]interface{}{"email": "a@b.com", "name": "Test", "age": float64(20)},
@@ -72,6 +83,24 @@ func TestUserHandler_CreateUser(t *testing.T) {
 			expectedCode: http.StatusUnprocessableEntity,
 			expectedBody: map[string]interface{}{"error": "Validation failed"},
 		},
+		{
+			name:        "weak password",
+			requestBody: gin.H{"email": "a@b.com", "password": "password", "name": "Test", "age": 20},
+			mockSetup: func(m *mockUserService) {
+				m.On("CreateUser", mock.Anything, &models.CreateUserRequest{Email: "a@b.com", Password: "password", Name: "Test", Age: 20}).Return(nil, &services.PasswordPolicyError{Violations: []services.PasswordViolation{
+					{Rule: services.PasswordRuleDigit, Message: "Password must contain a digit"},
+					{Rule: services.PasswordRuleBreached, Message: "Password has appeared in a data breach, choose another one"},
+				}})
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: map[string]interface{}{
+				"error": "Password does not meet the policy",
+				"violations": []interface{}{
+					map[string]interface{}{"rule": "digit", "message": "Password must contain a digit"},
+					map[string]interface{}{"rule": "breached", "message": "Password has appeared in a data breach, choose another one"},
+				},
+			},
+		},
 		{
 			name:        "email exists",
 			requestBody: gin.H{"email": "a@b.com", "password": "12345678", "name": "Test", "age": 20},
@@ -115,6 +144,39 @@ func TestUserHandler_CreateUser(t *testing.T) {
 	}
 }
 
+func TestUserHandler_CreateUser_PrivateSignup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name          string
+		serviceResult *services.SignupResult
+		serviceErr    error
+		expectedCode  int
+	}{
+		{name: "accepted", serviceResult: &services.SignupResult{Accepted: true}, expectedCode: http.StatusAccepted},
+		{name: "internal error", serviceErr: errors.New("db error"), expectedCode: http.StatusInternalServerError},
+	}
+	bodies := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockUserService)
+			mockSvc.On("CreateUser", mock.Anything, mock.AnythingOfType("*models.CreateUserRequest")).Return(tt.serviceResult, tt.serviceErr)
+			h := handlers.NewUserHandler(mockSvc)
+			r := gin.New()
+			r.POST("/users", h.CreateUser)
+			body, _ := json.Marshal(gin.H{"email": "a@b.com", "password": "12345678", "name": "Test", "age": 20})
+			req, _ := http.NewRequest(http.MethodPost, "/users", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			bodies[tt.name] = w.Body.String()
+		})
+	}
+	// Принятый запрос не раскрывает данные пользователя
+	assert.NotContains(t, bodies["accepted"], `"id"`)
+	assert.NotContains(t, bodies["accepted"], "a@b.com")
+}
+
 func TestUserHandler_ListUsers(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	tests := []struct {
@@ -130,11 +192,33 @@ func TestUserHandler_ListUsers(t *testing.T) {
 			query: "?page=1&limit=2",
 			mockSetup: func(m *mockUserService) {
 				users := []models.User{{Email: "a@b.com", Name: "A", Age: 20}, {Email: "b@b.com", Name: "B", Age: 21}}
-				m.On("ListUsers", mock.Anything, 1, 2, 0, 0).Return(users, int64(2), nil)
+				m.On("ListUsers", mock.Anything, models.UserListQuery{ListQuery: models.ListQuery{Page: 1, Limit: 2}}).Return(users, int64(2), nil)
 			},
 			expectedCode: http.StatusOK,
 			expectedLen:  2,
 		},
+		{
+			name:  "filters and sort",
+			query: "?page=2&limit=5&min_age=18&q=+Ann+&email_domain=Example.com,@mail.ru&sort=name,-age",
+			mockSetup: func(m *mockUserService) {
+				query := models.UserListQuery{
+					ListQuery:    models.ListQuery{Page: 2, Limit: 5, Sort: []models.SortField{{Field: "name"}, {Field: "age", Desc: true}}},
+					MinAge:       18,
+					Search:       "Ann",
+					EmailDomains: []string{"example.com", "mail.ru"},
+				}
+				m.On("ListUsers", mock.Anything, query).Return([]models.User{{Email: "ann@example.com", Name: "Ann", Age: 20}}, int64(1), nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedLen:  1,
+		},
+		{
+			name:         "unknown sort field",
+			query:        "?sort=password_hash",
+			mockSetup:    func(m *mockUserService) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{"error": "sort must be a comma-separated list of distinct fields id, name, email, age, each optionally prefixed with -"},
+		},
 		{
 			name:         "bad params",
 			query:        "?page=0&limit=0",
@@ -146,7 +230,7 @@ func TestUserHandler_ListUsers(t *testing.T) {
 			name:  "internal error",
 			query: "?page=1&limit=2",
 			mockSetup: func(m *mockUserService) {
-				m.On("ListUsers", mock.Anything, 1, 2, 0, 0).Return(nil, int64(0), errors.New("db error"))
+				m.On("ListUsers", mock.Anything, models.UserListQuery{ListQuery: models.ListQuery{Page: 1, Limit: 2}}).Return(nil, int64(0), errors.New("db error"))
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: map[string]interface{}{"error": "Failed to fetch users"},
@@ -261,7 +345,7 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 			userID:      "1",
 			requestBody: gin.H{"name": "NewName", "email": "new@b.com", "age": 22},
 			mockSetup: func(m *mockUserService) {
-				m.On("UpdateUser", mock.Anything, uint(1), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}).Return(&models.User{Name: "NewName", Email: "new@b.com", Age: 22}, nil)
+				m.On("UpdateUser", mock.Anything, uint(1), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}, []int(nil)).Return(&models.User{Name: "NewName", Email: "new@b.com", Age: 22}, nil)
 			},
 			expectedCode: http.StatusOK,
 			expectedBody: map[string]interface{}{"name": "NewName", "email": "new@b.com", "age": float64(22)},
@@ -287,17 +371,27 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 			userID:      "2",
 			requestBody: gin.H{"name": "NewName", "email": "new@b.com", "age": 22},
 			mockSetup: func(m *mockUserService) {
-				m.On("UpdateUser", mock.Anything, uint(2), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}).Return(nil, services.ErrUserNotFound)
+				m.On("UpdateUser", mock.Anything, uint(2), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}, []int(nil)).Return(nil, services.ErrUserNotFound)
 			},
 			expectedCode: http.StatusNotFound,
 			expectedBody: map[string]interface{}{"error": "User not found"},
 		},
+		{
+			name:        "forbidden",
+			userID:      "2",
+			requestBody: gin.H{"name": "NewName", "email": "new@b.com", "age": 22},
+			mockSetup: func(m *mockUserService) {
+				m.On("UpdateUser", mock.Anything, uint(2), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}, []int(nil)).Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: map[string]interface{}{"error": "Access denied: you can only operate on your own account"},
+		},
 		{
 			name:        "email exists",
 			userID:      "1",
 			requestBody: gin.H{"name": "NewName", "email": "new@b.com", "age": 22},
 			mockSetup: func(m *mockUserService) {
-				m.On("UpdateUser", mock.Anything, uint(1), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}).Return(nil, services.ErrEmailExists)
+				m.On("UpdateUser", mock.Anything, uint(1), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}, []int(nil)).Return(nil, services.ErrEmailExists)
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{"error": "Email already exists"},
@@ -307,7 +401,7 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 			userID:      "1",
 			requestBody: gin.H{"name": "NewName", "email": "new@b.com", "age": 22},
 			mockSetup: func(m *mockUserService) {
-				m.On("UpdateUser", mock.Anything, uint(1), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}).Return(nil, errors.New("db error"))
+				m.On("UpdateUser", mock.Anything, uint(1), &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}, []int(nil)).Return(nil, errors.New("db error"))
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: map[string]interface{}{"error": "Failed to update user"},
@@ -337,6 +431,228 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 	}
 }
 
+func TestUserHandler_GetUserByID_ETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockSvc := new(mockUserService)
+	mockSvc.On("GetUserByID", mock.Anything, uint(1)).Return(&models.User{ID: 1, Version: 3}, nil)
+	h := handlers.NewUserHandler(mockSvc)
+	r := gin.New()
+	r.GET("/users/:id", h.GetUserByID)
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
+
+func TestUserHandler_UpdateUser_IfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	updateReq := &models.UpdateUserRequest{Name: "NewName", Email: "new@b.com", Age: 22}
+	tests := []struct {
+		name         string
+		ifMatch      string
+		mockSetup    func(m *mockUserService)
+		expectedCode int
+		expectedETag string
+	}{
+		{
+			name:    "matching version",
+			ifMatch: `"3"`,
+			mockSetup: func(m *mockUserService) {
+				m.On("UpdateUser", mock.Anything, uint(1), updateReq, []int{3}).Return(&models.User{ID: 1, Version: 4}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:    "list of versions",
+			ifMatch: `"2", "3"`,
+			mockSetup: func(m *mockUserService) {
+				m.On("UpdateUser", mock.Anything, uint(1), updateReq, []int{2, 3}).Return(&models.User{ID: 1, Version: 4}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:    "any version",
+			ifMatch: "*",
+			mockSetup: func(m *mockUserService) {
+				m.On("UpdateUser", mock.Anything, uint(1), updateReq, []int(nil)).Return(&models.User{ID: 1, Version: 4}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:    "weak etag never matches",
+			ifMatch: `W/"3"`,
+			mockSetup: func(m *mockUserService) {
+				m.On("UpdateUser", mock.Anything, uint(1), updateReq, []int{}).Return(nil, services.ErrVersionMismatch)
+			},
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "stale version",
+			ifMatch: `"2"`,
+			mockSetup: func(m *mockUserService) {
+				m.On("UpdateUser", mock.Anything, uint(1), updateReq, []int{2}).Return(nil, services.ErrVersionMismatch)
+			},
+			expectedCode: http.StatusPreconditionFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockUserService)
+			tt.mockSetup(mockSvc)
+			h := handlers.NewUserHandler(mockSvc)
+			r := gin.New()
+			r.PUT("/users/:id", h.UpdateUser)
+			body, _ := json.Marshal(updateReq)
+			req, _ := http.NewRequest(http.MethodPut, "/users/1", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", tt.ifMatch)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedETag, w.Header().Get("ETag"))
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserHandler_PatchUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newAge := 22
+	tests := []struct {
+		name         string
+		userID       string
+		contentType  string
+		body         string
+		mockSetup    func(m *mockUserService)
+		expectedCode int
+		expectedBody map[string]interface{}
+	}{
+		{
+			name:        "success",
+			userID:      "1",
+			contentType: "application/merge-patch+json",
+			body:        `{"age":22}`,
+			mockSetup: func(m *mockUserService) {
+				m.On("PatchUser", mock.Anything, uint(1), &models.PatchUserRequest{Age: &newAge}, []int(nil)).Return(&models.User{Name: "Name", Email: "a@b.com", Age: 22}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"name": "Name", "email": "a@b.com", "age": float64(22)},
+		},
+		{
+			name:        "plain json accepted",
+			userID:      "1",
+			contentType: "application/json",
+			body:        `{}`,
+			mockSetup: func(m *mockUserService) {
+				m.On("PatchUser", mock.Anything, uint(1), &models.PatchUserRequest{}, []int(nil)).Return(&models.User{Name: "Name"}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"name": "Name"},
+		},
+		{
+			name:         "null removes required field",
+			userID:       "1",
+			contentType:  "application/merge-patch+json",
+			body:         `{"name":null}`,
+			mockSetup:    func(m *mockUserService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: map[string]interface{}{"error": "Validation failed", "details": []interface{}{"name: required"}},
+		},
+		{
+			name:         "supplied field invalid",
+			userID:       "1",
+			contentType:  "application/merge-patch+json",
+			body:         `{"email":"not-an-email"}`,
+			mockSetup:    func(m *mockUserService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: map[string]interface{}{"error": "Validation failed"},
+		},
+		{
+			name:         "zero age rejected",
+			userID:       "1",
+			contentType:  "application/merge-patch+json",
+			body:         `{"age":0}`,
+			mockSetup:    func(m *mockUserService) {},
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: map[string]interface{}{"error": "Validation failed"},
+		},
+		{
+			name:         "not an object",
+			userID:       "1",
+			contentType:  "application/merge-patch+json",
+			body:         `[{"age":22}]`,
+			mockSetup:    func(m *mockUserService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unsupported content type",
+			userID:       "1",
+			contentType:  "text/plain",
+			body:         `{"age":22}`,
+			mockSetup:    func(m *mockUserService) {},
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "email exists",
+			userID:      "1",
+			contentType: "application/merge-patch+json",
+			body:        `{"email":"taken@b.com"}`,
+			mockSetup: func(m *mockUserService) {
+				email := "taken@b.com"
+				m.On("PatchUser", mock.Anything, uint(1), &models.PatchUserRequest{Email: &email}, []int(nil)).Return(nil, services.ErrEmailExists)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{"error": "Email already exists"},
+		},
+		{
+			name:        "not found",
+			userID:      "2",
+			contentType: "application/merge-patch+json",
+			body:        `{"age":22}`,
+			mockSetup: func(m *mockUserService) {
+				m.On("PatchUser", mock.Anything, uint(2), &models.PatchUserRequest{Age: &newAge}, []int(nil)).Return(nil, services.ErrUserNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:        "forbidden",
+			userID:      "2",
+			contentType: "application/merge-patch+json",
+			body:        `{"age":22}`,
+			mockSetup: func(m *mockUserService) {
+				m.On("PatchUser", mock.Anything, uint(2), &models.PatchUserRequest{Age: &newAge}, []int(nil)).Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockUserService)
+			tt.mockSetup(mockSvc)
+			h := handlers.NewUserHandler(mockSvc)
+			r := gin.New()
+			r.PATCH("/users/:id", h.PatchUser)
+			req, _ := http.NewRequest(http.MethodPatch, "/users/"+tt.userID, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			var resp map[string]interface{}
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			for k, v := range tt.expectedBody {
+				assert.Equal(t, v, resp[k])
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
 func TestUserHandler_DeleteUser(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	tests := []struct {
@@ -361,6 +677,15 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{"error": "Invalid user ID"},
 		},
+		{
+			name:   "forbidden",
+			userID: "3",
+			mockSetup: func(m *mockUserService) {
+				m.On("DeleteUser", mock.Anything, uint(3)).Return(services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: map[string]interface{}{"error": "Access denied: you can only operate on your own account"},
+		},
 		{
 			name:   "not found",
 			userID: "2",
@@ -370,6 +695,15 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 			expectedCode: http.StatusNotFound,
 			expectedBody: map[string]interface{}{"error": "User not found"},
 		},
+		{
+			name:   "has orders",
+			userID: "1",
+			mockSetup: func(m *mockUserService) {
+				m.On("DeleteUser", mock.Anything, uint(1)).Return(fmt.Errorf("%w: user id=1 has 2 orders", services.ErrUserHasOrders))
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: map[string]interface{}{"error": "User has orders and cannot be deleted"},
+		},
 		{
 			name:   "internal error",
 			userID: "1",
@@ -403,3 +737,66 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUserHandler_RestoreUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		userID       string
+		mockSetup    func(m *mockUserService)
+		expectedCode int
+	}{
+		{
+			name:   "success",
+			userID: "2",
+			mockSetup: func(m *mockUserService) {
+				m.On("RestoreUser", mock.Anything, uint(2)).Return(&models.User{ID: 2, Email: "a@b.com", Version: 4}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "not deleted",
+			userID: "2",
+			mockSetup: func(m *mockUserService) {
+				m.On("RestoreUser", mock.Anything, uint(2)).Return(nil, services.ErrUserNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:   "email taken",
+			userID: "2",
+			mockSetup: func(m *mockUserService) {
+				m.On("RestoreUser", mock.Anything, uint(2)).Return(nil, services.ErrEmailExists)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:   "not admin",
+			userID: "2",
+			mockSetup: func(m *mockUserService) {
+				m.On("RestoreUser", mock.Anything, uint(2)).Return(nil, services.ErrForbidden)
+			},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "bad id",
+			userID:       "abc",
+			mockSetup:    func(m *mockUserService) {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockUserService)
+			tt.mockSetup(mockSvc)
+			h := handlers.NewUserHandler(mockSvc)
+			r := gin.New()
+			r.POST("/users/:id/restore", h.RestoreUser)
+			req, _ := http.NewRequest(http.MethodPost, "/users/"+tt.userID+"/restore", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
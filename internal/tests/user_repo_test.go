@@ -2,13 +2,16 @@ package test
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/iwtcode/user-order-api/internal/models"
 	"github.com/iwtcode/user-order-api/internal/repository"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -61,7 +64,7 @@ func TestUserRepository_GetUserByEmail(t *testing.T) {
 	repo := repository.NewUserRepository(db)
 	email := "test@mail.com"
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash"}).AddRow(1, "Test", email, 30, "hash")
-	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1 ORDER BY "users"\."id" LIMIT \$2`).WithArgs(email, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).WithArgs(email, 1).WillReturnRows(rows)
 	user, err := repo.GetUserByEmail(context.Background(), email)
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
@@ -74,7 +77,7 @@ func TestUserRepository_GetUserByEmail_NotFound(t *testing.T) {
 	defer cleanup()
 	repo := repository.NewUserRepository(db)
 	email := "notfound@mail.com"
-	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1 ORDER BY "users"\."id" LIMIT \$2`).WithArgs(email, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash"}))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).WithArgs(email, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash"}))
 	user, err := repo.GetUserByEmail(context.Background(), email)
 	assert.NoError(t, err)
 	assert.Nil(t, user)
@@ -87,7 +90,7 @@ func TestUserRepository_GetUserByID(t *testing.T) {
 	repo := repository.NewUserRepository(db)
 	id := uint(1)
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash"}).AddRow(1, "Test", "test@mail.com", 30, "hash")
-	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).WithArgs(id, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).WithArgs(id, 1).WillReturnRows(rows)
 	user, err := repo.GetUserByID(context.Background(), id)
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
@@ -100,7 +103,7 @@ func TestUserRepository_GetUserByID_NotFound(t *testing.T) {
 	defer cleanup()
 	repo := repository.NewUserRepository(db)
 	id := uint(2)
-	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).WithArgs(id, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash"}))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).WithArgs(id, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash"}))
 	user, err := repo.GetUserByID(context.Background(), id)
 	assert.NoError(t, err)
 	assert.Nil(t, user)
@@ -112,21 +115,45 @@ func TestUserRepository_ListUsers(t *testing.T) {
 	defer cleanup()
 	repo := repository.NewUserRepository(db)
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash"}).AddRow(1, "Test", "test@mail.com", 30, "hash")
-	mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery(`SELECT \* FROM "users" LIMIT \$1`).WithArgs(10).WillReturnRows(rows)
-	users, total, err := repo.ListUsers(context.Background(), 1, 10, 0, 0)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE "users"\."deleted_at" IS NULL`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."deleted_at" IS NULL ORDER BY "id" LIMIT \$1`).WithArgs(10).WillReturnRows(rows)
+	users, total, err := repo.ListUsers(context.Background(), models.UserListQuery{ListQuery: models.ListQuery{Page: 1, Limit: 10}})
 	assert.NoError(t, err)
 	assert.Len(t, users, 1)
 	assert.Equal(t, int64(1), total)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUserRepository_ListUsers_FiltersAndSort(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	query := models.UserListQuery{
+		ListQuery:    models.ListQuery{Page: 3, Limit: 10, Sort: []models.SortField{{Field: "name"}, {Field: "age", Desc: true}}},
+		MinAge:       18,
+		Search:       "50%_off",
+		EmailDomains: []string{"example.com"},
+	}
+	where := `WHERE age >= $1 AND (name ILIKE $2 OR email ILIKE $3) AND LOWER(SPLIT_PART(email, '@', 2)) IN ($4) AND "users"."deleted_at" IS NULL`
+	pattern := `%50\%\_off%`
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "users" `+where)).
+		WithArgs(18, pattern, pattern, "example.com").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "age"}).AddRow(21, "Zed", "zed@example.com", 40)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" `+where+` ORDER BY "name","age" DESC,"id" LIMIT $5 OFFSET $6`)).
+		WithArgs(18, pattern, pattern, "example.com", 10, 20).WillReturnRows(rows)
+	users, total, err := repo.ListUsers(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, int64(21), total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUserRepository_ListUsers_Error(t *testing.T) {
 	db, mock, cleanup := setupMockDBUser(t)
 	defer cleanup()
 	repo := repository.NewUserRepository(db)
 	mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).WillReturnError(errors.New("db error"))
-	users, total, err := repo.ListUsers(context.Background(), 1, 10, 0, 0)
+	users, total, err := repo.ListUsers(context.Background(), models.UserListQuery{ListQuery: models.ListQuery{Page: 1, Limit: 10}})
 	assert.Error(t, err)
 	assert.Nil(t, users)
 	assert.Equal(t, int64(0), total)
@@ -146,6 +173,36 @@ func TestUserRepository_UpdateUser(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUserRepository_UpdateUser_ConditionalOnVersion(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	user := &models.User{ID: 1, Name: "Test", Email: "test@mail.com", Age: 30, Version: 3}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "age"=$1,"email"=$2,"email_verified_at"=$3,"name"=$4,"version"=version + 1 WHERE (id = $5 AND version = $6) AND "users"."deleted_at" IS NULL`)).
+		WithArgs(30, "test@mail.com", nil, "Test", 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.UpdateUser(context.Background(), user)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, user.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdateUser_StaleVersion(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	user := &models.User{ID: 1, Name: "Test", Email: "test@mail.com", Age: 30, Version: 3}
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err := repo.UpdateUser(context.Background(), user)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, 3, user.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUserRepository_UpdateUser_Error(t *testing.T) {
 	db, mock, cleanup := setupMockDBUser(t)
 	defer cleanup()
@@ -160,15 +217,55 @@ func TestUserRepository_UpdateUser_Error(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUserRepository_UpdateUserFields_WritesOnlyGivenColumns(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "age"=$1,"version"=version + 1 WHERE (id = $2 AND version = $3) AND "users"."deleted_at" IS NULL`)).
+		WithArgs(31, 1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.UpdateUserFields(context.Background(), 1, 4, map[string]interface{}{"age": 31})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdateUserFields_NotFound(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err := repo.UpdateUserFields(context.Background(), 7, 1, map[string]interface{}{"name": "New"})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_MarkEmailVerified(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "email_verified_at"=$1,"version"=version + 1 WHERE (id = $2 AND email_verified_at IS NULL) AND "users"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.MarkEmailVerified(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUserRepository_DeleteUser(t *testing.T) {
 	db, mock, cleanup := setupMockDBUser(t)
 	defer cleanup()
 	repo := repository.NewUserRepository(db)
 	id := uint(1)
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "users" WHERE "users"\."id" = \$1`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "users" SET "deleted_at"=\$1 WHERE "users"\."id" = \$2 AND "users"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), id).WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
-	err := repo.DeleteUser(context.Background(), id)
+	err := repo.DeleteUser(context.Background(), id, models.OrderPolicyCascade)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -179,9 +276,9 @@ func TestUserRepository_DeleteUser_NotFound(t *testing.T) {
 	repo := repository.NewUserRepository(db)
 	id := uint(2)
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "users" WHERE "users"\."id" = \$1`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectCommit()
-	err := repo.DeleteUser(context.Background(), id)
+	mock.ExpectExec(`UPDATE "users" SET "deleted_at"=\$1 WHERE "users"\."id" = \$2 AND "users"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), id).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	err := repo.DeleteUser(context.Background(), id, models.OrderPolicyCascade)
 	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -192,10 +289,186 @@ func TestUserRepository_DeleteUser_Error(t *testing.T) {
 	repo := repository.NewUserRepository(db)
 	id := uint(1)
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "users" WHERE "users"\."id" = \$1`).WithArgs(id).WillReturnError(errors.New("db error"))
+	mock.ExpectExec(`UPDATE "users" SET "deleted_at"=\$1 WHERE "users"\."id" = \$2 AND "users"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), id).WillReturnError(errors.New("db error"))
 	mock.ExpectRollback()
-	err := repo.DeleteUser(context.Background(), id)
+	err := repo.DeleteUser(context.Background(), id, models.OrderPolicyCascade)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to delete user")
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestUserRepository_DeleteUser_RestrictWithoutOrders(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2 FOR UPDATE`).
+		WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "orders" WHERE user_id = \$1`).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`UPDATE "users" SET "deleted_at"=\$1 WHERE "users"\."id" = \$2 AND "users"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.DeleteUser(context.Background(), 2, models.OrderPolicyRestrict)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_DeleteUser_RestrictWithOrders(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2 FOR UPDATE`).
+		WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "orders" WHERE user_id = \$1`).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectRollback()
+	err := repo.DeleteUser(context.Background(), 2, models.OrderPolicyRestrict)
+	assert.ErrorIs(t, err, repository.ErrUserHasOrders)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetDeletedUserByID(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "age", "password_hash", "deleted_at"}).
+		AddRow(3, "Test", "test@mail.com", 30, "hash", time.Now())
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE deleted_at IS NOT NULL AND "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		WithArgs(3, 1).WillReturnRows(rows)
+	user, err := repo.GetDeletedUserByID(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.NotNil(t, user)
+	assert.True(t, user.DeletedAt.Valid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_RestoreUser(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "deleted_at"=$1,"version"=version + 1 WHERE id = $2 AND deleted_at IS NOT NULL`)).
+		WithArgs(nil, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err := repo.RestoreUser(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_RestoreUser_EmailTaken(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "deleted_at"=$1,"version"=version + 1 WHERE id = $2 AND deleted_at IS NOT NULL`)).
+		WithArgs(nil, 3).WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email_active"})
+	mock.ExpectRollback()
+	err := repo.RestoreUser(context.Background(), 3)
+	assert.ErrorIs(t, err, repository.ErrEmailTaken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Таблицы с данными пользователя, которые удаляются вместе с ним
+var purgedUserTables = []string{"sessions", "refresh_tokens", "api_keys", "totp_factors", "recovery_codes", "action_tokens", "revoked_tokens", "user_token_revocations", "revoked_sessions"}
+
+// Ожидает блокировку строк удаляемых пользователей, возвращающую ids
+func expectLockExpiredUsers(mock sqlmock.Sqlmock, query string, deletedBefore time.Time, ids ...uint) {
+	rows := sqlmock.NewRows([]string{"id"})
+	for _, id := range ids {
+		rows.AddRow(id)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(deletedBefore).WillReturnRows(rows)
+}
+
+func expectPurgeUserOwnedRows(mock sqlmock.Sqlmock, args ...driver.Value) {
+	for _, table := range purgedUserTables {
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE user_id IN ($1,$2)`)).
+			WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+const lockExpiredUsersQuery = `SELECT "id" FROM "users" WHERE deleted_at IS NOT NULL AND deleted_at < $1 FOR UPDATE`
+
+func TestUserRepository_PurgeDeletedUsers(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	deletedBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectLockExpiredUsers(mock, `SELECT "id" FROM "users" WHERE (deleted_at IS NOT NULL AND deleted_at < $1) AND NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id) FOR UPDATE`, deletedBefore, 3, 4)
+	expectPurgeUserOwnedRows(mock, 3, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE id IN ($1,$2)`)).
+		WithArgs(3, 4).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	purged, err := repo.PurgeDeletedUsers(context.Background(), deletedBefore, models.OrderPolicyRestrict)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_PurgeDeletedUsers_Cascade(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	deletedBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectLockExpiredUsers(mock, lockExpiredUsersQuery, deletedBefore, 3, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "orders" WHERE user_id IN ($1,$2)`)).
+		WithArgs(3, 4).WillReturnResult(sqlmock.NewResult(0, 5))
+	expectPurgeUserOwnedRows(mock, 3, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE id IN ($1,$2)`)).
+		WithArgs(3, 4).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	purged, err := repo.PurgeDeletedUsers(context.Background(), deletedBefore, models.OrderPolicyCascade)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_PurgeDeletedUsers_Anonymize(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	deletedBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectLockExpiredUsers(mock, lockExpiredUsersQuery, deletedBefore, 3, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "user_id"=$1 WHERE user_id IN ($2,$3)`)).
+		WithArgs(nil, 3, 4).WillReturnResult(sqlmock.NewResult(0, 5))
+	expectPurgeUserOwnedRows(mock, 3, 4)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE id IN ($1,$2)`)).
+		WithArgs(3, 4).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	purged, err := repo.PurgeDeletedUsers(context.Background(), deletedBefore, models.OrderPolicyAnonymize)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_PurgeDeletedUsers_NothingExpired(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	deletedBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectLockExpiredUsers(mock, lockExpiredUsersQuery, deletedBefore)
+	mock.ExpectCommit()
+	purged, err := repo.PurgeDeletedUsers(context.Background(), deletedBefore, models.OrderPolicyCascade)
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_PurgeDeletedUsers_Error(t *testing.T) {
+	db, mock, cleanup := setupMockDBUser(t)
+	defer cleanup()
+	repo := repository.NewUserRepository(db)
+	deletedBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectLockExpiredUsers(mock, lockExpiredUsersQuery, deletedBefore, 3)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "orders"`)).WillReturnError(errors.New("db error"))
+	mock.ExpectRollback()
+	purged, err := repo.PurgeDeletedUsers(context.Background(), deletedBefore, models.OrderPolicyCascade)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to purge deleted users")
+	assert.Zero(t, purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,12 +2,18 @@ package test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/iwtcode/user-order-api/internal/mail"
 	"github.com/iwtcode/user-order-api/internal/models"
+	"github.com/iwtcode/user-order-api/internal/repository"
 	"github.com/iwtcode/user-order-api/internal/services"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 type mockUserRepo struct {
@@ -23,8 +29,8 @@ func (m *mockUserRepo) CreateUser(ctx context.Context, user *models.User) error
 	args := m.Called(ctx, user)
 	return args.Error(0)
 }
-func (m *mockUserRepo) ListUsers(ctx context.Context, page, limit, minAge, maxAge int) ([]models.User, int64, error) {
-	args := m.Called(ctx, page, limit, minAge, maxAge)
+func (m *mockUserRepo) ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error) {
+	args := m.Called(ctx, query)
 	users, _ := args.Get(0).([]models.User)
 	total, _ := args.Get(1).(int64)
 	return users, total, args.Error(2)
@@ -33,44 +39,160 @@ func (m *mockUserRepo) UpdateUser(ctx context.Context, user *models.User) error
 	args := m.Called(ctx, user)
 	return args.Error(0)
 }
-func (m *mockUserRepo) DeleteUser(ctx context.Context, id uint) error {
+func (m *mockUserRepo) UpdateUserFields(ctx context.Context, id uint, version int, fields map[string]interface{}) error {
+	args := m.Called(ctx, id, version, fields)
+	return args.Error(0)
+}
+func (m *mockUserRepo) GetDeletedUserByID(ctx context.Context, id uint) (*models.User, error) {
+	args := m.Called(ctx, id)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+func (m *mockUserRepo) RestoreUser(ctx context.Context, id uint) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
+func (m *mockUserRepo) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time, orderPolicy string) (int64, error) {
+	args := m.Called(ctx, deletedBefore, orderPolicy)
+	purged, _ := args.Get(0).(int64)
+	return purged, args.Error(1)
+}
+func (m *mockUserRepo) UpdatePasswordHash(ctx context.Context, id uint, passwordHash string) error {
+	args := m.Called(ctx, id, passwordHash)
+	return args.Error(0)
+}
+func (m *mockUserRepo) MarkEmailVerified(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+func (m *mockUserRepo) DeleteUser(ctx context.Context, id uint, orderPolicy string) error {
+	args := m.Called(ctx, id, orderPolicy)
+	return args.Error(0)
+}
 
 func TestUserService_CreateUser(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewUserService(repo)
+	verification := new(mockEmailVerificationService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), verification, new(mockAuthService), new(mockMailer), services.UserSettings{})
 	ctx := context.Background()
 
 	req := &models.CreateUserRequest{Name: "Test", Email: "a@b.com", Age: 20, Password: "12345678"}
 
 	repo.On("GetUserByEmail", ctx, req.Email).Return(nil, nil)
 	repo.On("CreateUser", ctx, mock.AnythingOfType("*models.User")).Return(nil)
+	verification.On("SendVerification", ctx, mock.AnythingOfType("*models.User")).Return(nil)
 
-	user, err := svc.CreateUser(ctx, req)
-	assert.NoError(t, err)
+	result, err := svc.CreateUser(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, result.Accepted)
+	user := result.User
 	assert.Equal(t, req.Email, user.Email)
 	assert.Equal(t, req.Name, user.Name)
 	assert.Equal(t, req.Age, user.Age)
+	assert.Nil(t, user.EmailVerifiedAt)
+	verification.AssertCalled(t, "SendVerification", ctx, user)
+}
+
+func TestUserService_CreateUser_VerificationMailFailure(t *testing.T) {
+	repo := new(mockUserRepo)
+	verification := new(mockEmailVerificationService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), verification, new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := context.Background()
+
+	req := &models.CreateUserRequest{Name: "Test", Email: "a@b.com", Age: 20, Password: "12345678"}
+
+	repo.On("GetUserByEmail", ctx, req.Email).Return(nil, nil)
+	repo.On("CreateUser", ctx, mock.AnythingOfType("*models.User")).Return(nil)
+	verification.On("SendVerification", ctx, mock.AnythingOfType("*models.User")).Return(errors.New("smtp down"))
+
+	result, err := svc.CreateUser(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, req.Email, result.User.Email)
+}
+
+func TestUserService_CreateUser_PrivacyModeDuplicate(t *testing.T) {
+	repo := new(mockUserRepo)
+	mailer := new(mockMailer)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), mailer, services.UserSettings{SignupPrivacyMode: true})
+	ctx := context.Background()
+
+	req := &models.CreateUserRequest{Name: "Test", Email: "a@b.com", Age: 20, Password: "12345678"}
+	repo.On("GetUserByEmail", ctx, req.Email).Return(&models.User{ID: 1, Name: "Owner", Email: "a@b.com"}, nil)
+	mailer.On("Send", ctx, mock.AnythingOfType("mail.Message")).Return(nil)
+
+	result, err := svc.CreateUser(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, &services.SignupResult{Accepted: true}, result)
+	// Владелец адреса получает уведомление вместо письма подтверждения
+	msg := mailer.Calls[0].Arguments.Get(1).(mail.Message)
+	assert.Equal(t, "a@b.com", msg.To)
+	assert.Contains(t, msg.Body, "you already have one")
+	repo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
+
+func TestUserService_CreateUser_PrivacyModeEquivalentWork(t *testing.T) {
+	repo := new(mockUserRepo)
+	verification := new(mockEmailVerificationService)
+	mailer := new(mockMailer)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), verification, new(mockAuthService), mailer, services.UserSettings{SignupPrivacyMode: true})
+	ctx := context.Background()
+	hasher := useCountingPasswordHasher(t)
+
+	repo.On("GetUserByEmail", ctx, "taken@b.com").Return(&models.User{ID: 1, Email: "taken@b.com"}, nil)
+	repo.On("GetUserByEmail", ctx, "new@b.com").Return(nil, nil)
+	repo.On("CreateUser", ctx, mock.AnythingOfType("*models.User")).Return(nil)
+	verification.On("SendVerification", ctx, mock.AnythingOfType("*models.User")).Return(nil)
+	mailer.On("Send", ctx, mock.AnythingOfType("mail.Message")).Return(nil)
+
+	newResult, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Test", Email: "new@b.com", Age: 20, Password: "12345678"})
+	require.NoError(t, err)
+	newEmail := hasher.take()
+	takenResult, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Test", Email: "taken@b.com", Age: 20, Password: "12345678"})
+	require.NoError(t, err)
+	takenEmail := hasher.take()
+
+	// Результаты для нового и занятого email неразличимы
+	assert.Equal(t, &services.SignupResult{Accepted: true}, newResult)
+	assert.Equal(t, newResult, takenResult)
+
+	// Оба пути хешируют пароль один раз и отправляют ровно одно письмо
+	assert.Equal(t, passwordHasherCalls{hashes: 1}, newEmail)
+	assert.Equal(t, newEmail, takenEmail)
+	verification.AssertNumberOfCalls(t, "SendVerification", 1)
+	mailer.AssertNumberOfCalls(t, "Send", 1)
+}
+
+func TestUserService_CreateUser_WeakPassword(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newTestPasswordPolicy(t), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := context.Background()
+
+	req := &models.CreateUserRequest{Name: "Test", Email: "a@b.com", Age: 20, Password: "short"}
+
+	result, err := svc.CreateUser(ctx, req)
+	assert.ErrorIs(t, err, services.ErrWeakPassword)
+	assert.Nil(t, result)
+	// Занятость email не проверяется, пока пароль не прошёл политику
+	repo.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
 }
 
 func TestUserService_CreateUser_DuplicateEmail(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewUserService(repo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
 	ctx := context.Background()
 
 	req := &models.CreateUserRequest{Name: "Test", Email: "a@b.com", Age: 20, Password: "12345678"}
 	repo.On("GetUserByEmail", ctx, req.Email).Return(&models.User{Email: req.Email}, nil)
 
-	user, err := svc.CreateUser(ctx, req)
+	result, err := svc.CreateUser(ctx, req)
 	assert.ErrorIs(t, err, services.ErrEmailExists)
-	assert.Nil(t, user)
+	assert.Nil(t, result)
 }
 
 func TestUserService_GetUserByID(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewUserService(repo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
 	ctx := context.Background()
 
 	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{Email: "a@b.com"}, nil)
@@ -81,7 +203,7 @@ func TestUserService_GetUserByID(t *testing.T) {
 
 func TestUserService_GetUserByID_NotFound(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewUserService(repo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
 	ctx := context.Background()
 
 	repo.On("GetUserByID", ctx, uint(2)).Return(nil, nil)
@@ -92,14 +214,312 @@ func TestUserService_GetUserByID_NotFound(t *testing.T) {
 
 func TestUserService_ListUsers(t *testing.T) {
 	repo := new(mockUserRepo)
-	svc := services.NewUserService(repo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
 	ctx := context.Background()
 
 	users := []models.User{{Email: "a@b.com"}, {Email: "b@b.com"}}
-	repo.On("ListUsers", ctx, 1, 10, 0, 0).Return(users, int64(2), nil)
+	query := models.UserListQuery{ListQuery: models.ListQuery{Page: 1, Limit: 10}, Search: "b.com"}
+	repo.On("ListUsers", ctx, query).Return(users, int64(2), nil)
 
-	result, total, err := svc.ListUsers(ctx, 1, 10, 0, 0)
+	result, total, err := svc.ListUsers(ctx, query)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), total)
 	assert.Len(t, result, 2)
 }
+
+func TestUserService_UpdateUser_Forbidden(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	user, err := svc.UpdateUser(ctx, 2, &models.UpdateUserRequest{Name: "X", Email: "x@b.com", Age: 20}, nil)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	assert.Nil(t, user)
+	repo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+}
+
+func TestUserService_UpdateUser_VersionMismatch(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2, Email: "a@b.com", Version: 5}, nil)
+
+	user, err := svc.UpdateUser(ctx, 2, &models.UpdateUserRequest{Name: "X", Email: "a@b.com", Age: 20}, []int{4})
+	assert.ErrorIs(t, err, services.ErrVersionMismatch)
+	assert.Nil(t, user)
+	repo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+}
+
+func TestUserService_UpdateUser_ConcurrentModification(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2, Email: "a@b.com", Version: 5}, nil)
+	repo.On("UpdateUser", ctx, mock.AnythingOfType("*models.User")).Return(gorm.ErrRecordNotFound)
+
+	user, err := svc.UpdateUser(ctx, 2, &models.UpdateUserRequest{Name: "X", Email: "a@b.com", Age: 20}, []int{5})
+	assert.ErrorIs(t, err, services.ErrVersionMismatch)
+	assert.Nil(t, user)
+}
+
+func TestUserService_PatchUser_OnlyChangedFields(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	name, age := "Old", 31
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "Old", Email: "a@b.com", Age: 30}, nil)
+	repo.On("UpdateUserFields", ctx, uint(1), 0, map[string]interface{}{"age": 31}).Return(nil)
+
+	user, err := svc.PatchUser(ctx, 1, &models.PatchUserRequest{Name: &name, Age: &age}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Old", user.Name)
+	assert.Equal(t, "a@b.com", user.Email)
+	assert.Equal(t, 31, user.Age)
+	repo.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+}
+
+func TestUserService_UpdateUser_EmailChangeResetsVerification(t *testing.T) {
+	repo := new(mockUserRepo)
+	verification := new(mockEmailVerificationService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), verification, new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	verifiedAt := time.Now().UTC()
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "A", Email: "a@b.com", Age: 30, EmailVerifiedAt: &verifiedAt}, nil)
+	repo.On("GetUserByEmail", ctx, "new@b.com").Return(nil, nil)
+	repo.On("UpdateUser", ctx, mock.MatchedBy(func(u *models.User) bool {
+		return u.Email == "new@b.com" && u.EmailVerifiedAt == nil
+	})).Return(nil)
+	verification.On("SendVerification", ctx, mock.MatchedBy(func(u *models.User) bool { return u.Email == "new@b.com" })).Return(nil)
+
+	user, err := svc.UpdateUser(ctx, 1, &models.UpdateUserRequest{Name: "A", Email: "new@b.com", Age: 30}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, user.EmailVerifiedAt)
+	repo.AssertExpectations(t)
+	verification.AssertExpectations(t)
+}
+
+func TestUserService_UpdateUser_SameEmailKeepsVerification(t *testing.T) {
+	repo := new(mockUserRepo)
+	verification := new(mockEmailVerificationService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), verification, new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	verifiedAt := time.Now().UTC()
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "A", Email: "a@b.com", Age: 30, EmailVerifiedAt: &verifiedAt}, nil)
+	repo.On("UpdateUser", ctx, mock.AnythingOfType("*models.User")).Return(nil)
+
+	user, err := svc.UpdateUser(ctx, 1, &models.UpdateUserRequest{Name: "B", Email: "a@b.com", Age: 30}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, user.EmailVerifiedAt)
+	verification.AssertNotCalled(t, "SendVerification", mock.Anything, mock.Anything)
+}
+
+func TestUserService_PatchUser_EmailChangeResetsVerification(t *testing.T) {
+	repo := new(mockUserRepo)
+	verification := new(mockEmailVerificationService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), verification, new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	email := "new@b.com"
+	verifiedAt := time.Now().UTC()
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "A", Email: "a@b.com", Age: 30, Version: 2, EmailVerifiedAt: &verifiedAt}, nil)
+	repo.On("GetUserByEmail", ctx, email).Return(nil, nil)
+	repo.On("UpdateUserFields", ctx, uint(1), 2, map[string]interface{}{"email": email, "email_verified_at": nil}).Return(nil)
+	verification.On("SendVerification", ctx, mock.MatchedBy(func(u *models.User) bool { return u.Email == email })).Return(errors.New("smtp down"))
+
+	user, err := svc.PatchUser(ctx, 1, &models.PatchUserRequest{Email: &email}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, email, user.Email)
+	assert.Nil(t, user.EmailVerifiedAt)
+	repo.AssertExpectations(t)
+	verification.AssertExpectations(t)
+}
+
+func TestUserService_PatchUser_EmptyPatch(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Name: "Old", Email: "a@b.com", Age: 30}, nil)
+
+	user, err := svc.PatchUser(ctx, 1, &models.PatchUserRequest{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Old", user.Name)
+	repo.AssertNotCalled(t, "UpdateUserFields", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_PatchUser_EmailExists(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleUser)
+
+	email := "taken@b.com"
+	repo.On("GetUserByID", ctx, uint(1)).Return(&models.User{ID: 1, Email: "a@b.com"}, nil)
+	repo.On("GetUserByEmail", ctx, email).Return(&models.User{ID: 2, Email: email}, nil)
+
+	user, err := svc.PatchUser(ctx, 1, &models.PatchUserRequest{Email: &email}, nil)
+	assert.ErrorIs(t, err, services.ErrEmailExists)
+	assert.Nil(t, user)
+	repo.AssertNotCalled(t, "UpdateUserFields", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_PatchUser_Forbidden(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+
+	age := 40
+	user, err := svc.PatchUser(contextWithUser(1, models.RoleUser), 2, &models.PatchUserRequest{Age: &age}, nil)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	assert.Nil(t, user)
+	repo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+}
+
+func TestUserService_DeleteUser_AdminAllowed(t *testing.T) {
+	repo := new(mockUserRepo)
+	auth := new(mockAuthService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), auth, new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(1, models.RoleAdmin)
+
+	repo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2}, nil)
+	repo.On("DeleteUser", ctx, uint(2), mock.Anything).Return(nil)
+	auth.On("LogoutAll", ctx, uint(2)).Return(nil)
+
+	err := svc.DeleteUser(ctx, 2)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+	auth.AssertExpectations(t)
+}
+
+func TestUserService_DeleteUser_RevokeFailure(t *testing.T) {
+	repo := new(mockUserRepo)
+	auth := new(mockAuthService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), auth, new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(2, models.RoleUser)
+
+	repo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2}, nil)
+	repo.On("DeleteUser", ctx, uint(2), mock.Anything).Return(nil)
+	auth.On("LogoutAll", ctx, uint(2)).Return(errors.New("db error"))
+
+	err := svc.DeleteUser(ctx, 2)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to revoke tokens of deleted user id=2")
+}
+
+func TestUserService_DeleteUser_RestrictedByOrders(t *testing.T) {
+	repo := new(mockUserRepo)
+	auth := new(mockAuthService)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), auth, new(mockMailer), services.UserSettings{OrderPolicy: models.OrderPolicyRestrict})
+	ctx := contextWithUser(2, models.RoleUser)
+
+	repo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2}, nil)
+	repo.On("DeleteUser", ctx, uint(2), models.OrderPolicyRestrict).Return(repository.ErrUserHasOrders)
+
+	err := svc.DeleteUser(ctx, 2)
+	assert.ErrorIs(t, err, services.ErrUserHasOrders)
+	auth.AssertNotCalled(t, "LogoutAll", ctx, uint(2))
+}
+
+func TestUserService_DeleteUser_PassesOrderPolicy(t *testing.T) {
+	for _, policy := range []string{models.OrderPolicyRestrict, models.OrderPolicyCascade, models.OrderPolicyAnonymize} {
+		t.Run(policy, func(t *testing.T) {
+			repo := new(mockUserRepo)
+			auth := new(mockAuthService)
+			svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), auth, new(mockMailer), services.UserSettings{OrderPolicy: policy})
+			ctx := contextWithUser(2, models.RoleUser)
+
+			repo.On("GetUserByID", ctx, uint(2)).Return(&models.User{ID: 2}, nil)
+			repo.On("DeleteUser", ctx, uint(2), policy).Return(nil)
+			auth.On("LogoutAll", ctx, uint(2)).Return(nil)
+
+			err := svc.DeleteUser(ctx, 2)
+			assert.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserService_RestoreUser(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("GetDeletedUserByID", ctx, uint(2)).Return(&models.User{ID: 2, Email: "a@b.com", Version: 3}, nil)
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(nil, nil)
+	repo.On("RestoreUser", ctx, uint(2)).Return(nil)
+
+	user, err := svc.RestoreUser(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), user.ID)
+	assert.Equal(t, 4, user.Version)
+}
+
+func TestUserService_RestoreUser_EmailTaken(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("GetDeletedUserByID", ctx, uint(2)).Return(&models.User{ID: 2, Email: "a@b.com"}, nil)
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(&models.User{ID: 5, Email: "a@b.com"}, nil)
+
+	user, err := svc.RestoreUser(ctx, 2)
+	assert.ErrorIs(t, err, services.ErrEmailExists)
+	assert.Nil(t, user)
+	repo.AssertNotCalled(t, "RestoreUser", mock.Anything, mock.Anything)
+}
+
+// Email заняли между проверкой и восстановлением: уникальный индекс отклоняет запись, ответ — конфликт, а не ошибка сервера
+func TestUserService_RestoreUser_EmailTakenConcurrently(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("GetDeletedUserByID", ctx, uint(2)).Return(&models.User{ID: 2, Email: "a@b.com"}, nil)
+	repo.On("GetUserByEmail", ctx, "a@b.com").Return(nil, nil)
+	repo.On("RestoreUser", ctx, uint(2)).Return(repository.ErrEmailTaken)
+
+	user, err := svc.RestoreUser(ctx, 2)
+	assert.ErrorIs(t, err, services.ErrEmailExists)
+	assert.Nil(t, user)
+}
+
+func TestUserService_RestoreUser_NotDeleted(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+	ctx := contextWithUser(9, models.RoleAdmin)
+
+	repo.On("GetDeletedUserByID", ctx, uint(2)).Return(nil, nil)
+
+	user, err := svc.RestoreUser(ctx, 2)
+	assert.ErrorIs(t, err, services.ErrUserNotFound)
+	assert.Nil(t, user)
+}
+
+func TestUserService_RestoreUser_Forbidden(t *testing.T) {
+	repo := new(mockUserRepo)
+	svc := services.NewUserService(repo, services.NewAuthorizer(), newPermissivePasswordPolicy(), new(mockEmailVerificationService), new(mockAuthService), new(mockMailer), services.UserSettings{})
+
+	user, err := svc.RestoreUser(contextWithUser(2, models.RoleUser), 2)
+	assert.ErrorIs(t, err, services.ErrForbidden)
+	assert.Nil(t, user)
+	repo.AssertNotCalled(t, "GetDeletedUserByID", mock.Anything, mock.Anything)
+}
+
+func TestUserRetentionJob_PurgeExpired(t *testing.T) {
+	repo := new(mockUserRepo)
+	job := services.NewUserRetentionJob(repo, services.UserRetentionSettings{Retention: 30 * 24 * time.Hour, Interval: time.Hour, OrderPolicy: models.OrderPolicyAnonymize})
+	ctx := context.Background()
+
+	var deletedBefore time.Time
+	repo.On("PurgeDeletedUsers", ctx, mock.AnythingOfType("time.Time"), models.OrderPolicyAnonymize).Run(func(args mock.Arguments) {
+		deletedBefore = args.Get(1).(time.Time)
+	}).Return(int64(2), nil)
+
+	purged, err := job.PurgeExpired(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assert.WithinDuration(t, time.Now().Add(-30*24*time.Hour), deletedBefore, time.Minute)
+}
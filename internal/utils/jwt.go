@@ -1,71 +1,234 @@
 package utils
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
 )
 
-// Загружаем .env один раз при инициализации пакета
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		Warn("No .env file found or error loading it, relying on environment variables. %v", err)
-	}
+// Классы ошибок проверки JWT
+// ParseJWT оборачивает исходную ошибку библиотеки одной из них
+var ErrJWTMalformed = errors.New("malformed token")
+var ErrJWTSignatureInvalid = errors.New("invalid token signature")
+var ErrJWTExpired = errors.New("expired token")
+var ErrJWTNotValidYet = errors.New("token not valid yet")
+var ErrJWTInvalidIssuer = errors.New("invalid token issuer")
+var ErrJWTInvalidAudience = errors.New("invalid token audience")
+var ErrJWTInvalidClaims = errors.New("invalid token claims")
+
+// Соответствие ошибок библиотеки классам ошибок проверки
+var jwtErrorClasses = []struct {
+	cause error
+	class error
+}{
+	{jwt.ErrTokenMalformed, ErrJWTMalformed},
+	{jwt.ErrTokenSignatureInvalid, ErrJWTSignatureInvalid},
+	{jwt.ErrTokenUnverifiable, ErrJWTSignatureInvalid},
+	{jwt.ErrTokenExpired, ErrJWTExpired},
+	{jwt.ErrTokenNotValidYet, ErrJWTNotValidYet},
+	{jwt.ErrTokenUsedBeforeIssued, ErrJWTNotValidYet},
+	{jwt.ErrTokenInvalidIssuer, ErrJWTInvalidIssuer},
+	{jwt.ErrTokenInvalidAudience, ErrJWTInvalidAudience},
 }
 
-// jwtSecret — секретный ключ для подписи токенов
-var jwtSecret = []byte(getJWTSecret())
+// Возвращает время жизни access-токена
+func AccessTokenExpiration() time.Duration {
+	return currentJWTSettings().accessTTL
+}
 
-// Получает секретный ключ из .env или переменных окружения
-func getJWTSecret() string {
-	if value, exists := os.LookupEnv("JWT_SECRET"); exists {
-		return value
-	}
-	return "your-secret-key"
+// Возвращает время жизни refresh-токена
+func RefreshTokenExpiration() time.Duration {
+	return currentJWTSettings().refreshTTL
 }
 
-// Получает время жизни токена из .env или переменных окружения
-func getJWTExpiration() time.Duration {
-	if value, exists := os.LookupEnv("JWT_EXPIRATION"); exists {
-		dur, err := time.ParseDuration(value)
-		if err == nil {
-			return dur
-		}
-		Warn("Invalid JWT_EXPIRATION format: %s, using default 24h", value)
-	}
-	return 24 * time.Hour
+// Генерирует JWT-токен для пользователя по его ID, роли и областям действия
+// Каждый токен получает уникальный jti, по которому его можно отозвать
+// iat хранится с точностью до миллисекунд, чтобы массовый отзыв не задевал токены,
+// выпущенные в ту же секунду сразу после него.
+// Области действия записываются в claim scope через пробел; при пустом списке claim не добавляется
+func GenerateJWT(userID uint, role string, scopes []string) (string, error) {
+	token, _, err := GenerateSessionJWT(userID, role, scopes, 0)
+	return token, err
 }
 
-// Генерирует JWT-токен для пользователя по его ID
-func GenerateJWT(userID uint) (string, error) {
-	expiration := getJWTExpiration()
+// Генерирует JWT-токен пользователя, выданный в рамках сессии (входа на устройстве)
+// ID сессии записывается в claim sid; нулевой ID claim не добавляет.
+// Кроме токена возвращает его jti, чтобы токен можно было отозвать вместе с сессией
+func GenerateSessionJWT(userID uint, role string, scopes []string, sessionID uint) (string, string, error) {
 	claims := jwt.MapClaims{
+		"sub":     strconv.FormatUint(uint64(userID), 10),
 		"user_id": userID,
-		"exp":     time.Now().Add(expiration).Unix(),
+		"role":    role,
+	}
+	if sessionID != 0 {
+		claims["sid"] = sessionID
+	}
+	return signJWT(claims, scopes, currentJWTSettings().accessTTL)
+}
+
+// Генерирует JWT-токен, с которым администратор работает от имени пользователя (имперсонация)
+// Субъект токена — пользователь, а настоящий вызывающий записывается в claim act (RFC 8693).
+// Время жизни задаётся отдельно от обычных access-токенов; возвращает токен и его jti
+func GenerateImpersonationJWT(userID uint, role string, scopes []string, actorID uint, ttl time.Duration) (string, string, error) {
+	return signJWT(jwt.MapClaims{
+		"sub":     strconv.FormatUint(uint64(userID), 10),
+		"user_id": userID,
+		"role":    role,
+		"act": map[string]interface{}{
+			"sub":     strconv.FormatUint(uint64(actorID), 10),
+			"user_id": actorID,
+		},
+	}, scopes, ttl)
+}
+
+// Генерирует JWT-токен для зарегистрированного клиента OAuth2 (grant client_credentials)
+// Субъект токена — клиент, а не пользователь: вместо user_id и role токен содержит client_id
+func GenerateClientJWT(clientID string, scopes []string) (string, error) {
+	token, _, err := signJWT(jwt.MapClaims{
+		"sub":       clientID,
+		"client_id": clientID,
+	}, scopes, currentJWTSettings().accessTTL)
+	return token, err
+}
+
+// Дополняет claims субъекта общими claims (jti, сроки, издатель, аудитория, области действия) и подписывает токен
+// Возвращает подписанный токен и его jti
+func signJWT(claims jwt.MapClaims, scopes []string, ttl time.Duration) (string, string, error) {
+	settings := currentJWTSettings()
+	jti, err := GenerateRandomID(16)
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	issuedAt := float64(now.UnixMilli()) / 1000
+	claims["jti"] = jti
+	claims["iat"] = issuedAt
+	claims["nbf"] = issuedAt
+	claims["exp"] = now.Add(ttl).Unix()
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	if settings.issuer != "" {
+		claims["iss"] = settings.issuer
+	}
+	if len(settings.audience) > 0 {
+		claims["aud"] = settings.audience
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	signing := settings.signing
+	token := jwt.NewWithClaims(signing.method, claims)
+	if signing.kid != "" {
+		token.Header["kid"] = signing.kid
+	}
+	signed, err := token.SignedString(signing.signKey)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
 }
 
 // Разбирает и валидирует JWT-токен, возвращает claims
+// Ключ проверки выбирается по заголовку kid; токены без kid (выпущенные до назначения kid ключу)
+// проверяются текущим ключом подписи.
+// Алгоритм токена должен входить в список разрешённых и совпадать с алгоритмом ключа.
+// Сроки (exp, nbf, iat) проверяются с допуском на расхождение часов, издатель и аудитория —
+// если они заданы в настройках. Ошибка оборачивает один из классов ErrJWT*
 func ParseJWT(tokenString string) (jwt.MapClaims, error) {
+	settings := currentJWTSettings()
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(settings.allowedAlgorithms),
+		jwt.WithLeeway(settings.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if settings.issuer != "" {
+		options = append(options, jwt.WithIssuer(settings.issuer))
+	}
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		return nil, err
+		kid, _ := token.Header["kid"].(string)
+		key, ok := settings.verification[kid]
+		if kid == "" {
+			key, ok = settings.signing, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return key.verifyKey, nil
+	}, options...)
+	if err != nil {
+		return nil, classifyJWTError(err)
 	}
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, jwt.ErrInvalidKey
+	if !ok || !token.Valid {
+		return nil, ErrJWTInvalidClaims
+	}
+	if len(settings.audience) > 0 && !hasAudience(claims, settings.audience) {
+		return nil, fmt.Errorf("%w: expected one of %v", ErrJWTInvalidAudience, settings.audience)
 	}
 	return claims, nil
 }
 
-// Возвращает ошибку истечения срока действия токена
-func JwtErrTokenExpired() error {
-	return jwt.ErrTokenExpired
+// Проверяет, что claim aud содержит хотя бы одну из ожидаемых аудиторий
+func hasAudience(claims jwt.MapClaims, expected []string) bool {
+	audience, err := claims.GetAudience()
+	if err != nil {
+		return false
+	}
+	for _, aud := range audience {
+		if slices.Contains(expected, aud) {
+			return true
+		}
+	}
+	return false
+}
+
+// Оборачивает ошибку библиотеки соответствующим классом ошибок проверки
+func classifyJWTError(err error) error {
+	for _, c := range jwtErrorClasses {
+		if errors.Is(err, c.cause) {
+			return fmt.Errorf("%w: %w", c.class, err)
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrJWTInvalidClaims, err)
+}
+
+// Возвращает области действия из claim scope
+// Второе значение равно false, если claim отсутствует (токены, выпущенные до появления областей действия)
+func ClaimScopes(claims jwt.MapClaims) ([]string, bool) {
+	scope, ok := claims["scope"].(string)
+	if !ok {
+		return nil, false
+	}
+	return strings.Fields(scope), true
+}
+
+// Возвращает ID администратора из claim act токена имперсонации
+// Второе значение равно false, если токен выдан не при имперсонации
+func ClaimActorID(claims jwt.MapClaims) (uint, bool) {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	actorID, ok := act["user_id"].(float64)
+	if !ok || actorID < 1 {
+		return 0, false
+	}
+	return uint(actorID), true
+}
+
+// Возвращает момент времени из числового claim (iat, exp и т.п.)
+func ClaimTime(claims jwt.MapClaims, name string) (time.Time, bool) {
+	value, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(math.Round(value * 1000))), true
 }
@@ -0,0 +1,408 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Поддерживаемые алгоритмы подписи JWT
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+	JWTAlgES256 = "ES256"
+	JWTAlgEdDSA = "EdDSA"
+)
+
+// Значения по умолчанию для разработки
+// Секрет по умолчанию небезопасен: конфигурация приложения запрещает его в режиме release
+const (
+	DefaultJWTSecret       = "your-secret-key"
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 720 * time.Hour
+	DefaultJWTLeeway       = 30 * time.Second
+)
+
+// Настройки выпуска и проверки JWT
+// Для HS256 используется общий секрет, для RS256/ES256/EdDSA — закрытый ключ из PEM-файла.
+// VerificationKeyFiles — дополнительные ключи, которыми принимаются ранее выпущенные токены
+// во время ротации; элемент списка имеет вид "путь" или "kid=путь".
+// Issuer и Audience записываются в выпускаемые токены и требуются от принимаемых.
+// AllowedAlgorithms по умолчанию — алгоритмы загруженных ключей, нулевой Leeway — без допуска.
+// Незаданные поля заменяются значениями по умолчанию
+type JWTConfig struct {
+	Algorithm            string
+	Secret               string
+	KeyID                string
+	PrivateKeyFile       string
+	VerificationKeyFiles []string
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	Issuer               string
+	Audience             []string
+	AllowedAlgorithms    []string
+	Leeway               time.Duration
+}
+
+// Ключ подписи или проверки с его идентификатором и алгоритмом
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Действующие настройки: ключ подписи, все ключи, которыми принимаются токены,
+// сроки жизни и требования к claims
+type jwtSettings struct {
+	signing           *jwtKey
+	verification      map[string]*jwtKey
+	accessTTL         time.Duration
+	refreshTTL        time.Duration
+	issuer            string
+	audience          []string
+	allowedAlgorithms []string
+	leeway            time.Duration
+}
+
+// Текущие настройки; до вызова ConfigureJWT используются значения по умолчанию
+var jwtCurrent atomic.Pointer[jwtSettings]
+
+// Публичный ключ в формате JWK (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Набор публичных ключей в формате JWKS
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Загружает ключи по настройкам и делает настройки текущими
+// При ошибке текущие настройки не меняются
+func ConfigureJWT(cfg JWTConfig) error {
+	settings, err := loadJWTSettings(cfg)
+	if err != nil {
+		return err
+	}
+	jwtCurrent.Store(settings)
+	return nil
+}
+
+// Возвращает публичные ключи проверки подписи для эндпоинта JWKS
+// Для HS256 набор пуст: общий секрет не публикуется
+func PublicJWKS() JWKSet {
+	settings := currentJWTSettings()
+	set := JWKSet{Keys: []JWK{}}
+	if settings.signing.kid != "" {
+		if jwk, ok := publicJWK(settings.signing); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	for kid, key := range settings.verification {
+		if kid == settings.signing.kid {
+			continue
+		}
+		if jwk, ok := publicJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// Возвращает текущие настройки, при необходимости создавая настройки по умолчанию
+func currentJWTSettings() *jwtSettings {
+	if settings := jwtCurrent.Load(); settings != nil {
+		return settings
+	}
+	settings, err := loadJWTSettings(JWTConfig{})
+	if err != nil {
+		// Настройки по умолчанию используют только секрет и не могут завершиться ошибкой
+		panic(err)
+	}
+	jwtCurrent.CompareAndSwap(nil, settings)
+	return jwtCurrent.Load()
+}
+
+// Собирает действующие настройки и загружает ключи
+func loadJWTSettings(cfg JWTConfig) (*jwtSettings, error) {
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = JWTAlgHS256
+	}
+	var signing *jwtKey
+	switch algorithm {
+	case JWTAlgHS256:
+		secret := cfg.Secret
+		if secret == "" {
+			secret = DefaultJWTSecret
+		}
+		signing = &jwtKey{kid: cfg.KeyID, method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+	case JWTAlgRS256, JWTAlgES256, JWTAlgEdDSA:
+		if cfg.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("private key file is required for %s", algorithm)
+		}
+		key, err := loadPrivateJWTKey(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.method.Alg() != algorithm {
+			return nil, fmt.Errorf("private key %s is a %s key, but algorithm %s is configured", cfg.PrivateKeyFile, key.method.Alg(), algorithm)
+		}
+		if cfg.KeyID != "" {
+			key.kid = cfg.KeyID
+		}
+		signing = key
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+
+	settings := &jwtSettings{
+		signing:      signing,
+		verification: map[string]*jwtKey{signing.kid: signing},
+		accessTTL:    cfg.AccessTokenTTL,
+		refreshTTL:   cfg.RefreshTokenTTL,
+		issuer:       cfg.Issuer,
+		audience:     cfg.Audience,
+		leeway:       cfg.Leeway,
+	}
+	if settings.accessTTL <= 0 {
+		settings.accessTTL = DefaultAccessTokenTTL
+	}
+	if settings.refreshTTL <= 0 {
+		settings.refreshTTL = DefaultRefreshTokenTTL
+	}
+	for _, entry := range cfg.VerificationKeyFiles {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path := "", entry
+		if before, after, found := strings.Cut(entry, "="); found {
+			kid, path = strings.TrimSpace(before), strings.TrimSpace(after)
+		}
+		key, err := loadPublicJWTKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if kid != "" {
+			key.kid = kid
+		}
+		if _, exists := settings.verification[key.kid]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id %q in %s", key.kid, path)
+		}
+		settings.verification[key.kid] = key
+	}
+	// Список разрешённых алгоритмов: заданный явно или алгоритмы загруженных ключей
+	if len(cfg.AllowedAlgorithms) > 0 {
+		for _, alg := range cfg.AllowedAlgorithms {
+			if !IsSupportedJWTAlgorithm(alg) {
+				return nil, fmt.Errorf("unsupported JWT algorithm %q in allowed algorithms", alg)
+			}
+		}
+		if !slices.Contains(cfg.AllowedAlgorithms, signing.method.Alg()) {
+			return nil, fmt.Errorf("signing algorithm %s is not in allowed algorithms %v", signing.method.Alg(), cfg.AllowedAlgorithms)
+		}
+		settings.allowedAlgorithms = slices.Clone(cfg.AllowedAlgorithms)
+	} else {
+		for _, key := range settings.verification {
+			if !slices.Contains(settings.allowedAlgorithms, key.method.Alg()) {
+				settings.allowedAlgorithms = append(settings.allowedAlgorithms, key.method.Alg())
+			}
+		}
+	}
+	return settings, nil
+}
+
+// Проверяет, поддерживается ли алгоритм подписи
+func IsSupportedJWTAlgorithm(alg string) bool {
+	switch alg {
+	case JWTAlgHS256, JWTAlgRS256, JWTAlgES256, JWTAlgEdDSA:
+		return true
+	}
+	return false
+}
+
+// Загружает закрытый ключ из PEM-файла (PKCS#8, PKCS#1 или SEC 1)
+func loadPrivateJWTKey(path string) (*jwtKey, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T in %s", private, path)
+	}
+	key, err := newAsymmetricJWTKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key in %s: %w", path, err)
+	}
+	key.signKey = private
+	return key, nil
+}
+
+// Загружает публичный ключ из PEM-файла
+// Допускаются публичный ключ (PKIX или PKCS#1), сертификат или закрытый ключ
+func loadPublicJWTKey(path string) (*jwtKey, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var public interface{}
+	switch block.Type {
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			public = cert.PublicKey
+		}
+	default:
+		key, err := loadPrivateJWTKey(path)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey = nil
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	key, err := newAsymmetricJWTKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported public key in %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// Читает первый PEM-блок из файла
+func readPEMFile(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
+
+// Определяет алгоритм по типу публичного ключа; kid по умолчанию — отпечаток ключа (RFC 7638)
+func newAsymmetricJWTKey(public interface{}) (*jwtKey, error) {
+	key := &jwtKey{verifyKey: public}
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 curve is supported for ES256")
+		}
+		key.method = jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+	jwk, _ := publicJWK(key)
+	key.kid = jwkThumbprint(jwk)
+	return key, nil
+}
+
+// Формирует JWK для публичного ключа; для симметричного ключа возвращает false
+func publicJWK(key *jwtKey) (JWK, bool) {
+	jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+	switch pub := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		ecdhKey, err := pub.ECDH()
+		if err != nil {
+			return JWK{}, false
+		}
+		// Несжатая точка: 0x04 || X || Y, координаты по 32 байта для P-256
+		point := ecdhKey.Bytes()[1:]
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[:32])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[32:])
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// Вычисляет отпечаток JWK по RFC 7638: SHA-256 от обязательных полей в лексикографическом порядке
+func jwkThumbprint(jwk JWK) string {
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -1,20 +1,299 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Функции для хеширования и проверки паролей
-// Используется bcrypt для безопасности
+// Новые хеши создаются алгоритмом из настроек (bcrypt или Argon2id), проверяются хеши обоих алгоритмов.
+// Хеши хранятся в формате PHC ($argon2id$v=19$m=...,t=...,p=...$соль$хеш) или в модульном формате bcrypt ($2a$...),
+// поэтому алгоритм и параметры определяются по самому хешу
+
+// Поддерживаемые алгоритмы хеширования паролей
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// Параметры Argon2id по умолчанию (рекомендации OWASP с запасом по памяти)
+const (
+	DefaultArgon2Memory      = 64 * 1024
+	DefaultArgon2Iterations  = 3
+	DefaultArgon2Parallelism = 2
+	argon2SaltLength         = 16
+	argon2KeyLength          = 32
+)
+
+// Настройки хеширования паролей
+// Argon2Memory задаётся в КиБ
+type PasswordHashConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
 
-// Хеширует пароль пользователя
+// Интерфейс алгоритма хеширования паролей
+type PasswordHasher interface {
+	// Хеширует пароль со случайной солью
+	Hash(password string) (string, error)
+	// Проверяет, создан ли хеш этим алгоритмом
+	Supports(hash string) bool
+	// Проверяет соответствие пароля и хеша
+	Verify(password, hash string) bool
+	// Проверяет, отличаются ли параметры хеша от текущих
+	NeedsRehash(hash string) bool
+}
+
+// Действующие настройки: алгоритм для новых хешей, все алгоритмы для проверки
+// и хеш-заглушка для уравнивания времени входа
+type passwordHashing struct {
+	preferred PasswordHasher
+	verifiers []PasswordHasher
+	dummyHash string
+}
+
+// Текущие настройки; до вызова ConfigurePasswordHashing используется bcrypt со стоимостью по умолчанию
+var passwordHashingCurrent atomic.Pointer[passwordHashing]
+
+// Проверяет настройки и делает их текущими
+// При ошибке текущие настройки не меняются
+func ConfigurePasswordHashing(cfg PasswordHashConfig) error {
+	hashing, err := newPasswordHashing(cfg)
+	if err != nil {
+		return err
+	}
+	passwordHashingCurrent.Store(hashing)
+	return nil
+}
+
+// Делает hasher алгоритмом для новых хешей; хеши bcrypt и Argon2id по-прежнему проверяются
+// Позволяет подключить собственную реализацию, например считающую вызовы в тестах
+func UsePasswordHasher(hasher PasswordHasher) error {
+	hashing, err := passwordHashingWith(hasher)
+	if err != nil {
+		return err
+	}
+	passwordHashingCurrent.Store(hashing)
+	return nil
+}
+
+// Создаёт алгоритм хеширования паролей по настройкам
+// Незаданные параметры заменяются значениями по умолчанию
+func NewPasswordHasher(cfg PasswordHashConfig) (PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case PasswordHashBcrypt, "":
+		return NewBcryptHasher(cfg.BcryptCost)
+	case PasswordHashArgon2id:
+		return NewArgon2idHasher(cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+}
+
+// Хеширует пароль пользователя текущим алгоритмом
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	return currentPasswordHashing().preferred.Hash(password)
 }
 
-// Проверяет соответствие пароля и хеша
+// Проверяет соответствие пароля и хеша любого поддерживаемого алгоритма
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	for _, hasher := range currentPasswordHashing().verifiers {
+		if hasher.Supports(hash) {
+			return hasher.Verify(password, hash)
+		}
+	}
+	return false
+}
+
+// Проверяет, нужно ли пересчитать хеш: он создан другим алгоритмом или с другими параметрами
+// Пересчитать хеш можно только при входе, когда известен пароль
+func PasswordNeedsRehash(hash string) bool {
+	preferred := currentPasswordHashing().preferred
+	return !preferred.Supports(hash) || preferred.NeedsRehash(hash)
+}
+
+// Проверяет пароль по хешу-заглушке и всегда возвращает false
+// Вызывается, когда пользователь не найден, чтобы вход по неизвестному email
+// занимал столько же времени, сколько вход с неверным паролем
+func CheckDummyPasswordHash(password string) bool {
+	CheckPasswordHash(password, currentPasswordHashing().dummyHash)
+	return false
+}
+
+// Возвращает текущие настройки, при необходимости создавая настройки по умолчанию
+func currentPasswordHashing() *passwordHashing {
+	if hashing := passwordHashingCurrent.Load(); hashing != nil {
+		return hashing
+	}
+	hashing, err := newPasswordHashing(PasswordHashConfig{})
+	if err != nil {
+		// Настройки по умолчанию корректны, ошибкой может завершиться только генерация хеша-заглушки
+		panic(err)
+	}
+	passwordHashingCurrent.CompareAndSwap(nil, hashing)
+	return passwordHashingCurrent.Load()
+}
+
+// Собирает действующие настройки
+func newPasswordHashing(cfg PasswordHashConfig) (*passwordHashing, error) {
+	preferred, err := NewPasswordHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return passwordHashingWith(preferred)
+}
+
+// Собирает действующие настройки для алгоритма новых хешей
+// Хеш-заглушка вычисляется этим алгоритмом сразу, чтобы её стоимость совпадала с настоящими хешами,
+// а первый вход по неизвестному email не был медленнее остальных
+func passwordHashingWith(preferred PasswordHasher) (*passwordHashing, error) {
+	// Параметры хеша берутся из него самого, поэтому для проверки достаточно экземпляра с параметрами по умолчанию
+	verifiers := []PasswordHasher{preferred, &bcryptHasher{cost: bcrypt.DefaultCost}, &argon2idHasher{}}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate dummy password: %w", err)
+	}
+	dummyHash, err := preferred.Hash(base64.RawStdEncoding.EncodeToString(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash dummy password: %w", err)
+	}
+	return &passwordHashing{preferred: preferred, verifiers: verifiers, dummyHash: dummyHash}, nil
+}
+
+// Реализация bcrypt с настраиваемой стоимостью
+type bcryptHasher struct {
+	cost int
+}
+
+// Создаёт алгоритм bcrypt; нулевая стоимость заменяется bcrypt.DefaultCost
+func NewBcryptHasher(cost int) (PasswordHasher, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &bcryptHasher{cost: cost}, nil
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(bytes), err
+}
+
+func (h *bcryptHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h *bcryptHasher) Verify(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+// Реализация Argon2id с настраиваемыми памятью, числом проходов и параллелизмом
+type argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// Параметры, разобранные из хеша Argon2id в формате PHC
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// Создаёт алгоритм Argon2id; нулевые параметры заменяются значениями по умолчанию
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8) (PasswordHasher, error) {
+	if memory == 0 {
+		memory = DefaultArgon2Memory
+	}
+	if iterations == 0 {
+		iterations = DefaultArgon2Iterations
+	}
+	if parallelism == 0 {
+		parallelism = DefaultArgon2Parallelism
+	}
+	// Argon2 требует не меньше 8 КиБ памяти на каждую полосу
+	if memory < 8*uint32(parallelism) {
+		return nil, fmt.Errorf("argon2id memory must be at least %d KiB for parallelism %d", 8*uint32(parallelism), parallelism)
+	}
+	return &argon2idHasher{memory: memory, iterations: iterations, parallelism: parallelism}, nil
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h *argon2idHasher) Verify(password, hash string) bool {
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), parsed.salt, parsed.iterations, parsed.memory, parsed.parallelism, uint32(len(parsed.key)))
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1
+}
+
+func (h *argon2idHasher) NeedsRehash(hash string) bool {
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return parsed.version != argon2.Version || parsed.memory != h.memory || parsed.iterations != h.iterations ||
+		parsed.parallelism != h.parallelism || len(parsed.key) != argon2KeyLength
+}
+
+// Разбирает хеш Argon2id в формате PHC
+func parseArgon2idHash(hash string) (*argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordHashArgon2id {
+		return nil, errors.New("invalid argon2id hash format")
+	}
+	parsed := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &parsed.version); err != nil {
+		return nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.iterations, &parsed.parallelism); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if parsed.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	if parsed.iterations == 0 || parsed.parallelism == 0 || len(parsed.key) == 0 {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+	return parsed, nil
 }
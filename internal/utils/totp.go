@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Функции для одноразовых паролей TOTP (RFC 6238)
+// Параметры совместимы с распространёнными приложениями-аутентификаторами:
+// HMAC-SHA1, 6 цифр, шаг 30 секунд
+
+const (
+	// Размер секрета TOTP в байтах (160 бит, как рекомендует RFC 4226)
+	TOTPSecretSize = 20
+	// Длительность шага TOTP
+	TOTPPeriod = 30 * time.Second
+	// Количество цифр в коде
+	TOTPDigits = 6
+	// Допустимое расхождение часов в шагах в каждую сторону
+	TOTPSkew = 1
+)
+
+// Кодировка секрета: base32 без выравнивания, как в URI otpauth://
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Генерирует случайный секрет TOTP в кодировке base32
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, TOTPSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// Возвращает номер шага TOTP для момента времени
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// Вычисляет код TOTP для указанного шага (HOTP по RFC 4226 со счётчиком = шагу)
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	// Динамическое усечение
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// Проверяет код TOTP с учётом расхождения часов на TOTPSkew шагов
+// Возвращает шаг, которому соответствует код, чтобы вызывающий мог запретить его повторное использование
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := TOTPStep(t)
+	for delta := int64(-TOTPSkew); delta <= TOTPSkew; delta++ {
+		expected, err := TOTPCode(secret, current+delta)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return current + delta, true
+		}
+	}
+	return 0, false
+}
+
+// Формирует URI otpauth:// для QR-кода приложения-аутентификатора
+func TOTPProvisioningURI(secret, issuer, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return uri.String()
+}
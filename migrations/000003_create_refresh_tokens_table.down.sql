@@ -0,0 +1,2 @@
+-- Удалить таблицу refresh-токенов
+DROP TABLE IF EXISTS refresh_tokens;
\ No newline at end of file
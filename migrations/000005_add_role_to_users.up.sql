@@ -0,0 +1,2 @@
+-- Добавить роль пользователя
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'user';
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Удалить таблицу одноразовых токенов действий
+DROP TABLE IF EXISTS action_tokens;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- Создать таблицу одноразовых токенов действий (сброс пароля и т.п.)
+CREATE TABLE IF NOT EXISTS action_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_action_tokens_user_id ON action_tokens (user_id);
\ No newline at end of file
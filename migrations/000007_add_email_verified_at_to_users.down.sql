@@ -0,0 +1,2 @@
+-- Удалить у пользователей отметку о подтверждении email
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Добавить пользователям отметку о подтверждении email
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- Пользователи, зарегистрированные до появления подтверждения, считаются подтверждёнными
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE email_verified_at IS NULL;
\ No newline at end of file
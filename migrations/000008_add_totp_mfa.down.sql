@@ -0,0 +1,4 @@
+-- Удалить таблицы TOTP-факторов и кодов восстановления и счётчик попыток токенов действий
+ALTER TABLE action_tokens DROP COLUMN IF EXISTS attempts;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_factors;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- Создать таблицы TOTP-факторов и кодов восстановления, добавить счётчик попыток токенам действий
+CREATE TABLE IF NOT EXISTS totp_factors (
+    id SERIAL PRIMARY KEY,
+    user_id INT UNIQUE NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+ALTER TABLE action_tokens ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
\ No newline at end of file
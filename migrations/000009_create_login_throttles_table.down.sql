@@ -0,0 +1,2 @@
+-- Удалить таблицу счётчиков неудачных попыток входа
+DROP TABLE IF EXISTS login_throttles;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Удалить таблицу персональных API-ключей
+DROP TABLE IF EXISTS api_keys;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Удалить области действия входа из refresh-токенов
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS scopes;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Добавить области действия входа к refresh-токенам
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS scopes VARCHAR(255) NOT NULL DEFAULT '';
\ No newline at end of file
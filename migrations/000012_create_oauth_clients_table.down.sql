@@ -0,0 +1,2 @@
+-- Удалить таблицу зарегистрированных клиентов OAuth2
+DROP TABLE IF EXISTS oauth_clients;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Удалить таблицу сессий пользователей
+DROP TABLE IF EXISTS sessions;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Удалить журнал имперсонации
+DROP TABLE IF EXISTS impersonations;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- Создать журнал имперсонации пользователей администраторами
+CREATE TABLE IF NOT EXISTS impersonations (
+    id SERIAL PRIMARY KEY,
+    admin_id INT NOT NULL,
+    user_id INT NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    token_id VARCHAR(64) UNIQUE NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonations_admin_id ON impersonations (admin_id);
+CREATE INDEX IF NOT EXISTS idx_impersonations_user_id ON impersonations (user_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Удалить версию записи пользователя
+ALTER TABLE users DROP COLUMN IF EXISTS version;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Добавить версию записи пользователя для оптимистичной блокировки
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
\ No newline at end of file
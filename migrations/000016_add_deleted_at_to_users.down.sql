@@ -0,0 +1,22 @@
+-- Мягко удалённые пользователи мешают вернуть уникальность email, а удалять их при откате нельзя:
+-- откат прерывается, пока такие пользователи не восстановлены или не удалены вручную
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'deleted_at') THEN
+        IF EXISTS (SELECT 1 FROM users WHERE deleted_at IS NOT NULL) THEN
+            RAISE EXCEPTION 'users contain soft-deleted rows, restore or delete them before rolling back';
+        END IF;
+    END IF;
+    IF EXISTS (SELECT 1 FROM users GROUP BY email HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'users contain duplicate emails, resolve them before rolling back';
+    END IF;
+END
+$$;
+
+-- Удалить у пользователей отметку о мягком удалении
+DROP INDEX IF EXISTS idx_users_email_active;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Добавить пользователям отметку о мягком удалении
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+-- Email должен быть уникальным только среди неудалённых пользователей, иначе удалённый аккаунт занимал бы адрес
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (email) WHERE deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- Обезличенные заказы без пользователя не удовлетворяют NOT NULL, а удалять их при откате нельзя:
+-- откат прерывается, пока такие заказы не перенесены или не удалены вручную
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM orders WHERE user_id IS NULL) THEN
+        RAISE EXCEPTION 'orders contain anonymized rows with NULL user_id, move or delete them before rolling back';
+    END IF;
+END
+$$;
+
+-- Удалить внешний ключ заказов на пользователей
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS fk_orders_user;
+DROP INDEX IF EXISTS idx_orders_user_id;
+ALTER TABLE orders ALTER COLUMN user_id SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- Связать заказы с пользователями внешним ключом
+-- user_id может быть NULL: так хранятся обезличенные заказы окончательно удалённых пользователей
+ALTER TABLE orders ALTER COLUMN user_id DROP NOT NULL;
+
+-- Заказы, оставшиеся от удалённых раньше пользователей, обезличиваются, иначе ключ не создать
+UPDATE orders SET user_id = NULL WHERE user_id IS NOT NULL AND user_id NOT IN (SELECT id FROM users);
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+
+-- Что делать с заказами при удалении пользователя, решает приложение (USER_DELETE_ORDER_POLICY);
+-- ключ не даёт удалить пользователя с заказами в обход этой политики и создать заказ несуществующему пользователю
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS fk_orders_user;
+ALTER TABLE orders ADD CONSTRAINT fk_orders_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE RESTRICT;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Удалить таблицу отозванных сессий
+DROP TABLE IF EXISTS revoked_sessions;
\ No newline at end of file
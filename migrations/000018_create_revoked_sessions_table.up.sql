@@ -0,0 +1,10 @@
+-- Создать таблицу отозванных сессий
+-- Access-токены с claim sid завершённой сессии отклоняются, пока не истекут сами
+CREATE TABLE IF NOT EXISTS revoked_sessions (
+    session_id INT PRIMARY KEY,
+    user_id INT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_sessions_user_id ON revoked_sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_revoked_sessions_expires_at ON revoked_sessions (expires_at);
\ No newline at end of file
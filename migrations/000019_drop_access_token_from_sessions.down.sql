@@ -0,0 +1,3 @@
+-- Вернуть в сессии jti последнего access-токена
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS access_token_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS access_token_expires_at TIMESTAMP;
\ No newline at end of file
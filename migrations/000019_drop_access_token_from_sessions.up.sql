@@ -0,0 +1,3 @@
+-- Удалить из сессий jti последнего access-токена: токены сессии отзываются по claim sid
+ALTER TABLE sessions DROP COLUMN IF EXISTS access_token_id;
+ALTER TABLE sessions DROP COLUMN IF EXISTS access_token_expires_at;
\ No newline at end of file